@@ -32,17 +32,27 @@ func main() {
 	}
 	defer database.Close()
 
-	// Initialize Schema (simple migration for now)
-	schema, err := os.ReadFile("internal/database/schema.sql")
-	if err == nil {
-		_, err = database.DB.Exec(context.Background(), string(schema))
-		if err != nil {
-			log.Printf("Warning: Failed to execute schema: %v", err)
+	// "ipam migrate ..." manages the schema and exits without serving.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
 		}
-	} else {
-		log.Printf("Warning: Could not read schema.sql: %v", err)
+		return
 	}
 
+	// Apply pending schema migrations. An advisory lock makes this safe when
+	// several replicas start at the same time.
+	applied, err := database.MigrateUp(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+
+	// Return expired allocations and reservations to the pool in the background.
+	go handlers.RunExpiryWorker(context.Background())
+
 	mux := http.NewServeMux()
 
 	// Static Files - Register more specific patterns first or use exact matches where possible
@@ -56,7 +66,179 @@ func main() {
 	mux.HandleFunc("DELETE /subnets/{id}", handlers.HandleDeleteSubnet)
 
 	mux.HandleFunc("GET /subnets/{id}", handlers.HandleSubnetDetail)
+	mux.HandleFunc("GET /subnets/{id}/map", handlers.HandleSubnetMap)
+	mux.HandleFunc("GET /subnets/{id}/history", handlers.HandleSubnetHistory)
+	mux.HandleFunc("POST /subnets/{id}/carve", handlers.HandleCarveSubnet)
+	mux.HandleFunc("POST /subnets/{id}/fields", handlers.HandleUpdateSubnetFields)
 	mux.HandleFunc("POST /subnets/{id}/ips", handlers.HandleAllocateIP)
+	mux.HandleFunc("POST /subnets/{id}/ips/next", handlers.HandleAllocateNextIP)
+	mux.HandleFunc("POST /subnets/{id}/ranges", handlers.HandleCreateRange)
+	mux.HandleFunc("DELETE /subnets/{id}/ranges/{rangeID}", handlers.HandleDeleteRange)
+
+	// CSV exports with the same filters as the subnet list and the IP table.
+	mux.HandleFunc("GET /subnets.csv", handlers.HandleExportSubnets)
+	mux.HandleFunc("GET /subnets/{id}/ips.csv", handlers.HandleExportIPs)
+
+	// Bulk tagging of the rows selected on the subnet list and in the IP table.
+	mux.HandleFunc("POST /subnets/tags", handlers.HandleBulkTagSubnets)
+	mux.HandleFunc("POST /subnets/{id}/ips/tags", handlers.HandleBulkTagIPs)
+
+	// Inline row actions in the IP table; each returns the re-rendered row.
+	mux.HandleFunc("GET /subnets/{id}/ips/{address}", handlers.HandleIPRow)
+	mux.HandleFunc("GET /subnets/{id}/ips/{address}/edit", handlers.HandleEditIPRow)
+	mux.HandleFunc("PATCH /subnets/{id}/ips/{address}", handlers.HandleUpdateIP)
+	mux.HandleFunc("POST /subnets/{id}/ips/{address}/reserve", handlers.HandleReserveIP)
+	mux.HandleFunc("POST /subnets/{id}/ips/{address}/unreserve", handlers.HandleUnreserveIP)
+	mux.HandleFunc("POST /subnets/{id}/ips/{address}/release", handlers.HandleReleaseIP)
+	mux.HandleFunc("POST /subnets/{id}/ips/{address}/extend", handlers.HandleExtendIP)
+	mux.HandleFunc("GET /subnets/{id}/ips/{address}/history", handlers.HandleIPHistory)
+	mux.HandleFunc("GET /expiring", handlers.HandleExpiringList)
+	mux.HandleFunc("GET /changelog", handlers.HandleChangelog)
+
+	mux.HandleFunc("GET /sites", handlers.HandleSiteList)
+	mux.HandleFunc("POST /regions", handlers.HandleCreateRegion)
+	mux.HandleFunc("DELETE /regions/{id}", handlers.HandleDeleteRegion)
+	mux.HandleFunc("POST /sites", handlers.HandleCreateSite)
+	mux.HandleFunc("DELETE /sites/{id}", handlers.HandleDeleteSite)
+	mux.HandleFunc("POST /sites/{id}/locations", handlers.HandleCreateLocation)
+	mux.HandleFunc("DELETE /locations/{id}", handlers.HandleDeleteLocation)
+
+	mux.HandleFunc("GET /vrfs", handlers.HandleVRFList)
+	mux.HandleFunc("POST /vrfs", handlers.HandleCreateVRF)
+	mux.HandleFunc("DELETE /vrfs/{id}", handlers.HandleDeleteVRF)
+
+	mux.HandleFunc("GET /devices", handlers.HandleDeviceList)
+	mux.HandleFunc("POST /devices", handlers.HandleCreateDevice)
+	mux.HandleFunc("GET /devices/{id}", handlers.HandleDeviceDetail)
+	mux.HandleFunc("PATCH /devices/{id}", handlers.HandleUpdateDevice)
+	mux.HandleFunc("DELETE /devices/{id}", handlers.HandleDeleteDevice)
+	mux.HandleFunc("POST /devices/{id}/primary", handlers.HandleSetPrimaryIP)
+	mux.HandleFunc("POST /devices/{id}/interfaces", handlers.HandleCreateInterface)
+	mux.HandleFunc("DELETE /devices/{id}/interfaces/{interfaceID}", handlers.HandleDeleteInterface)
+	mux.HandleFunc("GET /mac-addresses", handlers.HandleMACSearch)
+	mux.HandleFunc("GET /search", handlers.HandleSearch)
+
+	mux.HandleFunc("GET /tenants", handlers.HandleTenantList)
+	mux.HandleFunc("POST /tenants", handlers.HandleCreateTenant)
+	mux.HandleFunc("DELETE /tenants/{id}", handlers.HandleDeleteTenant)
+
+	mux.HandleFunc("GET /tags", handlers.HandleTagList)
+	mux.HandleFunc("POST /tags", handlers.HandleCreateTag)
+	mux.HandleFunc("DELETE /tags/{id}", handlers.HandleDeleteTag)
+
+	mux.HandleFunc("GET /custom-fields", handlers.HandleCustomFieldList)
+	mux.HandleFunc("POST /custom-fields", handlers.HandleCreateCustomField)
+	mux.HandleFunc("DELETE /custom-fields/{id}", handlers.HandleDeleteCustomField)
+
+	mux.HandleFunc("GET /vlans", handlers.HandleVLANList)
+	mux.HandleFunc("POST /vlans", handlers.HandleCreateVLAN)
+	mux.HandleFunc("POST /vlan-groups", handlers.HandleCreateVLANGroup)
+	mux.HandleFunc("GET /vlans/{id}", handlers.HandleVLANDetail)
+	mux.HandleFunc("PATCH /vlans/{id}", handlers.HandleUpdateVLAN)
+	mux.HandleFunc("DELETE /vlans/{id}", handlers.HandleDeleteVLAN)
+	mux.HandleFunc("POST /vlans/{id}/subnets", handlers.HandleLinkVLANSubnet)
+	mux.HandleFunc("DELETE /vlans/{id}/subnets/{subnetID}", handlers.HandleUnlinkVLANSubnet)
+
+	// JSON API
+	mux.HandleFunc("GET /api/v1/subnets", handlers.HandleAPIListSubnets)
+	mux.HandleFunc("POST /api/v1/subnets", handlers.HandleAPICreateSubnet)
+	mux.HandleFunc("GET /api/v1/subnets/{id}", handlers.HandleAPIGetSubnet)
+	mux.HandleFunc("PATCH /api/v1/subnets/{id}", handlers.HandleAPIUpdateSubnet)
+	mux.HandleFunc("DELETE /api/v1/subnets/{id}", handlers.HandleAPIDeleteSubnet)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/carve", handlers.HandleAPICarveSubnet)
+
+	mux.HandleFunc("GET /api/v1/subnets/{id}/ips", handlers.HandleAPIListIPs)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ips", handlers.HandleAPIAllocateIP)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ips/next", handlers.HandleAPIAllocateNextIP)
+	mux.HandleFunc("GET /api/v1/subnets/{id}/ips/{address}", handlers.HandleAPIGetIP)
+	mux.HandleFunc("PATCH /api/v1/subnets/{id}/ips/{address}", handlers.HandleAPIUpdateIP)
+	mux.HandleFunc("DELETE /api/v1/subnets/{id}/ips/{address}", handlers.HandleAPIReleaseIP)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ips/{address}/reserve", handlers.HandleAPIReserveIP)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ips/{address}/unreserve", handlers.HandleAPIUnreserveIP)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ips/{address}/release", handlers.HandleAPIReleaseIPAction)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ips/{address}/extend", handlers.HandleAPIExtendIP)
+	mux.HandleFunc("GET /api/v1/subnets/{id}/ips/{address}/history", handlers.HandleAPIIPHistory)
+	mux.HandleFunc("GET /api/v1/subnets/{id}/state", handlers.HandleAPISubnetState)
+	mux.HandleFunc("GET /api/v1/ips/expiring", handlers.HandleAPIListExpiringIPs)
+	mux.HandleFunc("GET /api/v1/ips/expirations", handlers.HandleAPIListExpirations)
+	mux.HandleFunc("GET /api/v1/ips/{address}/history", handlers.HandleAPIAddressHistory)
+	mux.HandleFunc("GET /api/v1/audit", handlers.HandleAPIListAudit)
+	mux.HandleFunc("GET /api/v1/subnets/{id}/ranges", handlers.HandleAPIListRanges)
+	mux.HandleFunc("POST /api/v1/subnets/{id}/ranges", handlers.HandleAPICreateRange)
+	mux.HandleFunc("GET /api/v1/ranges/{id}", handlers.HandleAPIGetRange)
+	mux.HandleFunc("PATCH /api/v1/ranges/{id}", handlers.HandleAPIUpdateRange)
+	mux.HandleFunc("DELETE /api/v1/ranges/{id}", handlers.HandleAPIDeleteRange)
+
+	mux.HandleFunc("GET /api/v1/regions", handlers.HandleAPIListRegions)
+	mux.HandleFunc("POST /api/v1/regions", handlers.HandleAPICreateRegion)
+	mux.HandleFunc("GET /api/v1/regions/{id}", handlers.HandleAPIGetRegion)
+	mux.HandleFunc("PATCH /api/v1/regions/{id}", handlers.HandleAPIUpdateRegion)
+	mux.HandleFunc("DELETE /api/v1/regions/{id}", handlers.HandleAPIDeleteRegion)
+
+	mux.HandleFunc("GET /api/v1/sites", handlers.HandleAPIListSites)
+	mux.HandleFunc("POST /api/v1/sites", handlers.HandleAPICreateSite)
+	mux.HandleFunc("GET /api/v1/sites/{id}", handlers.HandleAPIGetSite)
+	mux.HandleFunc("PATCH /api/v1/sites/{id}", handlers.HandleAPIUpdateSite)
+	mux.HandleFunc("DELETE /api/v1/sites/{id}", handlers.HandleAPIDeleteSite)
+
+	mux.HandleFunc("GET /api/v1/locations", handlers.HandleAPIListLocations)
+	mux.HandleFunc("POST /api/v1/locations", handlers.HandleAPICreateLocation)
+	mux.HandleFunc("GET /api/v1/locations/{id}", handlers.HandleAPIGetLocation)
+	mux.HandleFunc("PATCH /api/v1/locations/{id}", handlers.HandleAPIUpdateLocation)
+	mux.HandleFunc("DELETE /api/v1/locations/{id}", handlers.HandleAPIDeleteLocation)
+
+	mux.HandleFunc("GET /api/v1/vrfs", handlers.HandleAPIListVRFs)
+	mux.HandleFunc("POST /api/v1/vrfs", handlers.HandleAPICreateVRF)
+	mux.HandleFunc("GET /api/v1/vrfs/{id}", handlers.HandleAPIGetVRF)
+	mux.HandleFunc("PATCH /api/v1/vrfs/{id}", handlers.HandleAPIUpdateVRF)
+	mux.HandleFunc("DELETE /api/v1/vrfs/{id}", handlers.HandleAPIDeleteVRF)
+
+	mux.HandleFunc("GET /api/v1/devices", handlers.HandleAPIListDevices)
+	mux.HandleFunc("POST /api/v1/devices", handlers.HandleAPICreateDevice)
+	mux.HandleFunc("GET /api/v1/devices/{id}", handlers.HandleAPIGetDevice)
+	mux.HandleFunc("PATCH /api/v1/devices/{id}", handlers.HandleAPIUpdateDevice)
+	mux.HandleFunc("DELETE /api/v1/devices/{id}", handlers.HandleAPIDeleteDevice)
+	mux.HandleFunc("GET /api/v1/devices/{id}/ips", handlers.HandleAPIListDeviceIPs)
+	mux.HandleFunc("POST /api/v1/devices/{id}/interfaces", handlers.HandleAPICreateInterface)
+	mux.HandleFunc("GET /api/v1/interfaces/{id}", handlers.HandleAPIGetInterface)
+	mux.HandleFunc("PATCH /api/v1/interfaces/{id}", handlers.HandleAPIUpdateInterface)
+	mux.HandleFunc("DELETE /api/v1/interfaces/{id}", handlers.HandleAPIDeleteInterface)
+	mux.HandleFunc("GET /api/v1/mac-addresses", handlers.HandleAPISearchMACs)
+	mux.HandleFunc("GET /api/v1/mac-addresses/duplicates", handlers.HandleAPIListDuplicateMACs)
+	mux.HandleFunc("GET /api/v1/search", handlers.HandleAPISearch)
+
+	mux.HandleFunc("GET /api/v1/tenants", handlers.HandleAPIListTenants)
+	mux.HandleFunc("POST /api/v1/tenants", handlers.HandleAPICreateTenant)
+	mux.HandleFunc("GET /api/v1/tenants/usage", handlers.HandleAPITenantUsage)
+	mux.HandleFunc("GET /api/v1/tenants/{id}", handlers.HandleAPIGetTenant)
+	mux.HandleFunc("PATCH /api/v1/tenants/{id}", handlers.HandleAPIUpdateTenant)
+	mux.HandleFunc("DELETE /api/v1/tenants/{id}", handlers.HandleAPIDeleteTenant)
+	mux.HandleFunc("GET /api/v1/tags", handlers.HandleAPIListTags)
+	mux.HandleFunc("POST /api/v1/tags", handlers.HandleAPICreateTag)
+	mux.HandleFunc("GET /api/v1/tags/{id}", handlers.HandleAPIGetTag)
+	mux.HandleFunc("PATCH /api/v1/tags/{id}", handlers.HandleAPIUpdateTag)
+	mux.HandleFunc("DELETE /api/v1/tags/{id}", handlers.HandleAPIDeleteTag)
+	mux.HandleFunc("POST /api/v1/tags/{id}/tag", handlers.HandleAPITagObjects)
+	mux.HandleFunc("POST /api/v1/tags/{id}/untag", handlers.HandleAPIUntagObjects)
+	mux.HandleFunc("GET /api/v1/custom-fields", handlers.HandleAPIListCustomFields)
+	mux.HandleFunc("POST /api/v1/custom-fields", handlers.HandleAPICreateCustomField)
+	mux.HandleFunc("GET /api/v1/custom-fields/{id}", handlers.HandleAPIGetCustomField)
+	mux.HandleFunc("PATCH /api/v1/custom-fields/{id}", handlers.HandleAPIUpdateCustomField)
+	mux.HandleFunc("DELETE /api/v1/custom-fields/{id}", handlers.HandleAPIDeleteCustomField)
+
+	mux.HandleFunc("GET /api/v1/vlan-groups", handlers.HandleAPIListVLANGroups)
+	mux.HandleFunc("POST /api/v1/vlan-groups", handlers.HandleAPICreateVLANGroup)
+	mux.HandleFunc("GET /api/v1/vlan-groups/{id}", handlers.HandleAPIGetVLANGroup)
+	mux.HandleFunc("PATCH /api/v1/vlan-groups/{id}", handlers.HandleAPIUpdateVLANGroup)
+	mux.HandleFunc("DELETE /api/v1/vlan-groups/{id}", handlers.HandleAPIDeleteVLANGroup)
+
+	mux.HandleFunc("GET /api/v1/vlans", handlers.HandleAPIListVLANs)
+	mux.HandleFunc("POST /api/v1/vlans", handlers.HandleAPICreateVLAN)
+	mux.HandleFunc("GET /api/v1/vlans/{id}", handlers.HandleAPIGetVLAN)
+	mux.HandleFunc("PATCH /api/v1/vlans/{id}", handlers.HandleAPIUpdateVLAN)
+	mux.HandleFunc("DELETE /api/v1/vlans/{id}", handlers.HandleAPIDeleteVLAN)
+
+	mux.HandleFunc("/api/v1/", handlers.HandleAPINotFound)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -64,7 +246,9 @@ func main() {
 	}
 
 	fmt.Printf("Server starting on port %s\n", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
+	// Changes are recorded in the audit log with the user, request ID and
+	// client address of the request making them.
+	if err := http.ListenAndServe(":"+port, handlers.WithAuditSource(mux)); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLSTATE codes that handlers translate into client errors.
+const (
+	ForeignKeyViolation       = "23503"
+	UniqueViolation           = "23505"
+	CheckViolation            = "23514"
+	ExclusionViolation        = "23P01"
+	InvalidTextRepresentation = "22P02"
+)
+
+// ErrorCode returns the SQLSTATE of a PostgreSQL error, or "" if err is not one.
+func ErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
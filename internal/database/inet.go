@@ -0,0 +1,39 @@
+package database
+
+import (
+	"net/netip"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CIDR returns a scan target that stores a cidr column into dst in canonical
+// CIDR notation (RFC 5952 for IPv6), e.g. "2001:db8::/64".
+func CIDR(dst *string) pgtype.NetipPrefixScanner {
+	return cidrText{dst}
+}
+
+// Addr returns a scan target that stores a host inet column into dst as a bare
+// address in canonical form, e.g. "10.0.0.1" rather than "10.0.0.1/32".
+func Addr(dst *string) pgtype.NetipPrefixScanner {
+	return addrText{dst}
+}
+
+type cidrText struct{ dst *string }
+
+func (t cidrText) ScanNetipPrefix(v netip.Prefix) error {
+	*t.dst = ""
+	if v.IsValid() {
+		*t.dst = v.Masked().String()
+	}
+	return nil
+}
+
+type addrText struct{ dst *string }
+
+func (t addrText) ScanNetipPrefix(v netip.Prefix) error {
+	*t.dst = ""
+	if v.IsValid() {
+		*t.dst = v.Addr().String()
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS subnets_check_ips_inside ON subnets;
+DROP TRIGGER IF EXISTS ips_check_in_subnet ON ips;
+DROP FUNCTION IF EXISTS subnets_check_ips_inside();
+DROP FUNCTION IF EXISTS ips_check_in_subnet();
+DROP TABLE IF EXISTS ips;
+DROP TABLE IF EXISTS subnets;
@@ -0,0 +1,85 @@
+-- Baseline schema. Written idempotently so databases created by the old
+-- schema.sql start-up script can adopt the migration history in place.
+
+CREATE TABLE IF NOT EXISTS subnets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    cidr CIDR NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- Subnets may not overlap. The GiST index behind this constraint also
+    -- serves containment lookups such as "which subnet holds this address" (cidr >>= addr).
+    CONSTRAINT subnets_cidr_excl EXCLUDE USING gist (cidr inet_ops WITH &&)
+);
+
+CREATE TABLE IF NOT EXISTS ips (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subnet_id UUID NOT NULL REFERENCES subnets(id) ON DELETE CASCADE,
+    address INET NOT NULL,
+    status TEXT NOT NULL DEFAULT 'available', -- available, reserved, allocated
+    hostname TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(subnet_id, address),
+    -- Rows hold single host addresses, never networks.
+    CONSTRAINT ips_address_host CHECK (masklen(address) = CASE family(address) WHEN 4 THEN 32 ELSE 128 END)
+);
+
+-- Available addresses are computed from the subnet CIDR; rows exist only for
+-- addresses that carry state. Drop placeholder rows left by older versions.
+DELETE FROM ips WHERE status = 'available' AND hostname IS NULL;
+
+-- Upgrade databases created when cidr/address were stored as TEXT.
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'subnets' AND column_name = 'cidr') = 'text' THEN
+        -- network() clears host bits, e.g. '10.0.0.5/24' becomes 10.0.0.0/24.
+        ALTER TABLE subnets ALTER COLUMN cidr TYPE CIDR USING network(cidr::inet);
+        ALTER TABLE subnets DROP CONSTRAINT IF EXISTS subnets_cidr_key;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'subnets_cidr_excl') THEN
+        ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_excl EXCLUDE USING gist (cidr inet_ops WITH &&);
+    END IF;
+
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'ips' AND column_name = 'address') = 'text' THEN
+        DROP INDEX IF EXISTS ips_subnet_address_idx;
+        ALTER TABLE ips ALTER COLUMN address TYPE INET USING address::inet;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'ips_address_host') THEN
+        ALTER TABLE ips ADD CONSTRAINT ips_address_host
+            CHECK (masklen(address) = CASE family(address) WHEN 4 THEN 32 ELSE 128 END);
+    END IF;
+END $$;
+
+-- Supports "which IPs fall inside this prefix" lookups (address <<= prefix).
+CREATE INDEX IF NOT EXISTS ips_address_idx ON ips USING gist (address inet_ops);
+
+-- Every IP row must fall inside its parent subnet. A CHECK constraint cannot
+-- look at another table, so both sides are enforced with triggers.
+CREATE OR REPLACE FUNCTION ips_check_in_subnet() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subnets WHERE id = NEW.subnet_id AND cidr >>= NEW.address) THEN
+        RAISE EXCEPTION 'address % is outside subnet %', host(NEW.address), NEW.subnet_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER ips_check_in_subnet
+    BEFORE INSERT OR UPDATE OF subnet_id, address ON ips
+    FOR EACH ROW EXECUTE FUNCTION ips_check_in_subnet();
+
+CREATE OR REPLACE FUNCTION subnets_check_ips_inside() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ips WHERE subnet_id = NEW.id AND NOT NEW.cidr >>= address) THEN
+        RAISE EXCEPTION 'subnet % would no longer contain all of its IP addresses', NEW.cidr
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER subnets_check_ips_inside
+    BEFORE UPDATE OF cidr ON subnets
+    FOR EACH ROW EXECUTE FUNCTION subnets_check_ips_inside();
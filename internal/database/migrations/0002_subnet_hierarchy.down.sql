@@ -0,0 +1,33 @@
+-- Nested subnets cannot be represented once overlaps are forbidden again, so
+-- containers are removed; their children are kept.
+DELETE FROM subnets WHERE kind = 'container';
+
+CREATE OR REPLACE TRIGGER subnets_check_ips_inside
+    BEFORE UPDATE OF cidr ON subnets
+    FOR EACH ROW EXECUTE FUNCTION subnets_check_ips_inside();
+
+CREATE OR REPLACE FUNCTION subnets_check_ips_inside() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ips WHERE subnet_id = NEW.id AND NOT NEW.cidr >>= address) THEN
+        RAISE EXCEPTION 'subnet % would no longer contain all of its IP addresses', NEW.cidr
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION ips_check_in_subnet() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subnets WHERE id = NEW.subnet_id AND cidr >>= NEW.address) THEN
+        RAISE EXCEPTION 'address % is outside subnet %', host(NEW.address), NEW.subnet_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS subnets_cidr_idx;
+ALTER TABLE subnets DROP CONSTRAINT subnets_cidr_excl;
+ALTER TABLE subnets DROP CONSTRAINT subnets_cidr_key;
+ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_excl EXCLUDE USING gist (cidr inet_ops WITH &&);
+ALTER TABLE subnets DROP COLUMN kind;
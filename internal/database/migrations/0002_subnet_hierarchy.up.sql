@@ -0,0 +1,45 @@
+-- Subnets nest by CIDR containment. A "container" holds child subnets but no
+-- host addresses; a "network" holds host addresses but no child subnets.
+ALTER TABLE subnets ADD COLUMN kind TEXT NOT NULL DEFAULT 'network'
+    CONSTRAINT subnets_kind_check CHECK (kind IN ('network', 'container'));
+
+-- Networks still may not overlap each other. Containers may enclose networks
+-- and other containers; the application checks the remaining nesting rules
+-- while holding a lock on the table.
+ALTER TABLE subnets DROP CONSTRAINT subnets_cidr_excl;
+ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_key UNIQUE (cidr);
+ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_excl
+    EXCLUDE USING gist (cidr inet_ops WITH &&) WHERE (kind = 'network');
+CREATE INDEX subnets_cidr_idx ON subnets USING gist (cidr inet_ops);
+
+CREATE OR REPLACE FUNCTION ips_check_in_subnet() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subnets WHERE id = NEW.subnet_id AND cidr >>= NEW.address) THEN
+        RAISE EXCEPTION 'address % is outside subnet %', host(NEW.address), NEW.subnet_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    IF EXISTS (SELECT 1 FROM subnets WHERE id = NEW.subnet_id AND kind = 'container') THEN
+        RAISE EXCEPTION 'subnet % is a container and holds no host addresses', NEW.subnet_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION subnets_check_ips_inside() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ips WHERE subnet_id = NEW.id AND NOT NEW.cidr >>= address) THEN
+        RAISE EXCEPTION 'subnet % would no longer contain all of its IP addresses', NEW.cidr
+            USING ERRCODE = 'check_violation';
+    END IF;
+    IF NEW.kind = 'container' AND EXISTS (SELECT 1 FROM ips WHERE subnet_id = NEW.id) THEN
+        RAISE EXCEPTION 'subnet % has IP addresses and cannot become a container', NEW.cidr
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER subnets_check_ips_inside
+    BEFORE UPDATE OF cidr, kind ON subnets
+    FOR EACH ROW EXECUTE FUNCTION subnets_check_ips_inside();
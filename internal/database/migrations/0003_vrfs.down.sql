@@ -0,0 +1,11 @@
+-- Subnets in VRFs may overlap the global table, so they cannot be kept.
+DELETE FROM subnets WHERE vrf_id IS NOT NULL;
+
+ALTER TABLE subnets DROP CONSTRAINT subnets_cidr_excl;
+ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_excl
+    EXCLUDE USING gist (cidr inet_ops WITH &&) WHERE (kind = 'network');
+ALTER TABLE subnets DROP CONSTRAINT subnets_vrf_cidr_key;
+ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_key UNIQUE (cidr);
+ALTER TABLE subnets DROP COLUMN vrf_id;
+
+DROP TABLE vrfs;
@@ -0,0 +1,24 @@
+-- VRFs (routing domains) let the same address space be used more than once,
+-- e.g. two customers both numbering 192.168.1.0/24. Subnets without a VRF
+-- belong to the global table.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE vrfs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CONSTRAINT vrfs_name_key UNIQUE,
+    rd TEXT CONSTRAINT vrfs_rd_key UNIQUE, -- route distinguisher, e.g. 65000:100
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE subnets ADD COLUMN vrf_id UUID REFERENCES vrfs(id) ON DELETE RESTRICT;
+
+-- Uniqueness and overlap now apply per VRF. NULL (the global table) is
+-- treated as one more VRF.
+ALTER TABLE subnets DROP CONSTRAINT subnets_cidr_key;
+ALTER TABLE subnets ADD CONSTRAINT subnets_vrf_cidr_key UNIQUE NULLS NOT DISTINCT (vrf_id, cidr);
+ALTER TABLE subnets DROP CONSTRAINT subnets_cidr_excl;
+ALTER TABLE subnets ADD CONSTRAINT subnets_cidr_excl EXCLUDE USING gist (
+    (COALESCE(vrf_id, '00000000-0000-0000-0000-000000000000'::uuid)) WITH =,
+    cidr inet_ops WITH &&
+) WHERE (kind = 'network');
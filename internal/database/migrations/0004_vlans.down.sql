@@ -0,0 +1,4 @@
+ALTER TABLE subnets DROP COLUMN vlan_id;
+
+DROP TABLE vlans;
+DROP TABLE vlan_groups;
@@ -0,0 +1,25 @@
+-- VLANs record which layer-2 segment a subnet lives on. A VID is unique
+-- within its VLAN group (typically one switching domain); VLANs outside any
+-- group share one more, implicit group.
+CREATE TABLE vlan_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CONSTRAINT vlan_groups_name_key UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE vlans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    vid INTEGER NOT NULL CONSTRAINT vlans_vid_check CHECK (vid BETWEEN 1 AND 4094),
+    name TEXT NOT NULL,
+    group_id UUID REFERENCES vlan_groups(id) ON DELETE RESTRICT,
+    status TEXT NOT NULL DEFAULT 'active'
+        CONSTRAINT vlans_status_check CHECK (status IN ('active', 'reserved', 'deprecated')),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT vlans_group_vid_key UNIQUE NULLS NOT DISTINCT (group_id, vid)
+);
+
+-- Deleting a VLAN unlinks its subnets rather than deleting them.
+ALTER TABLE subnets ADD COLUMN vlan_id UUID REFERENCES vlans(id) ON DELETE SET NULL;
+CREATE INDEX subnets_vlan_id_idx ON subnets (vlan_id);
@@ -0,0 +1,6 @@
+ALTER TABLE vlans DROP COLUMN site_id;
+ALTER TABLE subnets DROP COLUMN site_id;
+
+DROP TABLE locations;
+DROP TABLE sites;
+DROP TABLE regions;
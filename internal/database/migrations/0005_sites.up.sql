@@ -0,0 +1,29 @@
+-- Regions contain sites, and sites contain locations (rooms, floors,
+-- cages). Subnets and VLANs are assigned to a site.
+CREATE TABLE regions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CONSTRAINT regions_name_key UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE sites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    region_id UUID NOT NULL REFERENCES regions(id) ON DELETE RESTRICT,
+    name TEXT NOT NULL CONSTRAINT sites_name_key UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE locations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    site_id UUID NOT NULL REFERENCES sites(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT locations_site_name_key UNIQUE (site_id, name)
+);
+
+ALTER TABLE subnets ADD COLUMN site_id UUID REFERENCES sites(id) ON DELETE RESTRICT;
+CREATE INDEX subnets_site_id_idx ON subnets (site_id);
+ALTER TABLE vlans ADD COLUMN site_id UUID REFERENCES sites(id) ON DELETE RESTRICT;
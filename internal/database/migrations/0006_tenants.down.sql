@@ -0,0 +1,4 @@
+ALTER TABLE ips DROP COLUMN tenant_id;
+ALTER TABLE subnets DROP COLUMN tenant_id;
+
+DROP TABLE tenants;
@@ -0,0 +1,13 @@
+-- Tenants own subnets and individual addresses. An address without a tenant
+-- of its own belongs to the tenant of its subnet.
+CREATE TABLE tenants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CONSTRAINT tenants_name_key UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE subnets ADD COLUMN tenant_id UUID REFERENCES tenants(id) ON DELETE RESTRICT;
+CREATE INDEX subnets_tenant_id_idx ON subnets (tenant_id);
+ALTER TABLE ips ADD COLUMN tenant_id UUID REFERENCES tenants(id) ON DELETE RESTRICT;
+CREATE INDEX ips_tenant_id_idx ON ips (tenant_id);
@@ -0,0 +1,3 @@
+DROP TABLE ip_tags;
+DROP TABLE subnet_tags;
+DROP TABLE tags;
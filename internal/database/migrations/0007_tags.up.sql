@@ -0,0 +1,25 @@
+-- Tags label subnets and addresses, e.g. "prod" or "k8s-node". Colors are
+-- DaisyUI badge colors. Tags of an address go away with its row when the
+-- address is released.
+CREATE TABLE tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CONSTRAINT tags_name_key UNIQUE,
+    color TEXT NOT NULL DEFAULT 'neutral' CONSTRAINT tags_color_check
+        CHECK (color IN ('neutral', 'primary', 'secondary', 'accent', 'info', 'success', 'warning', 'error')),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE subnet_tags (
+    subnet_id UUID NOT NULL REFERENCES subnets(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (subnet_id, tag_id)
+);
+CREATE INDEX subnet_tags_tag_id_idx ON subnet_tags (tag_id);
+
+CREATE TABLE ip_tags (
+    ip_id UUID NOT NULL REFERENCES ips(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (ip_id, tag_id)
+);
+CREATE INDEX ip_tags_tag_id_idx ON ip_tags (tag_id);
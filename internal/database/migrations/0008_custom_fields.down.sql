@@ -0,0 +1,3 @@
+ALTER TABLE ips DROP COLUMN custom_fields;
+ALTER TABLE subnets DROP COLUMN custom_fields;
+DROP TABLE custom_fields;
@@ -0,0 +1,19 @@
+-- Custom fields are admin-defined attributes of subnets or addresses, e.g. an
+-- owner email or a ticket number. Values are stored per object in a JSONB
+-- column keyed by field name and are validated against the definition on write.
+CREATE TABLE custom_fields (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    object_type TEXT NOT NULL CONSTRAINT custom_fields_object_type_check
+        CHECK (object_type IN ('subnet', 'ip')),
+    name TEXT NOT NULL CONSTRAINT custom_fields_name_check CHECK (name ~ '^[a-z][a-z0-9_]*$'),
+    label TEXT NOT NULL,
+    type TEXT NOT NULL CONSTRAINT custom_fields_type_check
+        CHECK (type IN ('text', 'integer', 'boolean', 'date', 'enum', 'url')),
+    choices TEXT[] NOT NULL DEFAULT '{}',
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT custom_fields_object_type_name_key UNIQUE (object_type, name)
+);
+
+ALTER TABLE subnets ADD COLUMN custom_fields JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE ips ADD COLUMN custom_fields JSONB NOT NULL DEFAULT '{}';
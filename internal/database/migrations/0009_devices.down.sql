@@ -0,0 +1,4 @@
+ALTER TABLE devices DROP COLUMN primary_ip_id;
+ALTER TABLE ips DROP COLUMN interface_id;
+DROP TABLE interfaces;
+DROP TABLE devices;
@@ -0,0 +1,32 @@
+-- Devices (routers, switches, servers) have interfaces, and addresses are
+-- assigned to an interface. A device may name one of its addresses as its
+-- primary IP.
+CREATE TABLE devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CONSTRAINT devices_name_key UNIQUE,
+    role TEXT NOT NULL DEFAULT '',
+    platform TEXT NOT NULL DEFAULT '',
+    site_id UUID REFERENCES sites(id) ON DELETE RESTRICT,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX devices_site_id_idx ON devices (site_id);
+
+-- MAC addresses are stored in lowercase colon-separated form.
+CREATE TABLE interfaces (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT 'ethernet'
+        CONSTRAINT interfaces_type_check CHECK (type IN ('ethernet', 'virtual', 'lag', 'wireless', 'loopback')),
+    mac_address TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT interfaces_device_name_key UNIQUE (device_id, name)
+);
+
+-- Deleting an interface keeps its addresses, unassigned.
+ALTER TABLE ips ADD COLUMN interface_id UUID REFERENCES interfaces(id) ON DELETE SET NULL;
+CREATE INDEX ips_interface_id_idx ON ips (interface_id);
+
+-- Releasing the primary IP deletes its row and so clears the designation.
+ALTER TABLE devices ADD COLUMN primary_ip_id UUID REFERENCES ips(id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+ALTER TABLE ips DROP COLUMN mac_address;
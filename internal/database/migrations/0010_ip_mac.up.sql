@@ -0,0 +1,5 @@
+-- The MAC address an address is bound to, e.g. from a DHCP lease, in
+-- lowercase colon-separated form. The same MAC on several addresses is
+-- allowed but reported as a conflict.
+ALTER TABLE ips ADD COLUMN mac_address TEXT;
+CREATE INDEX ips_mac_address_idx ON ips (mac_address);
@@ -0,0 +1,5 @@
+DROP TRIGGER subnets_check_ranges_inside ON subnets;
+DROP FUNCTION subnets_check_ranges_inside();
+DROP TABLE ip_ranges;
+DROP FUNCTION ip_ranges_check_in_subnet();
+DROP TYPE inetrange;
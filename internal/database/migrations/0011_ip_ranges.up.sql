@@ -0,0 +1,51 @@
+-- Ranges set contiguous addresses of a network aside for a purpose, e.g. a
+-- DHCP pool or a block for static assignments. Bounds are inclusive host
+-- addresses; the ranges of a subnet must not overlap.
+CREATE TYPE inetrange AS RANGE (subtype = inet);
+
+CREATE TABLE ip_ranges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subnet_id UUID NOT NULL REFERENCES subnets(id) ON DELETE CASCADE,
+    start_address INET NOT NULL,
+    end_address INET NOT NULL,
+    role TEXT NOT NULL
+        CONSTRAINT ip_ranges_role_check CHECK (role IN ('dhcp', 'static', 'reserved')),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ip_ranges_bounds_check CHECK (family(start_address) = family(end_address) AND start_address <= end_address),
+    CONSTRAINT ip_ranges_overlap_excl EXCLUDE USING gist (
+        subnet_id WITH =,
+        inetrange(start_address, end_address, '[]') WITH &&
+    )
+);
+
+-- Like IP rows, ranges must stay inside their subnet.
+CREATE FUNCTION ip_ranges_check_in_subnet() RETURNS trigger AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM subnets WHERE id = NEW.subnet_id
+                   AND cidr >>= NEW.start_address AND cidr >>= NEW.end_address) THEN
+        RAISE EXCEPTION 'range %-% is outside subnet %', host(NEW.start_address), host(NEW.end_address), NEW.subnet_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ip_ranges_check_in_subnet
+    BEFORE INSERT OR UPDATE OF subnet_id, start_address, end_address ON ip_ranges
+    FOR EACH ROW EXECUTE FUNCTION ip_ranges_check_in_subnet();
+
+CREATE FUNCTION subnets_check_ranges_inside() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM ip_ranges WHERE subnet_id = NEW.id
+               AND NOT (NEW.cidr >>= start_address AND NEW.cidr >>= end_address)) THEN
+        RAISE EXCEPTION 'subnet % would no longer contain all of its ranges', NEW.cidr
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subnets_check_ranges_inside
+    BEFORE UPDATE OF cidr ON subnets
+    FOR EACH ROW EXECUTE FUNCTION subnets_check_ranges_inside();
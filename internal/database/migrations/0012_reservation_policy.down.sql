@@ -0,0 +1,4 @@
+ALTER TABLE ips DROP COLUMN reservation_reason;
+ALTER TABLE subnets DROP CONSTRAINT subnets_gateway_inside;
+ALTER TABLE subnets DROP COLUMN gateway;
+ALTER TABLE subnets DROP COLUMN reservation_policy;
@@ -0,0 +1,8 @@
+-- Addresses reserved automatically when a network is created. The subnet
+-- records the policy that was applied (e.g. 'gateway=first,last=1'; empty
+-- when none) and its gateway, and every address reserved by the policy
+-- records the term of the policy that reserved it, so the UI can explain why.
+ALTER TABLE subnets ADD COLUMN reservation_policy TEXT NOT NULL DEFAULT '';
+ALTER TABLE subnets ADD COLUMN gateway INET;
+ALTER TABLE subnets ADD CONSTRAINT subnets_gateway_inside CHECK (gateway IS NULL OR gateway <<= cidr);
+ALTER TABLE ips ADD COLUMN reservation_reason TEXT;
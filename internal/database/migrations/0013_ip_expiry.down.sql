@@ -0,0 +1,2 @@
+DROP TABLE ip_expirations;
+ALTER TABLE ips DROP COLUMN expires_at;
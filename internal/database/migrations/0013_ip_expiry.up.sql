@@ -0,0 +1,17 @@
+-- Allocations and reservations may be time-limited. A background job returns
+-- addresses to the pool once expires_at has passed; since available
+-- addresses are not stored, each release is recorded in ip_expirations.
+ALTER TABLE ips ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX ips_expires_at_idx ON ips (expires_at) WHERE expires_at IS NOT NULL;
+
+CREATE TABLE ip_expirations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subnet_id UUID NOT NULL REFERENCES subnets(id) ON DELETE CASCADE,
+    address INET NOT NULL,
+    status TEXT NOT NULL, -- allocated or reserved, before the release
+    hostname TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reason TEXT NOT NULL
+);
+CREATE INDEX ip_expirations_released_at_idx ON ip_expirations (released_at DESC);
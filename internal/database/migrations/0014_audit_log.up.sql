@@ -0,0 +1,33 @@
+-- Every change to a subnet, range, address or other object is appended to
+-- the audit log in the transaction making it. Entries keep the subnet ID
+-- without a foreign key so that they outlive the subnet, and the identity
+-- column orders the entries of one transaction, which share a timestamp.
+CREATE TABLE audit_log (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,       -- e.g. create, allocate, release, expire
+    object_type TEXT NOT NULL,  -- e.g. subnet, range, ip, vrf or device
+    object_id TEXT NOT NULL,    -- the UUID of an object, the address of an ip
+    object_label TEXT NOT NULL, -- the CIDR, range, address or name at the time
+    subnet_id UUID,             -- NULL for objects outside subnets
+    before JSONB,               -- NULL when the object was created
+    after JSONB,                -- NULL when the object was deleted
+    request_id TEXT NOT NULL DEFAULT '',
+    source_ip INET
+);
+CREATE INDEX audit_log_occurred_at_idx ON audit_log (occurred_at DESC);
+CREATE INDEX audit_log_subnet_idx ON audit_log (subnet_id, id DESC);
+CREATE INDEX audit_log_object_idx ON audit_log (object_type, object_id, id DESC);
+
+-- The log is append-only: entries cannot be changed or deleted.
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only'
+        USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
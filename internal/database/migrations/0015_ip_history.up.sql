@@ -0,0 +1,33 @@
+-- The history of an address is read from the audit log. Addresses stored
+-- before the log existed have no entries there, so their current state is
+-- recorded once as an import: from now on they show up in point-in-time
+-- queries. The snapshot has the fields of an address in the API.
+INSERT INTO audit_log (actor, action, object_type, object_id, object_label, subnet_id, after)
+SELECT 'system', 'import', 'ip', host(ips.address), host(ips.address), ips.subnet_id,
+    jsonb_build_object(
+        'id', ips.id,
+        'subnet_id', ips.subnet_id,
+        'address', host(ips.address),
+        'status', ips.status,
+        'hostname', ips.hostname,
+        'mac_address', ips.mac_address,
+        'mac_vendor', '',
+        'mac_conflicts', 0,
+        'tenant_id', ips.tenant_id,
+        'interface_id', ips.interface_id,
+        'device_id', (SELECT f.device_id FROM interfaces f WHERE f.id = ips.interface_id),
+        'tags', COALESCE((SELECT jsonb_agg(jsonb_build_object('id', t.id, 'name', t.name, 'color', t.color) ORDER BY t.name)
+                          FROM ip_tags x JOIN tags t ON t.id = x.tag_id WHERE x.ip_id = ips.id), '[]'),
+        'custom_fields', ips.custom_fields,
+        'reservation_reason', COALESCE(ips.reservation_reason, ''),
+        'expires_at', ips.expires_at,
+        'created_at', ips.created_at)
+FROM ips
+WHERE NOT EXISTS (
+    SELECT 1 FROM audit_log a
+    WHERE a.object_type = 'ip' AND a.subnet_id = ips.subnet_id AND a.object_id = host(ips.address)
+)
+ORDER BY ips.subnet_id, ips.address;
+
+-- Point-in-time queries look up the latest entry of each address of a subnet.
+CREATE INDEX audit_log_subnet_object_idx ON audit_log (subnet_id, object_type, object_id, id DESC);
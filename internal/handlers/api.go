@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// apiErrorBody is the JSON body of every error returned under /api/v1.
+//
+//	{"error": {"code": "subnet_not_found", "message": "Subnet not found"}}
+type apiErrorBody struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// listResponse wraps collections so that metadata can be added without
+// breaking clients.
+type listResponse[T any] struct {
+	Items []T `json:"items"`
+}
+
+// nullableUUID is a PATCH field referring to another object. Unlike a
+// pointer it tells an absent key (leave unchanged) from null (clear).
+type nullableUUID struct {
+	Set bool
+	ID  pgtype.UUID
+}
+
+func (n *nullableUUID) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	return n.ID.UnmarshalJSON(b)
+}
+
+// nullableTime is a PATCH field holding an optional time. Like nullableUUID
+// it tells an absent key (leave unchanged) from null (clear).
+type nullableTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (n *nullableTime) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	return json.Unmarshal(b, &n.Time)
+}
+
+// writeJSON encodes v as the response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// writeAPIError reports err as a JSON error body. Unexpected errors are
+// logged and reported as internal_error without details.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var ae *appError
+	if errors.As(err, &ae) {
+		writeJSON(w, ae.Status, apiErrorBody{apiErrorDetail{Code: ae.Code, Message: ae.Message}})
+		return
+	}
+	log.Printf("API error: %v", err)
+	writeJSON(w, http.StatusInternalServerError, apiErrorBody{apiErrorDetail{Code: "internal_error", Message: "Internal server error"}})
+}
+
+// decodeJSON reads the request body into v, rejecting unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &appError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "Invalid JSON body: " + err.Error()}
+	}
+	return nil
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints whose body may be omitted,
+// in which case v keeps its zero value.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	return decodeJSON(w, r, v)
+}
+
+// queryInt returns a positive integer query parameter, or def when absent or invalid.
+func queryInt(r *http.Request, name string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// HandleAPINotFound answers unknown /api/v1 routes with a JSON error.
+func HandleAPINotFound(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, errNotFound("route_not_found", "No API route for %s %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// auditListResponse is one page of the audit log.
+type auditListResponse struct {
+	Items    []models.AuditEntry `json:"items"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+	Total    int                 `json:"total"`
+}
+
+// HandleAPIListAudit handles GET /api/v1/audit, newest entries first.
+// Query parameters: type (one of models.AuditObjectTypes), object (an
+// object ID, or part of a CIDR, address or name), action, actor, subnet (a subnet ID; the
+// subnet, its ranges and its addresses), since and until (dates or RFC 3339
+// times), page, page_size.
+func HandleAPIListAudit(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilterFrom(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	page, pageSize := queryInt(r, "page", 1), min(queryInt(r, "page_size", auditPageSize), maxAPIPageSize)
+	entries, total, err := listAudit(r.Context(), f, page, pageSize)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, auditListResponse{Items: entries, Page: page, PageSize: pageSize, Total: total})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIAuditLog(t *testing.T) {
+	cleanDB(t)
+
+	// Create a subnet as alice through two trusted proxies
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/subnets", strings.NewReader(`{"cidr": "10.60.0.0/24", "name": "audited"}`))
+	req.Header.Set("X-Forwarded-User", "alice")
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	w := httptest.NewRecorder()
+	trusted := []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32"), netip.MustParsePrefix("10.0.0.0/8")}
+	auditSourceHandler(http.HandlerFunc(HandleAPICreateSubnet), trusted).ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Header().Get("X-Request-ID") != "req-1" {
+		t.Fatalf("create: got %d, request ID %q", w.Code, w.Header().Get("X-Request-ID"))
+	}
+	var s models.Subnet
+	decodeBody(t, w, &s)
+	id := s.ID.String()
+	base := "/api/v1/subnets/" + id + "/ips"
+
+	// Allocate, rename (twice, the second time without a change) and release an address
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.60.0.5", "hostname": "old"}`, "id", id)
+	for range 2 {
+		serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, base+"/10.60.0.5", `{"hostname": "new"}`, "id", id, "address", "10.60.0.5")
+	}
+	serveAPI(t, HandleAPIReleaseIP, http.MethodDelete, base+"/10.60.0.5", "", "id", id, "address", "10.60.0.5")
+
+	// Add a range and an address, then delete the subnet with them
+	serveAPI(t, HandleAPICreateRange, http.MethodPost, "/api/v1/subnets/"+id+"/ranges",
+		`{"start_address": "10.60.0.100", "end_address": "10.60.0.199", "role": "dhcp"}`, "id", id)
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.60.0.6"}`, "id", id)
+	if w := serveAPI(t, HandleAPIDeleteSubnet, http.MethodDelete, "/api/v1/subnets/"+id, "", "id", id); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", w.Code)
+	}
+
+	// The history of the subnet outlives it, newest first
+	w = serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?subnet="+id, "")
+	var list auditListResponse
+	decodeBody(t, w, &list)
+	var got []string
+	for _, e := range list.Items {
+		got = append(got, e.ObjectType+" "+e.Action)
+	}
+	want := []string{"subnet delete", "range delete", "ip delete", "ip allocate", "range create", "ip release", "ip update", "ip allocate", "subnet create"}
+	if w.Code != http.StatusOK || list.Total != len(want) || !slices.Equal(got, want) {
+		t.Fatalf("history: expected %v, got %d %v (total %d)", want, w.Code, got, list.Total)
+	}
+	if e := list.Items[0]; e.Before == nil || string(e.After) != "null" || e.ObjectLabel != "10.60.0.0/24" {
+		t.Errorf("subnet delete: expected a before snapshot only, got %+v", e)
+	}
+
+	// Filters
+	w = serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?type=ip&action=update", "")
+	decodeBody(t, w, &list)
+	if len(list.Items) != 1 {
+		t.Fatalf("filter by action: expected 1 entry, got %+v", list.Items)
+	}
+	var before, after models.IP
+	json.Unmarshal(list.Items[0].Before, &before)
+	json.Unmarshal(list.Items[0].After, &after)
+	if hostnameOf(before) != "old" || hostnameOf(after) != "new" || list.Items[0].ObjectID != "10.60.0.5" {
+		t.Errorf("update: expected hostname old -> new, got %+v", list.Items[0])
+	}
+	w = serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?actor=alice", "")
+	decodeBody(t, w, &list)
+	if len(list.Items) != 1 || list.Items[0].Action != "create" || list.Items[0].RequestID != "req-1" || list.Items[0].SourceIP != "203.0.113.7" {
+		t.Errorf("filter by actor: got %+v", list.Items)
+	}
+	w = serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?object=10.60.0.6&page_size=1", "")
+	decodeBody(t, w, &list)
+	if list.Total != 2 || len(list.Items) != 1 || list.Items[0].Action != "delete" {
+		t.Errorf("filter by object: got %d %+v", list.Total, list.Items)
+	}
+	w = serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?type=rack", "")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid type: expected 422, got %d", w.Code)
+	}
+
+	// The log is append-only
+	if _, err := database.DB.Exec(context.Background(), "DELETE FROM audit_log"); err == nil {
+		t.Error("deleting audit entries: expected an error")
+	}
+}
+
+func TestAPIAuditObjects(t *testing.T) {
+	cleanDB(t)
+	decodeID := func(w *httptest.ResponseRecorder) string {
+		t.Helper()
+		var obj struct {
+			ID string `json:"id"`
+		}
+		decodeBody(t, w, &obj)
+		return obj.ID
+	}
+	audit := func(query string) []models.AuditEntry {
+		t.Helper()
+		w := serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("audit %s: expected 200, got %d: %s", query, w.Code, w.Body)
+		}
+		var list auditListResponse
+		decodeBody(t, w, &list)
+		return list.Items
+	}
+
+	// Objects outside subnets are recorded too
+	tid := decodeID(serveAPI(t, HandleAPICreateTenant, http.MethodPost, "/api/v1/tenants", `{"name": "acme"}`))
+	serveAPI(t, HandleAPIUpdateTenant, http.MethodPatch, "/api/v1/tenants/"+tid, `{"name": "acme corp"}`, "id", tid)
+	serveAPI(t, HandleAPIDeleteTenant, http.MethodDelete, "/api/v1/tenants/"+tid, "", "id", tid)
+	var got []string
+	for _, e := range audit("type=tenant") {
+		got = append(got, e.Action+" "+e.ObjectLabel)
+	}
+	if want := []string{"delete acme corp", "update acme corp", "create acme"}; !slices.Equal(got, want) {
+		t.Errorf("tenant: expected %v, got %v", want, got)
+	}
+
+	// Deleting a VLAN unlinks its subnets
+	vid := decodeID(serveAPI(t, HandleAPICreateVLAN, http.MethodPost, "/api/v1/vlans", `{"vid": 100, "name": "servers"}`))
+	sid := decodeID(serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.62.0.0/24", "name": "srv", "vlan_id": "`+vid+`"}`))
+	serveAPI(t, HandleAPIDeleteVLAN, http.MethodDelete, "/api/v1/vlans/"+vid, "", "id", vid)
+	entries := audit("type=subnet&action=update&subnet=" + sid)
+	var before, after models.Subnet
+	if len(entries) == 1 {
+		json.Unmarshal(entries[0].Before, &before)
+		json.Unmarshal(entries[0].After, &after)
+	}
+	if len(entries) != 1 || before.VLANID.String() != vid || after.VLANID.Valid {
+		t.Errorf("VLAN delete: expected the subnet to be unlinked, got %+v", entries)
+	}
+	if entries := audit("type=vlan&action=delete"); len(entries) != 1 || entries[0].ObjectLabel != "100 (servers)" {
+		t.Errorf("VLAN delete: got %+v", entries)
+	}
+
+	// Deleting a custom field drops its values
+	fid := decodeID(serveAPI(t, HandleAPICreateCustomField, http.MethodPost, "/api/v1/custom-fields",
+		`{"object_type": "ip", "name": "owner", "type": "text"}`))
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+sid+"/ips",
+		`{"address": "10.62.0.5", "custom_fields": {"owner": "netops"}}`, "id", sid)
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+sid+"/ips", `{"address": "10.62.0.6"}`, "id", sid)
+	serveAPI(t, HandleAPIDeleteCustomField, http.MethodDelete, "/api/v1/custom-fields/"+fid, "", "id", fid)
+	if entries := audit("type=ip&action=update"); len(entries) != 1 || entries[0].ObjectID != "10.62.0.5" {
+		t.Errorf("custom field delete: expected an update of 10.62.0.5, got %+v", entries)
+	}
+
+	// Deleting an interface unassigns its addresses and clears the primary IP
+	did := decodeID(serveAPI(t, HandleAPICreateDevice, http.MethodPost, "/api/v1/devices", `{"name": "edge-01"}`))
+	fid = decodeID(serveAPI(t, HandleAPICreateInterface, http.MethodPost, "/api/v1/devices/"+did+"/interfaces", `{"name": "eth0"}`, "id", did))
+	w := serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, "/api/v1/subnets/"+sid+"/ips/10.62.0.6",
+		`{"interface_id": "`+fid+`"}`, "id", sid, "address", "10.62.0.6")
+	ipID := decodeID(w)
+	serveAPI(t, HandleAPIUpdateDevice, http.MethodPatch, "/api/v1/devices/"+did, `{"primary_ip_id": "`+ipID+`"}`, "id", did)
+	serveAPI(t, HandleAPIDeleteInterface, http.MethodDelete, "/api/v1/interfaces/"+fid, "", "id", fid)
+	got = nil
+	for _, e := range audit("") {
+		got = append(got, e.ObjectType+" "+e.Action+" "+e.ObjectLabel)
+	}
+	want := []string{"interface delete edge-01 eth0", "device update edge-01", "ip update 10.62.0.6",
+		"device update edge-01", "ip update 10.62.0.6", "interface create edge-01 eth0", "device create edge-01"}
+	if len(got) < len(want) || !slices.Equal(got[:len(want)], want) {
+		t.Errorf("interface delete: expected %v, got %v", want, got)
+	}
+}
+
+func TestAuditChanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []models.AuditChange
+	}{
+		{"update", `{"hostname": "a", "tags": [], "status": "allocated"}`, `{"hostname": "b", "tags": [], "status": "allocated"}`,
+			[]models.AuditChange{{Field: "hostname", Before: `"a"`, After: `"b"`}}},
+		{"create skips empty fields", "", `{"hostname": null, "mac_address": "", "status": "reserved"}`,
+			[]models.AuditChange{{Field: "status", After: `"reserved"`}}},
+		{"delete", `{"cidr": "10.0.0.0/24"}`, "null",
+			[]models.AuditChange{{Field: "cidr", Before: `"10.0.0.0/24"`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := auditChanges(json.RawMessage(tt.before), json.RawMessage(tt.after))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequestAuditSource(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name, remote, user, forwardedFor string
+		actor, sourceIP                  string
+	}{
+		{"direct", "[::ffff:192.0.2.1]:54321", "", "", "anonymous", "192.0.2.1"},
+		{"forged headers", "192.0.2.1:54321", "alice", "203.0.113.7", "anonymous", "192.0.2.1"},
+		{"trusted proxy", "10.0.0.1:54321", "alice", "203.0.113.7", "alice", "203.0.113.7"},
+		{"spoofed hop before the proxy", "10.0.0.1:54321", "alice", "198.51.100.1, 203.0.113.7, 10.0.0.2", "alice", "203.0.113.7"},
+		{"trusted proxy without headers", "10.0.0.1:54321", "", "", "anonymous", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.user != "" {
+				req.Header.Set("X-Forwarded-User", tt.user)
+			}
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			src := requestAuditSource(req, trusted)
+			if src.Actor != tt.actor || src.SourceIP != tt.sourceIP || len(src.RequestID) != 32 {
+				t.Errorf("expected %s from %s, got %+v", tt.actor, tt.sourceIP, src)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "bad\nid")
+	if src := requestAuditSource(req, nil); src.RequestID == "bad\nid" {
+		t.Errorf("a request ID with control characters should be replaced, got %q", src.RequestID)
+	}
+	if src := auditSourceOf(context.Background()); src.Actor != "system" {
+		t.Errorf("outside a request: expected the system actor, got %+v", src)
+	}
+}
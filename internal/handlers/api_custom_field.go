@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListCustomFields handles GET /api/v1/custom-fields.
+// Query parameters: object_type (subnet or ip).
+func HandleAPIListCustomFields(w http.ResponseWriter, r *http.Request) {
+	fields, err := listCustomFields(r.Context(), r.URL.Query().Get("object_type"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if fields == nil {
+		fields = []models.CustomField{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.CustomField]{Items: fields})
+}
+
+// HandleAPICreateCustomField handles POST /api/v1/custom-fields with a body such as
+// {"object_type": "ip", "name": "rack_unit", "label": "Rack unit", "type": "integer"}.
+// Enum fields list their "choices".
+func HandleAPICreateCustomField(w http.ResponseWriter, r *http.Request) {
+	var req models.CustomField
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	f, err := createCustomField(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/custom-fields/"+f.ID.String())
+	writeJSON(w, http.StatusCreated, f)
+}
+
+// HandleAPIGetCustomField handles GET /api/v1/custom-fields/{id}.
+func HandleAPIGetCustomField(w http.ResponseWriter, r *http.Request) {
+	f, err := getCustomField(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// HandleAPIUpdateCustomField handles PATCH /api/v1/custom-fields/{id}.
+func HandleAPIUpdateCustomField(w http.ResponseWriter, r *http.Request) {
+	var patch customFieldPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	f, err := updateCustomField(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// HandleAPIDeleteCustomField handles DELETE /api/v1/custom-fields/{id}. The
+// values of the field are removed from all objects.
+func HandleAPIDeleteCustomField(w http.ResponseWriter, r *http.Request) {
+	if err := deleteCustomField(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPICustomFieldLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	for _, body := range []string{
+		`{"object_type": "subnet", "name": "owner", "label": "Owner", "type": "text"}`,
+		`{"object_type": "ip", "name": "rack_unit", "type": "integer"}`,
+		`{"object_type": "ip", "name": "tier", "type": "enum", "choices": ["gold", "silver"]}`,
+		`{"object_type": "ip", "name": "monitored", "type": "boolean"}`,
+	} {
+		if w := serveAPI(t, HandleAPICreateCustomField, http.MethodPost, "/api/v1/custom-fields", body); w.Code != http.StatusCreated {
+			t.Fatalf("create field %s: expected 201, got %d; body: %s", body, w.Code, w.Body.String())
+		}
+	}
+	w := serveAPI(t, HandleAPIListCustomFields, http.MethodGet, "/api/v1/custom-fields?object_type=ip", "")
+	var fields listResponse[models.CustomField]
+	decodeBody(t, w, &fields)
+	if len(fields.Items) != 3 {
+		t.Fatalf("IP fields: expected 3, got %+v", fields.Items)
+	}
+	var rackUnit models.CustomField
+	for _, f := range fields.Items {
+		if f.Name == "rack_unit" {
+			rackUnit = f
+		}
+	}
+	if rackUnit.Label != "rack_unit" {
+		t.Errorf("default label: expected rack_unit, got %q", rackUnit.Label)
+	}
+
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.0.0.0/24", "name": "lan", "custom_fields": {"owner": "netops"}}`)
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	if lan.CustomFields["owner"] != "netops" {
+		t.Errorf("subnet fields: expected owner netops, got %+v", lan.CustomFields)
+	}
+	id := lan.ID.String()
+
+	// Values are normalized: integers and booleans given as strings are stored typed.
+	w = serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips",
+		`{"address": "10.0.0.5", "custom_fields": {"rack_unit": "12", "tier": "gold", "monitored": "true"}}`, "id", id)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("allocate: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if ip.CustomFields["rack_unit"] != float64(12) || ip.CustomFields["monitored"] != true || ip.CustomFields["tier"] != "gold" {
+		t.Errorf("IP fields: got %+v", ip.CustomFields)
+	}
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips",
+		`{"address": "10.0.0.6", "custom_fields": {"tier": "silver"}}`, "id", id)
+
+	// A patch merges into the current values; null removes one.
+	w = serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, "/api/v1/subnets/"+id+"/ips/10.0.0.5",
+		`{"custom_fields": {"monitored": null, "rack_unit": 14}}`, "id", id, "address", "10.0.0.5")
+	decodeBody(t, w, &ip)
+	if _, ok := ip.CustomFields["monitored"]; ok || ip.CustomFields["rack_unit"] != float64(14) || ip.CustomFields["tier"] != "gold" {
+		t.Errorf("patched IP fields: got %+v", ip.CustomFields)
+	}
+
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, "/api/v1/subnets/"+id+"/ips?cf_tier=gold", "", "id", id)
+	var page ipListResponse
+	decodeBody(t, w, &page)
+	if len(page.Items) != 1 || page.Items[0].Address != "10.0.0.5" {
+		t.Errorf("IPs with tier gold: expected only 10.0.0.5, got %+v", page.Items)
+	}
+	w = serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets?cf_owner=netops", "")
+	var subnets listResponse[models.Subnet]
+	decodeBody(t, w, &subnets)
+	if len(subnets.Items) != 1 {
+		t.Errorf("subnets owned by netops: expected 1, got %+v", subnets.Items)
+	}
+
+	w = serveAPI(t, HandleExportIPs, http.MethodGet, "/subnets/"+id+"/ips.csv", "", "id", id)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], ",rack_unit,tier,monitored") || !strings.HasSuffix(lines[1], ",14,gold,") {
+		t.Errorf("CSV export: got %q", w.Body.String())
+	}
+
+	// Deleting a field removes its values everywhere.
+	rid := rackUnit.ID.String()
+	if w := serveAPI(t, HandleAPIDeleteCustomField, http.MethodDelete, "/api/v1/custom-fields/"+rid, "", "id", rid); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIGetIP, http.MethodGet, "/api/v1/subnets/"+id+"/ips/10.0.0.5", "", "id", id, "address", "10.0.0.5")
+	decodeBody(t, w, &ip)
+	if _, ok := ip.CustomFields["rack_unit"]; ok {
+		t.Errorf("fields after delete: got %+v", ip.CustomFields)
+	}
+}
+
+func TestAPICustomFieldErrors(t *testing.T) {
+	cleanDB(t)
+
+	for _, body := range []string{
+		`{"object_type": "ip", "name": "rack_unit", "type": "integer"}`,
+		`{"object_type": "ip", "name": "tier", "type": "enum", "choices": ["gold"]}`,
+		`{"object_type": "ip", "name": "docs", "type": "url"}`,
+		`{"object_type": "ip", "name": "installed", "type": "date"}`,
+	} {
+		serveAPI(t, HandleAPICreateCustomField, http.MethodPost, "/api/v1/custom-fields", body)
+	}
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.0.0.0/24", "name": "lan"}`)
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	id := lan.ID.String()
+	allocate := func(fields string) string {
+		return `{"address": "10.0.0.5", "custom_fields": ` + fields + `}`
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		path    []string
+		status  int
+		code    string
+	}{
+		{"duplicate name", HandleAPICreateCustomField, `{"object_type": "ip", "name": "tier", "type": "text"}`, nil, http.StatusConflict, "custom_field_exists"},
+		{"invalid name", HandleAPICreateCustomField, `{"object_type": "ip", "name": "Rack Unit", "type": "text"}`, nil, http.StatusUnprocessableEntity, "invalid_name"},
+		{"invalid type", HandleAPICreateCustomField, `{"object_type": "ip", "name": "x", "type": "float"}`, nil, http.StatusUnprocessableEntity, "invalid_type"},
+		{"invalid object type", HandleAPICreateCustomField, `{"object_type": "vlan", "name": "x", "type": "text"}`, nil, http.StatusUnprocessableEntity, "invalid_object_type"},
+		{"enum without choices", HandleAPICreateCustomField, `{"object_type": "ip", "name": "x", "type": "enum"}`, nil, http.StatusUnprocessableEntity, "invalid_choices"},
+		{"not an integer", HandleAPIAllocateIP, allocate(`{"rack_unit": "twelve"}`), []string{"id", id}, http.StatusUnprocessableEntity, "invalid_custom_field"},
+		{"not a choice", HandleAPIAllocateIP, allocate(`{"tier": "bronze"}`), []string{"id", id}, http.StatusUnprocessableEntity, "invalid_custom_field"},
+		{"not a URL", HandleAPIAllocateIP, allocate(`{"docs": "wiki/page"}`), []string{"id", id}, http.StatusUnprocessableEntity, "invalid_custom_field"},
+		{"not a date", HandleAPIAllocateIP, allocate(`{"installed": "01/02/2024"}`), []string{"id", id}, http.StatusUnprocessableEntity, "invalid_custom_field"},
+		{"unknown field", HandleAPIAllocateIP, allocate(`{"color": "red"}`), []string{"id", id}, http.StatusUnprocessableEntity, "unknown_custom_field"},
+		{"subnet with IP field", HandleAPICreateSubnet, `{"cidr": "10.1.0.0/24", "name": "x", "custom_fields": {"tier": "gold"}}`, nil,
+			http.StatusUnprocessableEntity, "unknown_custom_field"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveAPI(t, tc.handler, http.MethodPost, "/api/v1/custom-fields", tc.body, tc.path...)
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d; body: %s", tc.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tc.code {
+				t.Errorf("expected code %s, got %s", tc.code, body.Error.Code)
+			}
+		})
+	}
+
+	if w := serveAPI(t, HandleAPIListIPs, http.MethodGet, "/api/v1/subnets/"+id+"/ips?cf_tier=bronze", "", "id", id); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("filter by invalid choice: expected 422, got %d", w.Code)
+	}
+}
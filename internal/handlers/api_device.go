@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListDevices handles GET /api/v1/devices.
+// Query parameters: site (a site ID, or "none").
+func HandleAPIListDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := listDevices(r.Context(), r.URL.Query().Get("site"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if devices == nil {
+		devices = []models.Device{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Device]{Items: devices})
+}
+
+// HandleAPICreateDevice handles POST /api/v1/devices with a body such as
+// {"name": "edge-01", "role": "router", "platform": "junos", "site_id": "..."}.
+func HandleAPICreateDevice(w http.ResponseWriter, r *http.Request) {
+	var req models.Device
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	d, err := createDevice(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/devices/"+d.ID.String())
+	writeJSON(w, http.StatusCreated, d)
+}
+
+// HandleAPIGetDevice handles GET /api/v1/devices/{id}. The device is
+// returned with its interfaces.
+func HandleAPIGetDevice(w http.ResponseWriter, r *http.Request) {
+	d, err := getDevice(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if d.Interfaces, err = listInterfaces(r.Context(), d.ID.String()); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// HandleAPIUpdateDevice handles PATCH /api/v1/devices/{id}. "primary_ip_id"
+// must name an address assigned to one of the device's interfaces.
+func HandleAPIUpdateDevice(w http.ResponseWriter, r *http.Request) {
+	var patch devicePatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	d, err := updateDevice(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// HandleAPIDeleteDevice handles DELETE /api/v1/devices/{id}. Its interfaces
+// are deleted too; their addresses are kept but no longer assigned.
+func HandleAPIDeleteDevice(w http.ResponseWriter, r *http.Request) {
+	if err := deleteDevice(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPIListDeviceIPs handles GET /api/v1/devices/{id}/ips: the addresses
+// assigned to the device's interfaces, across all subnets.
+func HandleAPIListDeviceIPs(w http.ResponseWriter, r *http.Request) {
+	d, err := getDevice(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	ips, err := deviceIPs(r.Context(), d.ID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if ips == nil {
+		ips = []models.IP{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.IP]{Items: ips})
+}
+
+// HandleAPICreateInterface handles POST /api/v1/devices/{id}/interfaces with
+// a body such as {"name": "eth0", "type": "ethernet", "mac_address": "00:50:56:aa:bb:cc"}.
+func HandleAPICreateInterface(w http.ResponseWriter, r *http.Request) {
+	var req models.Interface
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	f, err := createInterface(r.Context(), r.PathValue("id"), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/interfaces/"+f.ID.String())
+	writeJSON(w, http.StatusCreated, f)
+}
+
+// HandleAPIGetInterface handles GET /api/v1/interfaces/{id}.
+func HandleAPIGetInterface(w http.ResponseWriter, r *http.Request) {
+	f, err := getInterface(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// HandleAPIUpdateInterface handles PATCH /api/v1/interfaces/{id}.
+func HandleAPIUpdateInterface(w http.ResponseWriter, r *http.Request) {
+	var patch interfacePatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	f, err := updateInterface(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
+
+// HandleAPIDeleteInterface handles DELETE /api/v1/interfaces/{id}. Its
+// addresses are kept but no longer assigned.
+func HandleAPIDeleteInterface(w http.ResponseWriter, r *http.Request) {
+	if err := deleteInterface(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
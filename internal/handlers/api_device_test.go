@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIDeviceLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateDevice, http.MethodPost, "/api/v1/devices", `{"name": "edge-01", "role": "router", "platform": "junos"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create device: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var edge models.Device
+	decodeBody(t, w, &edge)
+	did := edge.ID.String()
+
+	// MAC addresses are accepted in colon, dash and dotted notation.
+	var ifaces []models.Interface
+	for _, body := range []string{
+		`{"name": "ge-0/0/0", "mac_address": "00-50-56-AA-BB-CC"}`,
+		`{"name": "lo0", "type": "loopback", "mac_address": "0050.56aa.bbcd"}`,
+	} {
+		w := serveAPI(t, HandleAPICreateInterface, http.MethodPost, "/api/v1/devices/"+did+"/interfaces", body, "id", did)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create interface %s: expected 201, got %d; body: %s", body, w.Code, w.Body.String())
+		}
+		var f models.Interface
+		decodeBody(t, w, &f)
+		ifaces = append(ifaces, f)
+	}
+	if mac := ifaces[0].MACAddress; mac == nil || *mac != "00:50:56:aa:bb:cc" || ifaces[0].Type != models.InterfaceEthernet {
+		t.Errorf("interface: expected ethernet with MAC 00:50:56:aa:bb:cc, got %+v", ifaces[0])
+	}
+	if mac := ifaces[1].MACAddress; mac == nil || *mac != "00:50:56:aa:bb:cd" {
+		t.Errorf("dotted MAC: expected 00:50:56:aa:bb:cd, got %v", mac)
+	}
+	ge, lo := ifaces[0].ID.String(), ifaces[1].ID.String()
+
+	// Addresses in two subnets are assigned to the device's interfaces.
+	var subnets []string
+	for _, body := range []string{`{"cidr": "10.0.0.0/24", "name": "transit"}`, `{"cidr": "192.0.2.0/24", "name": "loopbacks"}`} {
+		w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body)
+		var s models.Subnet
+		decodeBody(t, w, &s)
+		subnets = append(subnets, s.ID.String())
+	}
+	w = serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+subnets[0]+"/ips",
+		`{"address": "10.0.0.1", "interface_id": "`+ge+`"}`, "id", subnets[0])
+	if w.Code != http.StatusCreated {
+		t.Fatalf("allocate: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var transit models.IP
+	decodeBody(t, w, &transit)
+	if transit.InterfaceID != ifaces[0].ID || transit.DeviceID != edge.ID {
+		t.Errorf("allocated IP: expected interface ge-0/0/0 of edge-01, got %+v", transit)
+	}
+	w = serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, "/api/v1/subnets/"+subnets[1]+"/ips/next",
+		`{"interface_id": "`+lo+`"}`, "id", subnets[1])
+	var loopback models.IP
+	decodeBody(t, w, &loopback)
+
+	w = serveAPI(t, HandleAPIListDeviceIPs, http.MethodGet, "/api/v1/devices/"+did+"/ips", "", "id", did)
+	var ips listResponse[models.IP]
+	decodeBody(t, w, &ips)
+	if len(ips.Items) != 2 || ips.Items[0].Address != "10.0.0.1" || ips.Items[1].Address != "192.0.2.1" {
+		t.Fatalf("device IPs: expected 10.0.0.1 and 192.0.2.1, got %+v", ips.Items)
+	}
+
+	w = serveAPI(t, HandleAPIUpdateDevice, http.MethodPatch, "/api/v1/devices/"+did,
+		`{"primary_ip_id": "`+loopback.ID.String()+`"}`, "id", did)
+	decodeBody(t, w, &edge)
+	if w.Code != http.StatusOK || edge.PrimaryIP != "192.0.2.1" {
+		t.Fatalf("set primary: got %d %+v", w.Code, edge)
+	}
+
+	// Moving the primary IP to another device clears the designation.
+	w = serveAPI(t, HandleAPICreateDevice, http.MethodPost, "/api/v1/devices", `{"name": "edge-02"}`)
+	var other models.Device
+	decodeBody(t, w, &other)
+	oid := other.ID.String()
+	w = serveAPI(t, HandleAPICreateInterface, http.MethodPost, "/api/v1/devices/"+oid+"/interfaces", `{"name": "lo0"}`, "id", oid)
+	var otherLo models.Interface
+	decodeBody(t, w, &otherLo)
+	w = serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, "/api/v1/subnets/"+subnets[1]+"/ips/192.0.2.1",
+		`{"interface_id": "`+otherLo.ID.String()+`"}`, "id", subnets[1], "address", "192.0.2.1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("move IP: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	w = serveAPI(t, HandleAPIGetDevice, http.MethodGet, "/api/v1/devices/"+did, "", "id", did)
+	decodeBody(t, w, &edge)
+	if edge.PrimaryIPID.Valid || len(edge.Interfaces) != 2 {
+		t.Errorf("device after move: expected no primary IP and 2 interfaces, got %+v", edge)
+	}
+
+	// Deleting an interface keeps its addresses, unassigned.
+	if w := serveAPI(t, HandleAPIDeleteInterface, http.MethodDelete, "/api/v1/interfaces/"+ge, "", "id", ge); w.Code != http.StatusNoContent {
+		t.Fatalf("delete interface: expected 204, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIGetIP, http.MethodGet, "/api/v1/subnets/"+subnets[0]+"/ips/10.0.0.1", "", "id", subnets[0], "address", "10.0.0.1")
+	decodeBody(t, w, &transit)
+	if transit.Status != "allocated" || transit.InterfaceID.Valid {
+		t.Errorf("IP after interface delete: expected allocated and unassigned, got %+v", transit)
+	}
+
+	if w := serveAPI(t, HandleAPIDeleteDevice, http.MethodDelete, "/api/v1/devices/"+oid, "", "id", oid); w.Code != http.StatusNoContent {
+		t.Fatalf("delete device: expected 204, got %d", w.Code)
+	}
+	if w := serveAPI(t, HandleAPIGetInterface, http.MethodGet, "/api/v1/interfaces/"+otherLo.ID.String(), "", "id", otherLo.ID.String()); w.Code != http.StatusNotFound {
+		t.Errorf("interface of deleted device: expected 404, got %d", w.Code)
+	}
+}
+
+func TestAPIDeviceErrors(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateDevice, http.MethodPost, "/api/v1/devices", `{"name": "existing"}`)
+	var d models.Device
+	decodeBody(t, w, &d)
+	did := d.ID.String()
+	serveAPI(t, HandleAPICreateInterface, http.MethodPost, "/api/v1/devices/"+did+"/interfaces", `{"name": "eth0"}`, "id", did)
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.0.0.0/24", "name": "lan"}`)
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	id := lan.ID.String()
+	w = serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips", `{"address": "10.0.0.9"}`, "id", id)
+	var unassigned models.IP
+	decodeBody(t, w, &unassigned)
+	missing := "00000000-0000-0000-0000-000000000001"
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		path    []string
+		status  int
+		code    string
+	}{
+		{"duplicate name", HandleAPICreateDevice, http.MethodPost, `{"name": "existing"}`, nil, http.StatusConflict, "device_exists"},
+		{"missing name", HandleAPICreateDevice, http.MethodPost, `{"role": "switch"}`, nil, http.StatusUnprocessableEntity, "missing_field"},
+		{"unknown site", HandleAPICreateDevice, http.MethodPost, `{"name": "x", "site_id": "` + missing + `"}`, nil, http.StatusUnprocessableEntity, "site_not_found"},
+		{"unknown device", HandleAPIGetDevice, http.MethodGet, "", []string{"id", missing}, http.StatusNotFound, "device_not_found"},
+		{"duplicate interface", HandleAPICreateInterface, http.MethodPost, `{"name": "eth0"}`, []string{"id", did}, http.StatusConflict, "interface_exists"},
+		{"invalid interface type", HandleAPICreateInterface, http.MethodPost, `{"name": "eth1", "type": "serial"}`, []string{"id", did},
+			http.StatusUnprocessableEntity, "invalid_interface_type"},
+		{"invalid MAC", HandleAPICreateInterface, http.MethodPost, `{"name": "eth1", "mac_address": "00:50:56"}`, []string{"id", did},
+			http.StatusUnprocessableEntity, "invalid_mac"},
+		{"unknown interface", HandleAPIAllocateIP, http.MethodPost, `{"address": "10.0.0.5", "interface_id": "` + missing + `"}`, []string{"id", id},
+			http.StatusUnprocessableEntity, "interface_not_found"},
+		{"primary IP of another device", HandleAPIUpdateDevice, http.MethodPatch, `{"primary_ip_id": "` + unassigned.ID.String() + `"}`, []string{"id", did},
+			http.StatusUnprocessableEntity, "invalid_primary_ip"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveAPI(t, tc.handler, tc.method, "/api/v1/devices", tc.body, tc.path...)
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d; body: %s", tc.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tc.code {
+				t.Errorf("expected code %s, got %s", tc.code, body.Error.Code)
+			}
+		})
+	}
+}
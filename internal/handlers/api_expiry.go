@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// extendRequest is the optional body of POST .../ips/{address}/extend.
+type extendRequest struct {
+	Days int `json:"days"` // 7 when omitted
+}
+
+// HandleAPIExtendIP handles POST /api/v1/subnets/{id}/ips/{address}/extend
+// with an optional body such as {"days": 30}. The expiry moves back by that
+// many days from the current expiry, or from now if it has already passed.
+func HandleAPIExtendIP(w http.ResponseWriter, r *http.Request) {
+	req := extendRequest{Days: defaultExtendDays}
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	ip, err := extendIP(r.Context(), r.PathValue("id"), r.PathValue("address"), req.Days)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ip)
+}
+
+// HandleAPIListExpiringIPs handles GET /api/v1/ips/expiring: the allocated
+// and reserved addresses expiring within ?days= (7 by default) across all
+// subnets, soonest first.
+func HandleAPIListExpiringIPs(w http.ResponseWriter, r *http.Request) {
+	ips, err := expiringIPs(r.Context(), min(queryInt(r, "days", defaultExpiringDays), maxExpiryDays))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if ips == nil {
+		ips = []models.IP{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.IP]{Items: ips})
+}
+
+// HandleAPIListExpirations handles GET /api/v1/ips/expirations: the addresses
+// most recently released because they expired, latest first.
+func HandleAPIListExpirations(w http.ResponseWriter, r *http.Request) {
+	expirations, err := recentExpirations(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if expirations == nil {
+		expirations = []models.IPExpiration{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.IPExpiration]{Items: expirations})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIIPExpiry(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.50.0.0/24")
+	base := "/api/v1/subnets/" + id + "/ips"
+	soon := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	// Allocate and reserve with an expiry
+	w := serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.50.0.10", "hostname": "lab-01", "expires_at": "`+soon+`"}`, "id", id)
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusCreated || ip.ExpiresAt == nil {
+		t.Fatalf("allocate: expected 201 with an expiry, got %d %+v", w.Code, ip)
+	}
+	w = serveAPI(t, HandleAPIReserveIP, http.MethodPost, base+"/10.50.0.11/reserve", `{"expires_at": "`+soon+`"}`, "id", id, "address", "10.50.0.11")
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusOK || ip.Status != "reserved" || ip.ExpiresAt == nil {
+		t.Fatalf("reserve: expected a reservation with an expiry, got %d %+v", w.Code, ip)
+	}
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.50.0.12"}`, "id", id)
+
+	// An expiry in the past is refused
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	w = serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.50.0.13", "expires_at": "`+past+`"}`, "id", id)
+	var body apiErrorBody
+	decodeBody(t, w, &body)
+	if w.Code != http.StatusUnprocessableEntity || body.Error.Code != "invalid_expiry" {
+		t.Errorf("past expiry: expected 422 invalid_expiry, got %d %q", w.Code, body.Error.Code)
+	}
+
+	// Extend by two days
+	before := *ip.ExpiresAt
+	w = serveAPI(t, HandleAPIExtendIP, http.MethodPost, base+"/10.50.0.11/extend", `{"days": 2}`, "id", id, "address", "10.50.0.11")
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusOK || ip.ExpiresAt == nil || ip.ExpiresAt.Sub(before).Round(time.Hour) != 48*time.Hour {
+		t.Errorf("extend: expected the expiry 2 days later, got %d %+v", w.Code, ip.ExpiresAt)
+	}
+	w = serveAPI(t, HandleAPIExtendIP, http.MethodPost, base+"/10.50.0.12/extend", "", "id", id, "address", "10.50.0.12")
+	decodeBody(t, w, &body)
+	if w.Code != http.StatusConflict || body.Error.Code != "ip_not_expiring" {
+		t.Errorf("extend permanent: expected 409 ip_not_expiring, got %d %q", w.Code, body.Error.Code)
+	}
+
+	// Only the allocation expires within a day
+	w = serveAPI(t, HandleAPIListExpiringIPs, http.MethodGet, "/api/v1/ips/expiring?days=1", "")
+	var list listResponse[models.IP]
+	decodeBody(t, w, &list)
+	if len(list.Items) != 1 || list.Items[0].Address != "10.50.0.10" {
+		t.Errorf("expiring: expected 10.50.0.10, got %+v", list.Items)
+	}
+
+	// Clearing the expiry makes the address permanent
+	w = serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, base+"/10.50.0.10", `{"expires_at": null}`, "id", id, "address", "10.50.0.10")
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusOK || ip.ExpiresAt != nil {
+		t.Errorf("clear expiry: got %d %+v", w.Code, ip.ExpiresAt)
+	}
+
+	// Once the reservation has expired, the worker returns it to the pool
+	ctx := context.Background()
+	if _, err := database.DB.Exec(ctx, "UPDATE ips SET expires_at = now() - interval '1 minute' WHERE address = '10.50.0.11'"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := releaseExpired(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("releaseExpired: expected 1 release, got %d, %v", n, err)
+	}
+	w = serveAPI(t, HandleAPIGetIP, http.MethodGet, base+"/10.50.0.11", "", "id", id, "address", "10.50.0.11")
+	decodeBody(t, w, &ip)
+	if ip.Status != "available" {
+		t.Errorf("after expiry: expected available, got %+v", ip)
+	}
+	w = serveAPI(t, HandleAPIListExpirations, http.MethodGet, "/api/v1/ips/expirations", "")
+	var expirations listResponse[models.IPExpiration]
+	decodeBody(t, w, &expirations)
+	if len(expirations.Items) != 1 || expirations.Items[0].Address != "10.50.0.11" ||
+		expirations.Items[0].Status != "reserved" || expirations.Items[0].Reason != "reservation expired" {
+		t.Errorf("expirations: got %+v", expirations.Items)
+	}
+}
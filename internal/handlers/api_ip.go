@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// maxAPIPageSize bounds page_size for IP listings.
+const maxAPIPageSize = 1000
+
+// ipListResponse is one page of a subnet's addresses.
+type ipListResponse struct {
+	Items    []models.IP `json:"items"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	Total    *big.Int    `json:"total"` // may exceed 2^63 for IPv6 subnets
+}
+
+// HandleAPIListIPs handles GET /api/v1/subnets/{id}/ips.
+// Query parameters: status (all/available/allocated/reserved), tenant (a
+// tenant ID; only addresses it owns, directly or through the subnet), tag (a
+// tag ID; only addresses carrying it), cf_<name> (only addresses whose custom
+// field <name> has this value), page, page_size.
+func HandleAPIListIPs(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getNetwork(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	tenantID, err := parseTenantID(r.URL.Query().Get("tenant"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	tagID, err := parseTagID(r.URL.Query().Get("tag"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	fields, err := customFieldFilters(r.Context(), models.CustomFieldObjectIP, r.URL.Query())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	q := ipQuery{
+		Status:   r.URL.Query().Get("status"),
+		Tenant:   tenantID,
+		Tag:      tagID,
+		Fields:   fields,
+		Page:     queryInt(r, "page", 1),
+		PageSize: min(queryInt(r, "page_size", 30), maxAPIPageSize),
+	}
+	page, err := listIPs(r.Context(), subnet, q)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if page.IPs == nil {
+		page.IPs = []models.IP{}
+	}
+	writeJSON(w, http.StatusOK, ipListResponse{Items: page.IPs, Page: page.Page, PageSize: q.PageSize, Total: page.Total})
+}
+
+// HandleAPIAllocateIP handles POST /api/v1/subnets/{id}/ips with a body such as
+// {"address": "10.0.0.5", "hostname": "web-01"}. "mac_address" records the
+// MAC bound to the address in any common notation, "tenant_id" assigns the
+// address to a tenant other than the subnet's, "interface_id" assigns it to
+// a device interface, "expires_at" (RFC 3339) releases it automatically at
+// that time and "custom_fields" sets the values of custom fields by name.
+func HandleAPIAllocateIP(w http.ResponseWriter, r *http.Request) {
+	var req models.IP
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	ip, err := allocateIP(r.Context(), r.PathValue("id"), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/subnets/"+r.PathValue("id")+"/ips/"+ip.Address)
+	writeJSON(w, http.StatusCreated, ip)
+}
+
+// nextIPRequest is the body of POST /api/v1/subnets/{id}/ips/next.
+type nextIPRequest struct {
+	Hostname     string         `json:"hostname"`
+	MACAddress   string         `json:"mac_address"`
+	TenantID     pgtype.UUID    `json:"tenant_id"`
+	InterfaceID  pgtype.UUID    `json:"interface_id"`
+	CustomFields map[string]any `json:"custom_fields"`
+	Strategy     string         `json:"strategy"`   // lowest (default), highest or random
+	RangeID      pgtype.UUID    `json:"range_id"`   // if set, the address is taken from this range of the subnet
+	ExpiresAt    *time.Time     `json:"expires_at"` // if set, the address is released automatically at this time
+}
+
+// HandleAPIAllocateNextIP handles POST /api/v1/subnets/{id}/ips/next with an
+// optional body such as {"hostname": "web-01", "strategy": "lowest"}; "range_id"
+// restricts the choice to a range of the subnet, e.g. its static block.
+// The allocated address is returned, so callers need not list free addresses first.
+func HandleAPIAllocateNextIP(w http.ResponseWriter, r *http.Request) {
+	var req nextIPRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	in := models.IP{Hostname: &req.Hostname, MACAddress: &req.MACAddress, TenantID: req.TenantID, InterfaceID: req.InterfaceID,
+		CustomFields: req.CustomFields, ExpiresAt: req.ExpiresAt}
+	ip, err := allocateNextIP(r.Context(), r.PathValue("id"), in, req.Strategy, req.RangeID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/subnets/"+r.PathValue("id")+"/ips/"+ip.Address)
+	writeJSON(w, http.StatusCreated, ip)
+}
+
+// HandleAPIGetIP handles GET /api/v1/subnets/{id}/ips/{address}.
+func HandleAPIGetIP(w http.ResponseWriter, r *http.Request) {
+	ip, err := getIP(r.Context(), r.PathValue("id"), r.PathValue("address"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ip)
+}
+
+// HandleAPIUpdateIP handles PATCH /api/v1/subnets/{id}/ips/{address}.
+func HandleAPIUpdateIP(w http.ResponseWriter, r *http.Request) {
+	var patch ipPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	ip, err := updateIP(r.Context(), r.PathValue("id"), r.PathValue("address"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ip)
+}
+
+// HandleAPIReleaseIP handles DELETE /api/v1/subnets/{id}/ips/{address}.
+// The address returns to the available pool; reserved addresses need ?force=true.
+func HandleAPIReleaseIP(w http.ResponseWriter, r *http.Request) {
+	force := r.URL.Query().Get("force") == "true"
+	if _, err := releaseIP(r.Context(), r.PathValue("id"), r.PathValue("address"), force); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ipActionRequest is the optional body of the reserve, unreserve and release actions.
+type ipActionRequest struct {
+	Hostname  *string    `json:"hostname"`   // reserve only; nil keeps the current hostname
+	ExpiresAt *time.Time `json:"expires_at"` // reserve only; nil makes the reservation permanent
+	Force     bool       `json:"force"`      // allow reserving an allocated or releasing a reserved address
+}
+
+// HandleAPIReserveIP handles POST /api/v1/subnets/{id}/ips/{address}/reserve.
+func HandleAPIReserveIP(w http.ResponseWriter, r *http.Request) {
+	var req ipActionRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	ip, err := reserveIP(r.Context(), r.PathValue("id"), r.PathValue("address"), req.Hostname, req.ExpiresAt, req.Force)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ip)
+}
+
+// HandleAPIUnreserveIP handles POST /api/v1/subnets/{id}/ips/{address}/unreserve.
+func HandleAPIUnreserveIP(w http.ResponseWriter, r *http.Request) {
+	ip, err := unreserveIP(r.Context(), r.PathValue("id"), r.PathValue("address"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ip)
+}
+
+// HandleAPIReleaseIPAction handles POST /api/v1/subnets/{id}/ips/{address}/release,
+// which behaves like DELETE but returns the now available address.
+func HandleAPIReleaseIPAction(w http.ResponseWriter, r *http.Request) {
+	var req ipActionRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	ip, err := releaseIP(r.Context(), r.PathValue("id"), r.PathValue("address"), req.Force)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ip)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// createTestSubnet creates a subnet through the API and returns its ID.
+func createTestSubnet(t *testing.T, cidr string) string {
+	t.Helper()
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "`+cidr+`", "name": "test"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create subnet %s: %d %s", cidr, w.Code, w.Body.String())
+	}
+	var s models.Subnet
+	decodeBody(t, w, &s)
+	return s.ID.String()
+}
+
+func TestAPIIPLifecycle(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.5.0.0/24")
+	base := "/api/v1/subnets/" + id + "/ips"
+
+	// Allocate
+	w := serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.5.0.10", "hostname": "web-01"}`, "id", id)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("allocate: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if ip.Address != "10.5.0.10" || ip.Status != "allocated" || ip.Hostname == nil || *ip.Hostname != "web-01" {
+		t.Errorf("unexpected IP: %+v", ip)
+	}
+
+	// Allocating the same address again conflicts
+	w = serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.5.0.10"}`, "id", id)
+	if w.Code != http.StatusConflict {
+		t.Errorf("re-allocate: expected 409, got %d", w.Code)
+	}
+
+	// List allocated
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, base+"?status=allocated", "", "id", id)
+	var list ipListResponse
+	decodeBody(t, w, &list)
+	if w.Code != http.StatusOK || len(list.Items) != 1 || list.Total.Int64() != 1 {
+		t.Errorf("list: got %d, %d items, total %v", w.Code, len(list.Items), list.Total)
+	}
+
+	// List everything: computed available addresses are included
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, base+"?page_size=20", "", "id", id)
+	decodeBody(t, w, &list)
+	if len(list.Items) != 20 || list.Total.Int64() != 254 || list.Items[9].Status != "allocated" {
+		t.Errorf("list all: %d items, total %v", len(list.Items), list.Total)
+	}
+
+	// Update hostname
+	w = serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, base+"/10.5.0.10", `{"hostname": "web-02"}`, "id", id, "address", "10.5.0.10")
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusOK || ip.Hostname == nil || *ip.Hostname != "web-02" {
+		t.Errorf("update: got %d %+v", w.Code, ip)
+	}
+
+	// Release, then the address reads as available
+	w = serveAPI(t, HandleAPIReleaseIP, http.MethodDelete, base+"/10.5.0.10", "", "id", id, "address", "10.5.0.10")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("release: expected 204, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIGetIP, http.MethodGet, base+"/10.5.0.10", "", "id", id, "address", "10.5.0.10")
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusOK || ip.Status != "available" {
+		t.Errorf("get after release: got %d %+v", w.Code, ip)
+	}
+}
+
+func TestAPIIPErrors(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.6.0.0/24")
+	base := "/api/v1/subnets/" + id + "/ips"
+
+	tests := []struct {
+		name    string
+		h       http.HandlerFunc
+		method  string
+		body    string
+		address string
+		status  int
+		code    string
+	}{
+		{"invalid address", HandleAPIAllocateIP, http.MethodPost, `{"address": "bogus"}`, "", http.StatusUnprocessableEntity, "invalid_address"},
+		{"outside subnet", HandleAPIAllocateIP, http.MethodPost, `{"address": "10.7.0.1"}`, "", http.StatusUnprocessableEntity, "address_outside_subnet"},
+		{"invalid hostname", HandleAPIAllocateIP, http.MethodPost, `{"address": "10.6.0.1", "hostname": "-bad"}`, "", http.StatusUnprocessableEntity, "invalid_hostname"},
+		{"get outside subnet", HandleAPIGetIP, http.MethodGet, "", "10.7.0.1", http.StatusNotFound, "ip_not_found"},
+		{"update available", HandleAPIUpdateIP, http.MethodPatch, `{"hostname": "x"}`, "10.6.0.2", http.StatusConflict, "ip_not_in_use"},
+		{"release available", HandleAPIReleaseIP, http.MethodDelete, "", "10.6.0.2", http.StatusConflict, "ip_not_in_use"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAPI(t, tt.h, tt.method, base, tt.body, "id", id, "address", tt.address)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d; body: %s", tt.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tt.code {
+				t.Errorf("expected error code %q, got %q", tt.code, body.Error.Code)
+			}
+		})
+	}
+}
+
+func TestAPIAllocateNextIP_Concurrent(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.8.0.0/27") // 30 host addresses
+	const callers = 40
+	strategies := []string{"lowest", "highest", "random"}
+
+	type result struct {
+		code int
+		ip   models.IP
+	}
+	results := make(chan result, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := `{"hostname": "host-` + strconv.Itoa(i) + `", "strategy": "` + strategies[i%len(strategies)] + `"}`
+			w := serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips/next", body, "id", id)
+			var res result
+			res.code = w.Code
+			if w.Code == http.StatusCreated {
+				if err := json.Unmarshal(w.Body.Bytes(), &res.ip); err != nil {
+					t.Errorf("failed to decode response: %v", err)
+				}
+			}
+			results <- res
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make(map[string]bool)
+	var created, full int
+	for res := range results {
+		switch res.code {
+		case http.StatusCreated:
+			created++
+			if seen[res.ip.Address] {
+				t.Errorf("address %s was handed out twice", res.ip.Address)
+			}
+			seen[res.ip.Address] = true
+		case http.StatusConflict:
+			full++
+		default:
+			t.Errorf("unexpected status %d", res.code)
+		}
+	}
+	if created != 30 || full != callers-30 {
+		t.Errorf("expected 30 allocations and %d conflicts, got %d and %d", callers-30, created, full)
+	}
+}
+
+func TestAPIAllocateNextIP_EmptyBody(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "2001:db8:8::/64")
+
+	w := serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips/next", "", "id", id)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if ip.Address != "2001:db8:8::1" || ip.Hostname != nil {
+		t.Errorf("unexpected IP: %+v", ip)
+	}
+}
+
+func TestAPIIPTransitions(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.9.0.0/24")
+	base := "/api/v1/subnets/" + id + "/ips/10.9.0.7"
+
+	steps := []struct {
+		name   string
+		h      http.HandlerFunc
+		method string
+		body   string
+		status int
+		code   string // expected error code, or the resulting IP status on success
+	}{
+		{"reserve available", HandleAPIReserveIP, http.MethodPost, `{"hostname": "gw"}`, http.StatusOK, "reserved"},
+		{"reserve twice", HandleAPIReserveIP, http.MethodPost, "", http.StatusConflict, "ip_already_reserved"},
+		{"release reserved", HandleAPIReleaseIPAction, http.MethodPost, "", http.StatusConflict, "ip_reserved"},
+		{"unreserve", HandleAPIUnreserveIP, http.MethodPost, "", http.StatusOK, "available"},
+		{"allocate", HandleAPIAllocateIP, http.MethodPost, `{"address": "10.9.0.7"}`, http.StatusCreated, "allocated"},
+		{"reserve allocated", HandleAPIReserveIP, http.MethodPost, "", http.StatusConflict, "ip_allocated"},
+		{"force reserve allocated", HandleAPIReserveIP, http.MethodPost, `{"force": true}`, http.StatusOK, "reserved"},
+		{"force release reserved", HandleAPIReleaseIPAction, http.MethodPost, `{"force": true}`, http.StatusOK, "available"},
+		{"unreserve available", HandleAPIUnreserveIP, http.MethodPost, "", http.StatusConflict, "ip_not_reserved"},
+	}
+	for _, step := range steps {
+		w := serveAPI(t, step.h, step.method, base, step.body, "id", id, "address", "10.9.0.7")
+		if w.Code != step.status {
+			t.Fatalf("%s: expected %d, got %d; body: %s", step.name, step.status, w.Code, w.Body.String())
+		}
+		var got string
+		if w.Code < 300 {
+			var ip models.IP
+			decodeBody(t, w, &ip)
+			got = ip.Status
+		} else {
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			got = body.Error.Code
+		}
+		if got != step.code {
+			t.Errorf("%s: expected %q, got %q", step.name, step.code, got)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// subnetStateResponse is the state of a subnet at a point in time.
+type subnetStateResponse struct {
+	SubnetID pgtype.UUID `json:"subnet_id"`
+	At       time.Time   `json:"at"`
+	Items    []models.IP `json:"items"` // allocated and reserved addresses
+}
+
+// HandleAPIIPHistory handles GET /api/v1/subnets/{id}/ips/{address}/history:
+// the changes to an address, newest first, each with the address before and
+// after. The history of a deleted subnet is kept. Query parameters: action,
+// actor, since and until (dates or RFC 3339 times), page, page_size.
+func HandleAPIIPHistory(w http.ResponseWriter, r *http.Request) {
+	subnetID, err := historySubnetID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeIPHistory(w, r, subnetID.String())
+}
+
+// HandleAPIAddressHistory handles GET /api/v1/ips/{address}/history: the
+// changes to an address in any subnet, with the same query parameters as
+// HandleAPIIPHistory.
+func HandleAPIAddressHistory(w http.ResponseWriter, r *http.Request) {
+	writeIPHistory(w, r, "")
+}
+
+// writeIPHistory writes a page of the changes to the address in the path,
+// limited to subnetID unless it is empty.
+func writeIPHistory(w http.ResponseWriter, r *http.Request, subnetID string) {
+	address, err := historyAddr(r.PathValue("address"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	f, err := auditFilterFrom(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	f.ObjectType, f.Object, f.ObjectID, f.SubnetID = models.AuditObjectIP, "", address, subnetID
+	page, pageSize := queryInt(r, "page", 1), min(queryInt(r, "page_size", auditPageSize), maxAPIPageSize)
+	entries, total, err := listAudit(r.Context(), f, page, pageSize)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, auditListResponse{Items: entries, Page: page, PageSize: pageSize, Total: total})
+}
+
+// HandleAPISubnetState handles GET /api/v1/subnets/{id}/state?at=: the
+// allocated and reserved addresses of a subnet at a point in time (an RFC
+// 3339 time, or a date for its start; now by default), as recorded in the
+// audit log. The state of a deleted subnet can still be queried.
+func HandleAPISubnetState(w http.ResponseWriter, r *http.Request) {
+	subnetID, err := historySubnetID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	at, err := parseAuditTime(r.URL.Query().Get("at"), false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	ips, err := ipsAt(r.Context(), subnetID, at)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if ips == nil {
+		ips = []models.IP{}
+	}
+	writeJSON(w, http.StatusOK, subnetStateResponse{SubnetID: subnetID, At: at, Items: ips})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIIPHistory(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.61.0.0/24")
+	base := "/api/v1/subnets/" + id + "/ips"
+	addr := "10.61.0.5"
+
+	// Allocate, rename, release and allocate again
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.61.0.5", "hostname": "a", "mac_address": "00:50:56:aa:bb:01"}`, "id", id)
+	serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, base+"/"+addr, `{"hostname": "b"}`, "id", id, "address", addr)
+	serveAPI(t, HandleAPIReleaseIP, http.MethodDelete, base+"/"+addr, "", "id", id, "address", addr)
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.61.0.5", "hostname": "c"}`, "id", id)
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, base, `{"address": "10.61.0.50", "hostname": "other"}`, "id", id)
+
+	history := func(target string, pathKV ...string) []models.AuditEntry {
+		t.Helper()
+		w := serveAPI(t, HandleAPIIPHistory, http.MethodGet, target, "", pathKV...)
+		if w.Code != http.StatusOK {
+			t.Fatalf("history: expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var list auditListResponse
+		decodeBody(t, w, &list)
+		return list.Items
+	}
+	entries := history(base+"/"+addr+"/history", "id", id, "address", addr)
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	if want := []string{"allocate", "release", "update", "allocate"}; !slices.Equal(actions, want) {
+		t.Fatalf("history: expected %v, got %v", want, actions)
+	}
+
+	// The state of the subnet after each change
+	state := func(at time.Time) []string {
+		t.Helper()
+		w := serveAPI(t, HandleAPISubnetState, http.MethodGet,
+			"/api/v1/subnets/"+id+"/state?at="+url.QueryEscape(at.Format(time.RFC3339Nano)), "", "id", id)
+		if w.Code != http.StatusOK {
+			t.Fatalf("state: expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var resp subnetStateResponse
+		decodeBody(t, w, &resp)
+		var got []string
+		for _, ip := range resp.Items {
+			got = append(got, ip.Address+" "+hostnameOf(ip)+" "+macOf(ip))
+		}
+		return got
+	}
+	for _, tt := range []struct {
+		at   time.Time
+		want []string
+	}{
+		{entries[3].OccurredAt.Add(-time.Second), nil},
+		{entries[3].OccurredAt, []string{"10.61.0.5 a 00:50:56:aa:bb:01"}},
+		{entries[2].OccurredAt, []string{"10.61.0.5 b 00:50:56:aa:bb:01"}},
+		{entries[1].OccurredAt, nil},
+		{time.Now().Add(time.Minute), []string{"10.61.0.5 c ", "10.61.0.50 other "}},
+	} {
+		if got := state(tt.at); !slices.Equal(got, tt.want) {
+			t.Errorf("state at %s: expected %q, got %q", tt.at, tt.want, got)
+		}
+	}
+
+	// Tagging after allocation is replayed by the state
+	w := serveAPI(t, HandleAPICreateTag, http.MethodPost, "/api/v1/tags", `{"name": "prod"}`)
+	var tag models.Tag
+	decodeBody(t, w, &tag)
+	tid := tag.ID.String()
+	serveAPI(t, HandleAPITagObjects, http.MethodPost, "/api/v1/tags/"+tid+"/tag",
+		`{"ips": [{"subnet_id": "`+id+`", "address": "10.61.0.50"}]}`, "id", tid)
+	w = serveAPI(t, HandleAPISubnetState, http.MethodGet, "/api/v1/subnets/"+id+"/state", "", "id", id)
+	var now subnetStateResponse
+	decodeBody(t, w, &now)
+	if len(now.Items) != 2 || len(now.Items[0].Tags) != 0 || len(now.Items[1].Tags) != 1 || now.Items[1].Tags[0].Name != "prod" {
+		t.Errorf("state after tagging: expected 10.61.0.50 tagged prod, got %+v", now.Items)
+	}
+
+	// History and state outlive the subnet
+	serveAPI(t, HandleAPIDeleteSubnet, http.MethodDelete, "/api/v1/subnets/"+id, "", "id", id)
+	if entries := history(base+"/"+addr+"/history", "id", id, "address", addr); len(entries) != 5 || entries[0].Action != "delete" {
+		t.Errorf("history after deleting the subnet: got %+v", entries)
+	}
+	if got := state(entries[2].OccurredAt); len(got) != 1 {
+		t.Errorf("state before deleting the subnet: got %q", got)
+	}
+	if got := state(time.Now().Add(time.Minute)); len(got) != 0 {
+		t.Errorf("state after deleting the subnet: expected nothing, got %q", got)
+	}
+
+	// Across subnets
+	w = serveAPI(t, HandleAPIAddressHistory, http.MethodGet, "/api/v1/ips/"+addr+"/history?action=allocate", "", "address", addr)
+	var list auditListResponse
+	decodeBody(t, w, &list)
+	if list.Total != 2 {
+		t.Errorf("address history: expected 2 allocations, got %d", list.Total)
+	}
+
+	// Errors
+	if w := serveAPI(t, HandleAPISubnetState, http.MethodGet, "/api/v1/subnets/00000000-0000-0000-0000-000000000000/state", "",
+		"id", "00000000-0000-0000-0000-000000000000"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown subnet: expected 404, got %d", w.Code)
+	}
+	if w := serveAPI(t, HandleAPISubnetState, http.MethodGet, "/api/v1/subnets/"+id+"/state?at=yesterday", "", "id", id); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid time: expected 422, got %d", w.Code)
+	}
+	if w := serveAPI(t, HandleAPIIPHistory, http.MethodGet, base+"/nope/history", "", "id", id, "address", "nope"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid address: expected 422, got %d", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListRanges handles GET /api/v1/subnets/{id}/ranges. Ranges are
+// returned in address order.
+func HandleAPIListRanges(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getNetwork(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	ranges, err := listRanges(r.Context(), subnet.ID)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if ranges == nil {
+		ranges = []models.IPRange{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.IPRange]{Items: ranges})
+}
+
+// HandleAPICreateRange handles POST /api/v1/subnets/{id}/ranges with a body
+// such as {"start_address": "10.0.0.100", "end_address": "10.0.0.199", "role": "dhcp"}.
+func HandleAPICreateRange(w http.ResponseWriter, r *http.Request) {
+	var req models.IPRange
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	rg, err := createRange(r.Context(), r.PathValue("id"), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/ranges/"+rg.ID.String())
+	writeJSON(w, http.StatusCreated, rg)
+}
+
+// HandleAPIGetRange handles GET /api/v1/ranges/{id}.
+func HandleAPIGetRange(w http.ResponseWriter, r *http.Request) {
+	rg, err := getRange(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rg)
+}
+
+// HandleAPIUpdateRange handles PATCH /api/v1/ranges/{id}.
+func HandleAPIUpdateRange(w http.ResponseWriter, r *http.Request) {
+	var patch rangePatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	rg, err := updateRange(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rg)
+}
+
+// HandleAPIDeleteRange handles DELETE /api/v1/ranges/{id}. The addresses
+// inside the range are kept.
+func HandleAPIDeleteRange(w http.ResponseWriter, r *http.Request) {
+	if err := deleteRange(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIRanges(t *testing.T) {
+	cleanDB(t)
+	id := createTestSubnet(t, "10.30.0.0/24")
+	base := "/api/v1/subnets/" + id + "/ranges"
+
+	// Create a DHCP pool and a static block
+	w := serveAPI(t, HandleAPICreateRange, http.MethodPost, base,
+		`{"start_address": "10.30.0.100", "end_address": "10.30.0.102", "role": "dhcp", "description": "pool"}`, "id", id)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var dhcp models.IPRange
+	decodeBody(t, w, &dhcp)
+	if dhcp.Role != models.RangeRoleDHCP || dhcp.Size.Int64() != 3 || dhcp.Used != 0 {
+		t.Errorf("unexpected range: %+v", dhcp)
+	}
+	w = serveAPI(t, HandleAPICreateRange, http.MethodPost, base,
+		`{"start_address": "10.30.0.10", "end_address": "10.30.0.19", "role": "static"}`, "id", id)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create static: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var static models.IPRange
+	decodeBody(t, w, &static)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"overlap", `{"start_address": "10.30.0.90", "end_address": "10.30.0.100", "role": "reserved"}`, http.StatusConflict, "range_overlap"},
+		{"outside subnet", `{"start_address": "10.31.0.1", "end_address": "10.31.0.9", "role": "static"}`, http.StatusUnprocessableEntity, "address_outside_subnet"},
+		{"reversed", `{"start_address": "10.30.0.50", "end_address": "10.30.0.40", "role": "static"}`, http.StatusUnprocessableEntity, "invalid_range"},
+		{"invalid role", `{"start_address": "10.30.0.50", "end_address": "10.30.0.60", "role": "pool"}`, http.StatusUnprocessableEntity, "invalid_range_role"},
+		{"missing end", `{"start_address": "10.30.0.50", "role": "static"}`, http.StatusUnprocessableEntity, "missing_field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAPI(t, HandleAPICreateRange, http.MethodPost, base, tt.body, "id", id)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d; body: %s", tt.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tt.code {
+				t.Errorf("expected error code %q, got %q", tt.code, body.Error.Code)
+			}
+		})
+	}
+
+	// List in address order
+	w = serveAPI(t, HandleAPIListRanges, http.MethodGet, base, "", "id", id)
+	var list listResponse[models.IPRange]
+	decodeBody(t, w, &list)
+	if w.Code != http.StatusOK || len(list.Items) != 2 || list.Items[0].ID != static.ID {
+		t.Errorf("list: got %d %+v", w.Code, list.Items)
+	}
+
+	// Next free from the DHCP pool lands inside it until it is full
+	next := "/api/v1/subnets/" + id + "/ips/next"
+	for _, want := range []string{"10.30.0.100", "10.30.0.101", "10.30.0.102"} {
+		w = serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, next, `{"range_id": "`+dhcp.ID.String()+`"}`, "id", id)
+		var ip models.IP
+		decodeBody(t, w, &ip)
+		if w.Code != http.StatusCreated || ip.Address != want {
+			t.Fatalf("next in range: expected %s, got %d %+v", want, w.Code, ip)
+		}
+	}
+	w = serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, next, `{"range_id": "`+dhcp.ID.String()+`"}`, "id", id)
+	var body apiErrorBody
+	decodeBody(t, w, &body)
+	if w.Code != http.StatusConflict || body.Error.Code != "range_full" {
+		t.Errorf("full range: got %d %q", w.Code, body.Error.Code)
+	}
+	w = serveAPI(t, HandleAPIGetRange, http.MethodGet, "/api/v1/ranges/"+dhcp.ID.String(), "", "id", dhcp.ID.String())
+	decodeBody(t, w, &dhcp)
+	if dhcp.Used != 3 {
+		t.Errorf("used: expected 3, got %d", dhcp.Used)
+	}
+
+	// A range of another subnet is not accepted
+	other := createTestSubnet(t, "10.31.0.0/24")
+	w = serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, "/api/v1/subnets/"+other+"/ips/next", `{"range_id": "`+static.ID.String()+`"}`, "id", other)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("foreign range: expected 422, got %d", w.Code)
+	}
+
+	// Grow the static block into the pool, then shrink it back
+	w = serveAPI(t, HandleAPIUpdateRange, http.MethodPatch, "/api/v1/ranges/"+static.ID.String(), `{"end_address": "10.30.0.100"}`, "id", static.ID.String())
+	if w.Code != http.StatusConflict {
+		t.Errorf("update overlap: expected 409, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIUpdateRange, http.MethodPatch, "/api/v1/ranges/"+static.ID.String(), `{"end_address": "10.30.0.29", "description": "servers"}`, "id", static.ID.String())
+	decodeBody(t, w, &static)
+	if w.Code != http.StatusOK || static.EndAddress != "10.30.0.29" || static.Size.Int64() != 20 || static.Description != "servers" {
+		t.Errorf("update: got %d %+v", w.Code, static)
+	}
+
+	// Delete keeps the addresses
+	w = serveAPI(t, HandleAPIDeleteRange, http.MethodDelete, "/api/v1/ranges/"+dhcp.ID.String(), "", "id", dhcp.ID.String())
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIGetIP, http.MethodGet, "/api/v1/subnets/"+id+"/ips/10.30.0.100", "", "id", id, "address", "10.30.0.100")
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if ip.Status != "allocated" {
+		t.Errorf("address after range delete: %+v", ip)
+	}
+	w = serveAPI(t, HandleAPIGetRange, http.MethodGet, "/api/v1/ranges/"+dhcp.ID.String(), "", "id", dhcp.ID.String())
+	if w.Code != http.StatusNotFound {
+		t.Errorf("get deleted: expected 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPISearchMACs handles GET /api/v1/mac-addresses?q=: the addresses and
+// interfaces whose MAC contains q. q may be a complete MAC or part of one in
+// any notation, e.g. "00-50-56" or "0050.56aa.bbcc".
+func HandleAPISearchMACs(w http.ResponseWriter, r *http.Request) {
+	result, err := searchMACs(r.Context(), strings.TrimSpace(r.URL.Query().Get("q")))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if result.IPs == nil {
+		result.IPs = []models.IP{}
+	}
+	if result.Interfaces == nil {
+		result.Interfaces = []models.Interface{}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleAPIListDuplicateMACs handles GET /api/v1/mac-addresses/duplicates:
+// the MAC addresses recorded on more than one address.
+func HandleAPIListDuplicateMACs(w http.ResponseWriter, r *http.Request) {
+	duplicates, err := duplicateMACs(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if duplicates == nil {
+		duplicates = []models.MACDuplicate{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.MACDuplicate]{Items: duplicates})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestMACSearchDigits(t *testing.T) {
+	tests := []struct {
+		query, digits string
+		ok            bool
+	}{
+		{"00:50:56:AA:BB:CC", "005056aabbcc", true},
+		{"00-50-56", "005056", true},
+		{"0050.56aa.bbcc", "005056aabbcc", true},
+		{"aa", "aa", true},
+		{"", "", false},
+		{"00:50:5g", "", false},
+		{"00:50:56:aa:bb:cc:dd", "", false},
+	}
+	for _, tt := range tests {
+		digits, err := macSearchDigits(tt.query)
+		if digits != tt.digits || (err == nil) != tt.ok {
+			t.Errorf("macSearchDigits(%q) = %q, %v; want %q, ok %v", tt.query, digits, err, tt.digits, tt.ok)
+		}
+	}
+}
+
+func TestAPIMACAddresses(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.0.0.0/24", "name": "lan"}`)
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	id := lan.ID.String()
+
+	// MACs are normalized from any notation and carry the vendor of their prefix.
+	w = serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips",
+		`{"address": "10.0.0.5", "mac_address": "0050.56AA.BBCC"}`, "id", id)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("allocate: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if ip.MACAddress == nil || *ip.MACAddress != "00:50:56:aa:bb:cc" || ip.MACVendor != "VMware" || ip.MACConflicts != 0 {
+		t.Errorf("allocated IP: expected MAC 00:50:56:aa:bb:cc by VMware without conflicts, got %+v", ip)
+	}
+
+	// The same MAC on a second address is accepted but reported.
+	w = serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips/next",
+		`{"mac_address": "00-50-56-aa-bb-cc"}`, "id", id)
+	var second models.IP
+	decodeBody(t, w, &second)
+	if w.Code != http.StatusCreated || second.MACConflicts != 1 {
+		t.Errorf("duplicate MAC: expected 201 with 1 conflict, got %d %+v", w.Code, second)
+	}
+	w = serveAPI(t, HandleAPIListDuplicateMACs, http.MethodGet, "/api/v1/mac-addresses/duplicates", "")
+	var duplicates listResponse[models.MACDuplicate]
+	decodeBody(t, w, &duplicates)
+	if len(duplicates.Items) != 1 || len(duplicates.Items[0].IPs) != 2 {
+		t.Fatalf("duplicates: expected one MAC on 2 addresses, got %+v", duplicates.Items)
+	}
+
+	w = serveAPI(t, HandleAPISearchMACs, http.MethodGet, "/api/v1/mac-addresses?q=56aa.bb", "")
+	var found macSearchResult
+	decodeBody(t, w, &found)
+	if len(found.IPs) != 2 {
+		t.Errorf("partial search: expected 2 addresses, got %+v", found.IPs)
+	}
+
+	// An empty MAC removes it.
+	w = serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, "/api/v1/subnets/"+id+"/ips/"+second.Address,
+		`{"mac_address": ""}`, "id", id, "address", second.Address)
+	decodeBody(t, w, &second)
+	if second.MACAddress != nil {
+		t.Errorf("cleared MAC: got %v", *second.MACAddress)
+	}
+	w = serveAPI(t, HandleAPISearchMACs, http.MethodGet, "/api/v1/mac-addresses?q=00:50:56:aa:bb:cc", "")
+	decodeBody(t, w, &found)
+	if len(found.IPs) != 1 || found.IPs[0].Address != "10.0.0.5" || found.IPs[0].MACConflicts != 0 {
+		t.Errorf("exact search: expected only 10.0.0.5, got %+v", found.IPs)
+	}
+
+	if w := serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips",
+		`{"address": "10.0.0.9", "mac_address": "00:50:56"}`, "id", id); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid MAC: expected 422, got %d", w.Code)
+	}
+	if w := serveAPI(t, HandleAPISearchMACs, http.MethodGet, "/api/v1/mac-addresses?q=zz", ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid search: expected 422, got %d", w.Code)
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPISearch handles GET /api/v1/search?q=: the subnets, addresses,
+// devices and VLANs matching q (see search). An IP address in q also returns
+// the subnets containing it, even when it is not recorded.
+func HandleAPISearch(w http.ResponseWriter, r *http.Request) {
+	results, err := search(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if results.Subnets == nil {
+		results.Subnets = []models.Subnet{}
+	}
+	if results.IPs == nil {
+		results.IPs = []models.IP{}
+	}
+	if results.Devices == nil {
+		results.Devices = []models.Device{}
+	}
+	if results.VLANs == nil {
+		results.VLANs = []models.VLAN{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPISearch(t *testing.T) {
+	cleanDB(t)
+
+	for _, body := range []string{
+		`{"cidr": "10.3.0.0/16", "name": "datacenter", "kind": "container"}`,
+		`{"cidr": "10.3.4.0/24", "name": "databases"}`,
+		`{"cidr": "10.3.5.0/24", "name": "web"}`,
+	} {
+		if w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body); w.Code != http.StatusCreated {
+			t.Fatalf("create subnet %s: expected 201, got %d; body: %s", body, w.Code, w.Body.String())
+		}
+	}
+	w := serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets", "")
+	var subnets listResponse[models.Subnet]
+	decodeBody(t, w, &subnets)
+	var dbID string
+	for _, s := range subnets.Items {
+		if s.Name == "databases" {
+			dbID = s.ID.String()
+		}
+	}
+	serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+dbID+"/ips",
+		`{"address": "10.3.4.10", "hostname": "db-primary", "mac_address": "00:50:56:12:34:56"}`, "id", dbID)
+	serveAPI(t, HandleAPICreateDevice, http.MethodPost, "/api/v1/devices", `{"name": "db-switch", "description": "Top of rack"}`)
+	serveAPI(t, HandleAPICreateVLAN, http.MethodPost, "/api/v1/vlans", `{"vid": 40, "name": "storage", "description": "DB replication"}`)
+
+	tests := []struct {
+		query                        string
+		address                      string
+		subnets, ips, devices, vlans int
+	}{
+		{"10.3.4.17", "10.3.4.17", 2, 0, 0, 0}, // unrecorded, in databases and datacenter
+		{"10.3.4.10", "10.3.4.10", 2, 1, 0, 0},
+		{"192.0.2.1", "192.0.2.1", 0, 0, 0, 0},
+		{"10.3.4.0/24", "", 2, 0, 0, 0}, // itself and its container
+		{"10.3.0.0/16", "", 3, 0, 0, 0}, // itself and the subnets inside it
+		{"DB-PRIM", "", 0, 1, 0, 0},
+		{"data", "", 2, 0, 0, 0},
+		{"db", "", 0, 1, 1, 1}, // db-primary, db-switch and the VLAN by its description
+		{"0050.5612", "", 0, 1, 0, 0},
+		{"50%", "", 0, 0, 0, 0}, // wildcards match literally
+		{"", "", 0, 0, 0, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			w := serveAPI(t, HandleAPISearch, http.MethodGet, "/api/v1/search?q="+url.QueryEscape(tc.query), "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d; body: %s", w.Code, w.Body.String())
+			}
+			var got models.SearchResults
+			decodeBody(t, w, &got)
+			if got.Address != tc.address || len(got.Subnets) != tc.subnets || len(got.IPs) != tc.ips ||
+				len(got.Devices) != tc.devices || len(got.VLANs) != tc.vlans {
+				t.Errorf("expected address %q, %d subnets, %d IPs, %d devices and %d VLANs, got %+v",
+					tc.address, tc.subnets, tc.ips, tc.devices, tc.vlans, got)
+			}
+		})
+	}
+
+	// The most specific subnet containing an address comes first.
+	w = serveAPI(t, HandleAPISearch, http.MethodGet, "/api/v1/search?q=10.3.4.17", "")
+	var got models.SearchResults
+	decodeBody(t, w, &got)
+	if len(got.Subnets) == 0 || got.Subnets[0].CIDR != "10.3.4.0/24" {
+		t.Errorf("containing subnets: expected 10.3.4.0/24 first, got %+v", got.Subnets)
+	}
+}
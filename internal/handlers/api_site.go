@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListRegions handles GET /api/v1/regions.
+func HandleAPIListRegions(w http.ResponseWriter, r *http.Request) {
+	regions, err := listRegions(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if regions == nil {
+		regions = []models.Region{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Region]{Items: regions})
+}
+
+// HandleAPICreateRegion handles POST /api/v1/regions with a body such as
+// {"name": "Europe", "description": "EU datacenters"}.
+func HandleAPICreateRegion(w http.ResponseWriter, r *http.Request) {
+	var req models.Region
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	g, err := createRegion(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/regions/"+g.ID.String())
+	writeJSON(w, http.StatusCreated, g)
+}
+
+// HandleAPIGetRegion handles GET /api/v1/regions/{id}.
+func HandleAPIGetRegion(w http.ResponseWriter, r *http.Request) {
+	g, err := getRegion(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// HandleAPIUpdateRegion handles PATCH /api/v1/regions/{id}.
+func HandleAPIUpdateRegion(w http.ResponseWriter, r *http.Request) {
+	var patch regionPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	g, err := updateRegion(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// HandleAPIDeleteRegion handles DELETE /api/v1/regions/{id}. Regions that
+// still hold sites cannot be deleted.
+func HandleAPIDeleteRegion(w http.ResponseWriter, r *http.Request) {
+	if err := deleteRegion(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPIListSites handles GET /api/v1/sites. Each site includes its
+// locations and the utilization of its networks.
+// Query parameters: region (a region ID).
+func HandleAPIListSites(w http.ResponseWriter, r *http.Request) {
+	sites, err := listSites(r.Context(), r.URL.Query().Get("region"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if sites == nil {
+		sites = []models.Site{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Site]{Items: sites})
+}
+
+// HandleAPICreateSite handles POST /api/v1/sites with a body such as
+// {"region_id": "...", "name": "fra1", "description": "Frankfurt"}.
+func HandleAPICreateSite(w http.ResponseWriter, r *http.Request) {
+	var req models.Site
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	s, err := createSite(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/sites/"+s.ID.String())
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// HandleAPIGetSite handles GET /api/v1/sites/{id}.
+func HandleAPIGetSite(w http.ResponseWriter, r *http.Request) {
+	s, err := getSite(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// HandleAPIUpdateSite handles PATCH /api/v1/sites/{id}.
+func HandleAPIUpdateSite(w http.ResponseWriter, r *http.Request) {
+	var patch sitePatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	s, err := updateSite(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// HandleAPIDeleteSite handles DELETE /api/v1/sites/{id}. Sites that still
+// hold subnets or VLANs cannot be deleted; their locations are deleted too.
+func HandleAPIDeleteSite(w http.ResponseWriter, r *http.Request) {
+	if err := deleteSite(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPIListLocations handles GET /api/v1/locations.
+// Query parameters: site (a site ID).
+func HandleAPIListLocations(w http.ResponseWriter, r *http.Request) {
+	locations, err := listLocations(r.Context(), r.URL.Query().Get("site"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if locations == nil {
+		locations = []models.Location{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Location]{Items: locations})
+}
+
+// HandleAPICreateLocation handles POST /api/v1/locations with a body such as
+// {"site_id": "...", "name": "Room 1.01"}.
+func HandleAPICreateLocation(w http.ResponseWriter, r *http.Request) {
+	var req models.Location
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	l, err := createLocation(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/locations/"+l.ID.String())
+	writeJSON(w, http.StatusCreated, l)
+}
+
+// HandleAPIGetLocation handles GET /api/v1/locations/{id}.
+func HandleAPIGetLocation(w http.ResponseWriter, r *http.Request) {
+	l, err := getLocation(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+// HandleAPIUpdateLocation handles PATCH /api/v1/locations/{id}.
+func HandleAPIUpdateLocation(w http.ResponseWriter, r *http.Request) {
+	var patch locationPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	l, err := updateLocation(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
+
+// HandleAPIDeleteLocation handles DELETE /api/v1/locations/{id}.
+func HandleAPIDeleteLocation(w http.ResponseWriter, r *http.Request) {
+	if err := deleteLocation(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPISiteLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateRegion, http.MethodPost, "/api/v1/regions", `{"name": "Europe"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create region: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var region models.Region
+	decodeBody(t, w, &region)
+	regionID := region.ID.String()
+
+	w = serveAPI(t, HandleAPICreateSite, http.MethodPost, "/api/v1/sites", `{"region_id": "`+regionID+`", "name": "fra1"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create site: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var site models.Site
+	decodeBody(t, w, &site)
+	siteID := site.ID.String()
+
+	w = serveAPI(t, HandleAPICreateLocation, http.MethodPost, "/api/v1/locations", `{"site_id": "`+siteID+`", "name": "Room 1"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create location: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	w = serveAPI(t, HandleAPICreateLocation, http.MethodPost, "/api/v1/locations", `{"site_id": "`+siteID+`", "name": "Room 1"}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate location: expected 409, got %d", w.Code)
+	}
+
+	// Two networks at the site, a /30 and a /29, plus one subnet elsewhere.
+	var subnet models.Subnet
+	for _, body := range []string{
+		`{"cidr": "10.0.0.0/30", "name": "p2p", "site_id": "` + siteID + `"}`,
+		`{"cidr": "10.0.1.0/29", "name": "mgmt", "site_id": "` + siteID + `"}`,
+		`{"cidr": "10.9.0.0/24", "name": "elsewhere"}`,
+	} {
+		w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create subnet %s: expected 201, got %d; body: %s", body, w.Code, w.Body.String())
+		}
+		decodeBody(t, w, &subnet)
+		if subnet.Name == "p2p" {
+			id := subnet.ID.String()
+			w := serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips", `{"address": "10.0.0.1"}`, "id", id)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("allocate: expected 201, got %d", w.Code)
+			}
+		}
+	}
+
+	w = serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets?site="+siteID, "")
+	var list listResponse[models.Subnet]
+	decodeBody(t, w, &list)
+	if len(list.Items) != 2 {
+		t.Errorf("list by site: expected 2 subnets, got %d", len(list.Items))
+	}
+
+	w = serveAPI(t, HandleAPIGetSite, http.MethodGet, "/api/v1/sites/"+siteID, "", "id", siteID)
+	decodeBody(t, w, &site)
+	if w.Code != http.StatusOK || site.SubnetCount != 2 || len(site.Locations) != 1 {
+		t.Fatalf("get site: got %d %+v", w.Code, site)
+	}
+	// 2 hosts in the /30 plus 6 in the /29, one of them used.
+	if site.Utilization.Used.Int64() != 1 || site.Utilization.Total.Int64() != 8 {
+		t.Errorf("unexpected utilization %s/%s", site.Utilization.Used, site.Utilization.Total)
+	}
+
+	w = serveAPI(t, HandleAPIDeleteSite, http.MethodDelete, "/api/v1/sites/"+siteID, "", "id", siteID)
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete site in use: expected 409, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIDeleteRegion, http.MethodDelete, "/api/v1/regions/"+regionID, "", "id", regionID)
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete region in use: expected 409, got %d", w.Code)
+	}
+}
+
+func TestAPISiteErrors(t *testing.T) {
+	cleanDB(t)
+
+	tests := []struct {
+		name   string
+		h      http.HandlerFunc
+		body   string
+		status int
+		code   string
+	}{
+		{"region without name", HandleAPICreateRegion, `{}`, http.StatusUnprocessableEntity, "missing_field"},
+		{"site without region", HandleAPICreateSite, `{"name": "x"}`, http.StatusUnprocessableEntity, "missing_field"},
+		{"site in unknown region", HandleAPICreateSite, `{"name": "x", "region_id": "00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, "region_not_found"},
+		{"location at unknown site", HandleAPICreateLocation, `{"name": "x", "site_id": "00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, "site_not_found"},
+		{"subnet at unknown site", HandleAPICreateSubnet, `{"cidr": "10.0.0.0/24", "name": "x", "site_id": "00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, "site_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAPI(t, tt.h, http.MethodPost, "/api/v1/", tt.body)
+			var resp apiErrorBody
+			decodeBody(t, w, &resp)
+			if w.Code != tt.status || resp.Error.Code != tt.code {
+				t.Errorf("expected %d %s, got %d %s", tt.status, tt.code, w.Code, resp.Error.Code)
+			}
+		})
+	}
+}
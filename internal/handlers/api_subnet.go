@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListSubnets handles GET /api/v1/subnets.
+// Query parameters: vrf (a VRF ID, or "global" for subnets outside any VRF),
+// site (a site ID, or "none" for subnets without a site),
+// tenant (a tenant ID, or "none" for subnets without a tenant),
+// tag (a tag ID; only subnets carrying it),
+// cf_<name> (only subnets whose custom field <name> has this value),
+// sort ("utilization" for the fullest subnets first).
+// Each subnet carries its utilization stats.
+func HandleAPIListSubnets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := subnetFilter{VRF: q.Get("vrf"), Site: q.Get("site"), Tenant: q.Get("tenant"), Tag: q.Get("tag")}
+	fields, err := customFieldFilters(r.Context(), models.CustomFieldObjectSubnet, q)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	filter.Fields = fields
+	sort, err := parseSubnetSort(q.Get("sort"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	subnets, err := listSubnets(r.Context(), filter)
+	if err == nil {
+		err = fillSubnetStats(r.Context(), subnets)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if sort == sortUtilization {
+		slices.SortStableFunc(subnets, byUtilization)
+	}
+	if subnets == nil {
+		subnets = []models.Subnet{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Subnet]{Items: subnets})
+}
+
+// HandleAPICreateSubnet handles POST /api/v1/subnets with a body such as
+// {"cidr": "10.0.0.0/24", "name": "Production LAN"}. "kind" may be set to
+// "container" for a prefix that holds child subnets instead of host addresses,
+// and "vrf_id" places the subnet in a VRF instead of the global table.
+// "reservation_policy" (e.g. "gateway=first,last=1", or "none") sets the
+// addresses of a network reserved up front; it defaults to RESERVATION_POLICY.
+func HandleAPICreateSubnet(w http.ResponseWriter, r *http.Request) {
+	var req models.Subnet
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	s, err := createSubnet(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/subnets/"+s.ID.String())
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// HandleAPIGetSubnet handles GET /api/v1/subnets/{id}, with the subnet's
+// utilization stats.
+func HandleAPIGetSubnet(w http.ResponseWriter, r *http.Request) {
+	s, err := getSubnet(r.Context(), r.PathValue("id"))
+	if err == nil {
+		s, err = subnetWithStats(r.Context(), s)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// HandleAPIUpdateSubnet handles PATCH /api/v1/subnets/{id}.
+func HandleAPIUpdateSubnet(w http.ResponseWriter, r *http.Request) {
+	var patch subnetPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	s, err := updateSubnet(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// HandleAPIDeleteSubnet handles DELETE /api/v1/subnets/{id}.
+func HandleAPIDeleteSubnet(w http.ResponseWriter, r *http.Request) {
+	if err := deleteSubnet(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// carveRequest is the body of POST /api/v1/subnets/{id}/carve.
+type carveRequest struct {
+	PrefixLength int    `json:"prefix_length"`
+	Name         string `json:"name"`
+	Kind         string `json:"kind"` // network (default) or container
+}
+
+// HandleAPICarveSubnet handles POST /api/v1/subnets/{id}/carve with a body such
+// as {"prefix_length": 26, "name": "app-tier"}. The lowest free block of that
+// size inside the container is created and returned.
+func HandleAPICarveSubnet(w http.ResponseWriter, r *http.Request) {
+	var req carveRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	s, err := carveSubnet(r.Context(), r.PathValue("id"), req.PrefixLength, req.Name, req.Kind)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/subnets/"+s.ID.String())
+	writeJSON(w, http.StatusCreated, s)
+}
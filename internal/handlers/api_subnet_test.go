@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPISubnetLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	// Create
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.1.0.0/24", "name": "api"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var created models.Subnet
+	decodeBody(t, w, &created)
+	id := created.ID.String()
+	if created.CIDR != "10.1.0.0/24" || created.Name != "api" {
+		t.Errorf("unexpected subnet: %+v", created)
+	}
+	if loc := w.Header().Get("Location"); loc != "/api/v1/subnets/"+id {
+		t.Errorf("unexpected Location header %q", loc)
+	}
+
+	// List
+	w = serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets", "")
+	var list listResponse[models.Subnet]
+	decodeBody(t, w, &list)
+	if w.Code != http.StatusOK || len(list.Items) != 1 {
+		t.Fatalf("list: expected 200 with 1 item, got %d with %d", w.Code, len(list.Items))
+	}
+
+	// Update
+	w = serveAPI(t, HandleAPIUpdateSubnet, http.MethodPatch, "/api/v1/subnets/"+id, `{"name": "renamed"}`, "id", id)
+	var updated models.Subnet
+	decodeBody(t, w, &updated)
+	if w.Code != http.StatusOK || updated.Name != "renamed" || updated.CIDR != "10.1.0.0/24" {
+		t.Errorf("update: got %d %+v", w.Code, updated)
+	}
+
+	// Get
+	w = serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+id, "", "id", id)
+	if w.Code != http.StatusOK {
+		t.Errorf("get: expected 200, got %d", w.Code)
+	}
+
+	// Delete, then get again
+	w = serveAPI(t, HandleAPIDeleteSubnet, http.MethodDelete, "/api/v1/subnets/"+id, "", "id", id)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+id, "", "id", id)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("get after delete: expected 404, got %d", w.Code)
+	}
+}
+
+func TestAPISubnetErrors(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.2.0.0/16", "name": "existing"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("setup: expected 201, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name   string
+		h      http.HandlerFunc
+		method string
+		body   string
+		id     string
+		status int
+		code   string
+	}{
+		{"malformed json", HandleAPICreateSubnet, http.MethodPost, `{"cidr":`, "", http.StatusBadRequest, "invalid_json"},
+		{"unknown field", HandleAPICreateSubnet, http.MethodPost, `{"cidr": "10.3.0.0/24", "name": "x", "vlan": 5}`, "", http.StatusBadRequest, "invalid_json"},
+		{"missing name", HandleAPICreateSubnet, http.MethodPost, `{"cidr": "10.3.0.0/24"}`, "", http.StatusUnprocessableEntity, "missing_field"},
+		{"invalid cidr", HandleAPICreateSubnet, http.MethodPost, `{"cidr": "10.3.0.0/33", "name": "x"}`, "", http.StatusUnprocessableEntity, "invalid_cidr"},
+		{"overlap", HandleAPICreateSubnet, http.MethodPost, `{"cidr": "10.2.5.0/24", "name": "x"}`, "", http.StatusConflict, "subnet_overlap"},
+		{"unknown id", HandleAPIGetSubnet, http.MethodGet, "", "00000000-0000-0000-0000-000000000000", http.StatusNotFound, "subnet_not_found"},
+		{"malformed id", HandleAPIGetSubnet, http.MethodGet, "", "not-a-uuid", http.StatusNotFound, "subnet_not_found"},
+		{"delete unknown", HandleAPIDeleteSubnet, http.MethodDelete, "", "00000000-0000-0000-0000-000000000000", http.StatusNotFound, "subnet_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAPI(t, tt.h, tt.method, "/api/v1/subnets", tt.body, "id", tt.id)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d; body: %s", tt.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tt.code {
+				t.Errorf("expected error code %q, got %q", tt.code, body.Error.Code)
+			}
+		})
+	}
+}
+
+func TestAPICarveSubnet_Concurrent(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.30.0.0/24", "name": "pool", "kind": "container"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("setup: expected 201, got %d", w.Code)
+	}
+	var parent models.Subnet
+	decodeBody(t, w, &parent)
+	id := parent.ID.String()
+
+	// Only four /26 blocks fit into the /24.
+	const callers = 12
+	codes := make(chan int, callers)
+	cidrs := make(chan string, callers)
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serveAPI(t, HandleAPICarveSubnet, http.MethodPost, "/api/v1/subnets/"+id+"/carve", `{"prefix_length": 26, "name": "carved"}`, "id", id)
+			codes <- w.Code
+			var s models.Subnet
+			if w.Code == http.StatusCreated && json.Unmarshal(w.Body.Bytes(), &s) == nil {
+				cidrs <- s.CIDR
+			}
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	close(cidrs)
+
+	counts := make(map[int]int)
+	for c := range codes {
+		counts[c]++
+	}
+	if counts[http.StatusCreated] != 4 || counts[http.StatusConflict] != callers-4 {
+		t.Errorf("expected 4 created and %d conflicts, got %v", callers-4, counts)
+	}
+	seen := make(map[string]bool)
+	for c := range cidrs {
+		if seen[c] {
+			t.Errorf("block %s was carved twice", c)
+		}
+		seen[c] = true
+	}
+	for _, want := range []string{"10.30.0.0/26", "10.30.0.64/26", "10.30.0.128/26", "10.30.0.192/26"} {
+		if !seen[want] {
+			t.Errorf("expected %s to be carved", want)
+		}
+	}
+
+	w = serveAPI(t, HandleAPICarveSubnet, http.MethodPost, "/api/v1/subnets/"+id+"/carve", `{"prefix_length": 26, "name": "x"}`, "id", id)
+	var body apiErrorBody
+	decodeBody(t, w, &body)
+	if body.Error.Code != "no_free_prefix" {
+		t.Errorf("expected no_free_prefix, got %q", body.Error.Code)
+	}
+}
+
+func TestAPISubnetUtilization(t *testing.T) {
+	cleanDB(t)
+
+	ids := make(map[string]string)
+	for _, body := range []string{
+		`{"cidr": "10.40.0.0/16", "name": "pool", "kind": "container"}`,
+		`{"cidr": "10.40.0.0/29", "name": "small"}`, // 6 hosts
+		`{"cidr": "10.40.1.0/24", "name": "large"}`, // 254 hosts
+	} {
+		w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body)
+		var s models.Subnet
+		decodeBody(t, w, &s)
+		ids[s.Name] = s.ID.String()
+	}
+	small := ids["small"]
+	for _, a := range []string{"10.40.0.1", "10.40.0.2", "10.40.0.3", "10.40.0.4"} {
+		serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+small+"/ips", `{"address": "`+a+`"}`, "id", small)
+	}
+	serveAPI(t, HandleAPIReserveIP, http.MethodPost, "/api/v1/subnets/"+small+"/ips/10.40.0.5/reserve", "", "id", small, "address", "10.40.0.5")
+
+	w := serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+small, "", "id", small)
+	var s models.Subnet
+	decodeBody(t, w, &s)
+	if st := s.Stats; st == nil || st.Allocated != 4 || st.Reserved != 1 || st.Available.Int64() != 1 || st.Total.Int64() != 6 ||
+		st.Level != models.UtilizationWarning {
+		t.Fatalf("small: expected 4 allocated, 1 reserved, 1 available of 6 at warning level, got %+v", st)
+	}
+
+	// A container sums up the networks inside it.
+	w = serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+ids["pool"], "", "id", ids["pool"])
+	decodeBody(t, w, &s)
+	if st := s.Stats; st == nil || st.Allocated != 4 || st.Total.Int64() != 260 || st.Level != models.UtilizationOK {
+		t.Errorf("pool: expected 4 allocated of 260, got %+v", st)
+	}
+
+	w = serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets?sort=utilization", "")
+	var list listResponse[models.Subnet]
+	decodeBody(t, w, &list)
+	if len(list.Items) != 3 || list.Items[0].Name != "small" || list.Items[2].Name != "large" {
+		t.Errorf("sorted by utilization: expected small, pool, large, got %+v", list.Items)
+	}
+
+	if w := serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets?sort=name", ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid sort: expected 422, got %d", w.Code)
+	}
+}
+
+func TestUtilizationLevel(t *testing.T) {
+	th := thresholds{Warning: 80, Critical: 95}
+	for percent, want := range map[float64]string{
+		0:     models.UtilizationOK,
+		79.9:  models.UtilizationOK,
+		80:    models.UtilizationWarning,
+		94.99: models.UtilizationWarning,
+		95:    models.UtilizationCritical,
+		100:   models.UtilizationCritical,
+	} {
+		if got := th.level(percent); got != want {
+			t.Errorf("level(%g) = %s, want %s", percent, got, want)
+		}
+	}
+}
+
+func TestAPISubnetReservationPolicy(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.41.0.0/24", "name": "lan", "reservation_policy": "gateway=first, first=3, last=1"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var s models.Subnet
+	decodeBody(t, w, &s)
+	if s.Gateway != "10.41.0.1" || s.ReservationPolicy != "gateway=first,first=3,last=1" {
+		t.Errorf("expected gateway 10.41.0.1 and the canonical policy, got %q %q", s.Gateway, s.ReservationPolicy)
+	}
+	id := s.ID.String()
+
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, "/api/v1/subnets/"+id+"/ips?status=reserved", "", "id", id)
+	var list ipListResponse
+	decodeBody(t, w, &list)
+	want := map[string]string{
+		"10.41.0.1":   "gateway=first",
+		"10.41.0.2":   "first=3",
+		"10.41.0.3":   "first=3",
+		"10.41.0.254": "last=1",
+	}
+	if len(list.Items) != len(want) {
+		t.Fatalf("expected %d reserved addresses, got %+v", len(want), list.Items)
+	}
+	for _, ip := range list.Items {
+		if want[ip.Address] != ip.ReservationReason {
+			t.Errorf("%s: expected reason %q, got %q", ip.Address, want[ip.Address], ip.ReservationReason)
+		}
+	}
+
+	// Next free skips the reserved addresses.
+	w = serveAPI(t, HandleAPIAllocateNextIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips/next", "", "id", id)
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if ip.Address != "10.41.0.4" || ip.ReservationReason != "" {
+		t.Errorf("next free: expected 10.41.0.4, got %+v", ip)
+	}
+
+	// Unreserving the gateway frees it, so the subnet has no gateway anymore.
+	serveAPI(t, HandleAPIUnreserveIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips/10.41.0.1/unreserve", "",
+		"id", id, "address", "10.41.0.1")
+	w = serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+id, "", "id", id)
+	decodeBody(t, w, &s)
+	if s.Gateway != "" {
+		t.Errorf("after unreserving the gateway: expected no gateway, got %q", s.Gateway)
+	}
+	w = serveAPI(t, HandleAPIListAudit, http.MethodGet, "/api/v1/audit?type=subnet&action=update&subnet="+id, "")
+	var audit auditListResponse
+	decodeBody(t, w, &audit)
+	if audit.Total != 1 {
+		t.Errorf("after unreserving the gateway: expected the subnet update to be recorded, got %+v", audit.Items)
+	}
+
+	// "none" reserves nothing; containers ignore the policy.
+	for _, body := range []string{
+		`{"cidr": "10.42.0.0/24", "name": "bare", "reservation_policy": "none"}`,
+		`{"cidr": "10.43.0.0/16", "name": "pool", "kind": "container", "reservation_policy": "gateway=first"}`,
+	} {
+		w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body)
+		decodeBody(t, w, &s)
+		if w.Code != http.StatusCreated || s.Gateway != "" || s.ReservationPolicy != "" {
+			t.Errorf("%s: expected no reservations, got %d %+v", body, w.Code, s)
+		}
+	}
+
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.44.0.0/24", "name": "bad", "reservation_policy": "gateway=middle"}`)
+	var body apiErrorBody
+	decodeBody(t, w, &body)
+	if w.Code != http.StatusUnprocessableEntity || body.Error.Code != "invalid_reservation_policy" {
+		t.Errorf("invalid policy: expected 422 invalid_reservation_policy, got %d %q", w.Code, body.Error.Code)
+	}
+}
+
+func TestReservationPolicyPlan(t *testing.T) {
+	tests := []struct {
+		policy  string
+		prefix  string
+		gateway string
+		want    []string
+	}{
+		{"", "10.0.0.0/24", "", nil},
+		{"gateway", "10.0.0.0/24", "10.0.0.1", []string{"10.0.0.1"}},
+		{"gateway=last,first=2", "10.0.0.0/24", "10.0.0.254", []string{"10.0.0.1", "10.0.0.2", "10.0.0.254"}},
+		{"first=2,last=2", "10.0.0.0/30", "", []string{"10.0.0.1", "10.0.0.2"}},
+		{"gateway=first,last=1", "2001:db8::/64", "2001:db8::1", []string{"2001:db8::1", "2001:db8::ffff:ffff:ffff:ffff"}},
+	}
+	for _, tt := range tests {
+		p, err := parseReservationPolicy(tt.policy)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.policy, err)
+		}
+		gateway, reserved := p.plan(netip.MustParsePrefix(tt.prefix))
+		var got []string
+		for _, r := range reserved {
+			got = append(got, r.Addr.String())
+		}
+		if (gateway.IsValid() && gateway.String() != tt.gateway) || (!gateway.IsValid() && tt.gateway != "") || !slices.Equal(got, tt.want) {
+			t.Errorf("%q in %s: got gateway %v and %v, want %s and %v", tt.policy, tt.prefix, gateway, got, tt.gateway, tt.want)
+		}
+	}
+
+	for _, s := range []string{"gateway=middle", "first=-1", "last=x", "first=1000", "dhcp"} {
+		if _, err := parseReservationPolicy(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
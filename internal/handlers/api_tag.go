@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListTags handles GET /api/v1/tags.
+func HandleAPIListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := listTags(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Tag]{Items: tags})
+}
+
+// HandleAPICreateTag handles POST /api/v1/tags with a body such as
+// {"name": "prod", "color": "error", "description": "Production"}.
+// The color defaults to neutral.
+func HandleAPICreateTag(w http.ResponseWriter, r *http.Request) {
+	var req models.Tag
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	t, err := createTag(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/tags/"+t.ID.String())
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// HandleAPIGetTag handles GET /api/v1/tags/{id}.
+func HandleAPIGetTag(w http.ResponseWriter, r *http.Request) {
+	t, err := getTag(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleAPIUpdateTag handles PATCH /api/v1/tags/{id}.
+func HandleAPIUpdateTag(w http.ResponseWriter, r *http.Request) {
+	var patch tagPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	t, err := updateTag(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleAPIDeleteTag handles DELETE /api/v1/tags/{id}. The tag is removed
+// from all subnets and addresses carrying it.
+func HandleAPIDeleteTag(w http.ResponseWriter, r *http.Request) {
+	if err := deleteTag(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPITagObjects handles POST /api/v1/tags/{id}/tag with a body such as
+// {"subnet_ids": ["..."], "ips": [{"subnet_id": "...", "address": "10.0.0.5"}]}.
+// It returns the tag with its new counts.
+func HandleAPITagObjects(w http.ResponseWriter, r *http.Request) {
+	handleAPIBulkTag(w, r, true)
+}
+
+// HandleAPIUntagObjects handles POST /api/v1/tags/{id}/untag, with the same
+// body as HandleAPITagObjects.
+func HandleAPIUntagObjects(w http.ResponseWriter, r *http.Request) {
+	handleAPIBulkTag(w, r, false)
+}
+
+func handleAPIBulkTag(w http.ResponseWriter, r *http.Request, add bool) {
+	var req tagTargets
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	t, err := bulkTag(r.Context(), r.PathValue("id"), req, add)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPITagLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateTag, http.MethodPost, "/api/v1/tags", `{"name": "prod", "color": "error"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create tag: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var prod models.Tag
+	decodeBody(t, w, &prod)
+	if prod.Color != models.TagColorError {
+		t.Errorf("tag color: expected error, got %q", prod.Color)
+	}
+	w = serveAPI(t, HandleAPICreateTag, http.MethodPost, "/api/v1/tags", `{"name": "lab"}`)
+	var lab models.Tag
+	decodeBody(t, w, &lab)
+	if lab.Color != models.TagColorNeutral {
+		t.Errorf("default color: expected neutral, got %q", lab.Color)
+	}
+
+	var subnets []models.Subnet
+	for _, body := range []string{`{"cidr": "10.1.0.0/24", "name": "web"}`, `{"cidr": "10.2.0.0/24", "name": "db"}`} {
+		w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body)
+		var s models.Subnet
+		decodeBody(t, w, &s)
+		subnets = append(subnets, s)
+	}
+	web := subnets[0].ID.String()
+	for _, addr := range []string{"10.1.0.1", "10.1.0.2"} {
+		if w := serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+web+"/ips", `{"address": "`+addr+`"}`, "id", web); w.Code != http.StatusCreated {
+			t.Fatalf("allocate %s: expected 201, got %d", addr, w.Code)
+		}
+	}
+
+	// Tag the web subnet and one of its addresses in one request.
+	pid := prod.ID.String()
+	w = serveAPI(t, HandleAPITagObjects, http.MethodPost, "/api/v1/tags/"+pid+"/tag",
+		`{"subnet_ids": ["`+web+`"], "ips": [{"subnet_id": "`+web+`", "address": "10.1.0.2"}]}`, "id", pid)
+	if w.Code != http.StatusOK {
+		t.Fatalf("tag: expected 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+	decodeBody(t, w, &prod)
+	if prod.SubnetCount != 1 || prod.IPCount != 1 {
+		t.Errorf("tag counts: expected 1 subnet and 1 IP, got %+v", prod)
+	}
+	// Tagging again is a no-op.
+	if w := serveAPI(t, HandleAPITagObjects, http.MethodPost, "/api/v1/tags/"+pid+"/tag", `{"subnet_ids": ["`+web+`"]}`, "id", pid); w.Code != http.StatusOK {
+		t.Errorf("re-tag: expected 200, got %d", w.Code)
+	}
+
+	w = serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets?tag="+pid, "")
+	var list listResponse[models.Subnet]
+	decodeBody(t, w, &list)
+	if len(list.Items) != 1 || list.Items[0].Name != "web" {
+		t.Fatalf("subnets tagged prod: expected only web, got %+v", list.Items)
+	}
+	if tags := list.Items[0].Tags; len(tags) != 1 || tags[0].ID != prod.ID || tags[0].Color != models.TagColorError {
+		t.Errorf("web tags: expected [prod], got %+v", tags)
+	}
+
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, "/api/v1/subnets/"+web+"/ips?tag="+pid, "", "id", web)
+	var page ipListResponse
+	decodeBody(t, w, &page)
+	if len(page.Items) != 1 || page.Items[0].Address != "10.1.0.2" || len(page.Items[0].Tags) != 1 {
+		t.Errorf("IPs tagged prod: expected only 10.1.0.2, got %+v", page.Items)
+	}
+
+	// Untagging removes the tag; deleting a tag detaches it everywhere.
+	w = serveAPI(t, HandleAPIUntagObjects, http.MethodPost, "/api/v1/tags/"+pid+"/untag",
+		`{"ips": [{"subnet_id": "`+web+`", "address": "10.1.0.2"}]}`, "id", pid)
+	decodeBody(t, w, &prod)
+	if w.Code != http.StatusOK || prod.IPCount != 0 || prod.SubnetCount != 1 {
+		t.Errorf("untag: got %d %+v", w.Code, prod)
+	}
+	if w := serveAPI(t, HandleAPIDeleteTag, http.MethodDelete, "/api/v1/tags/"+pid, "", "id", pid); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+web, "", "id", web)
+	var s models.Subnet
+	decodeBody(t, w, &s)
+	if len(s.Tags) != 0 {
+		t.Errorf("tags after delete: expected none, got %+v", s.Tags)
+	}
+}
+
+func TestAPITagErrors(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateTag, http.MethodPost, "/api/v1/tags", `{"name": "existing"}`)
+	var tag models.Tag
+	decodeBody(t, w, &tag)
+	tid := tag.ID.String()
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.0.0.0/24", "name": "lan"}`)
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	id := lan.ID.String()
+	missing := "00000000-0000-0000-0000-000000000001"
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		path    []string
+		status  int
+		code    string
+	}{
+		{"duplicate name", HandleAPICreateTag, http.MethodPost, `{"name": "existing"}`, nil, http.StatusConflict, "tag_exists"},
+		{"missing name", HandleAPICreateTag, http.MethodPost, `{"color": "info"}`, nil, http.StatusUnprocessableEntity, "missing_field"},
+		{"invalid color", HandleAPICreateTag, http.MethodPost, `{"name": "x", "color": "pink"}`, nil, http.StatusUnprocessableEntity, "invalid_color"},
+		{"unknown tag", HandleAPIGetTag, http.MethodGet, "", []string{"id", missing}, http.StatusNotFound, "tag_not_found"},
+		{"tag nothing", HandleAPITagObjects, http.MethodPost, `{}`, []string{"id", tid}, http.StatusUnprocessableEntity, "missing_field"},
+		{"tag unknown subnet", HandleAPITagObjects, http.MethodPost, `{"subnet_ids": ["` + missing + `"]}`, []string{"id", tid},
+			http.StatusUnprocessableEntity, "subnet_not_found"},
+		{"tag available IP", HandleAPITagObjects, http.MethodPost, `{"ips": [{"subnet_id": "` + id + `", "address": "10.0.0.9"}]}`,
+			[]string{"id", tid}, http.StatusConflict, "ip_not_in_use"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveAPI(t, tc.handler, tc.method, "/api/v1/tags", tc.body, tc.path...)
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d; body: %s", tc.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tc.code {
+				t.Errorf("expected code %s, got %s", tc.code, body.Error.Code)
+			}
+		})
+	}
+
+	if w := serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets?tag=bogus", ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid tag filter: expected 422, got %d", w.Code)
+	}
+}
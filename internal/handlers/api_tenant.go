@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListTenants handles GET /api/v1/tenants.
+func HandleAPIListTenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := listTenants(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if tenants == nil {
+		tenants = []models.Tenant{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.Tenant]{Items: tenants})
+}
+
+// HandleAPICreateTenant handles POST /api/v1/tenants with a body such as
+// {"name": "payments", "description": "Payments business unit"}.
+func HandleAPICreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req models.Tenant
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	t, err := createTenant(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/tenants/"+t.ID.String())
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// HandleAPIGetTenant handles GET /api/v1/tenants/{id}.
+func HandleAPIGetTenant(w http.ResponseWriter, r *http.Request) {
+	t, err := getTenant(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleAPIUpdateTenant handles PATCH /api/v1/tenants/{id}.
+func HandleAPIUpdateTenant(w http.ResponseWriter, r *http.Request) {
+	var patch tenantPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	t, err := updateTenant(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// HandleAPIDeleteTenant handles DELETE /api/v1/tenants/{id}. Tenants that
+// still own subnets or addresses cannot be deleted.
+func HandleAPIDeleteTenant(w http.ResponseWriter, r *http.Request) {
+	if err := deleteTenant(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPITenantUsage handles GET /api/v1/tenants/usage, which reports the
+// allocated and reserved addresses of each tenant. The last item, without
+// tenant_id, counts the addresses no tenant owns.
+func HandleAPITenantUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := tenantUsage(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.TenantUsage]{Items: usage})
+}
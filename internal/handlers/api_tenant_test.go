@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPITenantLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	var payments, retail models.Tenant
+	for _, tc := range []struct {
+		body   string
+		tenant *models.Tenant
+	}{
+		{`{"name": "payments", "description": "Payments BU"}`, &payments},
+		{`{"name": "retail"}`, &retail},
+	} {
+		w := serveAPI(t, HandleAPICreateTenant, http.MethodPost, "/api/v1/tenants", tc.body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s: expected 201, got %d; body: %s", tc.body, w.Code, w.Body.String())
+		}
+		decodeBody(t, w, tc.tenant)
+	}
+
+	w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.1.0.0/24", "name": "payments-lan", "tenant_id": "`+payments.ID.String()+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create subnet: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	if lan.TenantID != payments.ID {
+		t.Errorf("subnet tenant: expected %s, got %+v", payments.ID, lan.TenantID)
+	}
+	if w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.2.0.0/24", "name": "shared"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create shared subnet: expected 201, got %d", w.Code)
+	}
+
+	for _, tc := range []struct {
+		query string
+		name  string
+	}{
+		{"?tenant=" + payments.ID.String(), "payments-lan"},
+		{"?tenant=none", "shared"},
+	} {
+		w := serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets"+tc.query, "")
+		var list listResponse[models.Subnet]
+		decodeBody(t, w, &list)
+		if len(list.Items) != 1 || list.Items[0].Name != tc.name {
+			t.Errorf("list %s: expected only %s, got %+v", tc.query, tc.name, list.Items)
+		}
+	}
+
+	// Two addresses inherit the subnet's tenant and one is handed to retail.
+	id := lan.ID.String()
+	for _, body := range []string{
+		`{"address": "10.1.0.1"}`,
+		`{"address": "10.1.0.2", "hostname": "pay-01"}`,
+		`{"address": "10.1.0.3", "tenant_id": "` + retail.ID.String() + `"}`,
+	} {
+		if w := serveAPI(t, HandleAPIAllocateIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips", body, "id", id); w.Code != http.StatusCreated {
+			t.Fatalf("allocate %s: expected 201, got %d; body: %s", body, w.Code, w.Body.String())
+		}
+	}
+	w = serveAPI(t, HandleAPIReserveIP, http.MethodPost, "/api/v1/subnets/"+id+"/ips/10.1.0.4/reserve", "",
+		"id", id, "address", "10.1.0.4")
+	if w.Code != http.StatusOK {
+		t.Fatalf("reserve: expected 200, got %d", w.Code)
+	}
+
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, "/api/v1/subnets/"+id+"/ips?tenant="+retail.ID.String(), "", "id", id)
+	var page ipListResponse
+	decodeBody(t, w, &page)
+	if len(page.Items) != 1 || page.Items[0].Address != "10.1.0.3" || page.Total.Int64() != 1 {
+		t.Errorf("retail IPs: expected only 10.1.0.3, got %+v", page)
+	}
+	w = serveAPI(t, HandleAPIListIPs, http.MethodGet, "/api/v1/subnets/"+id+"/ips?status=allocated&tenant="+payments.ID.String(), "", "id", id)
+	decodeBody(t, w, &page)
+	if page.Total.Int64() != 2 {
+		t.Errorf("payments allocated IPs: expected 2, got %+v", page)
+	}
+
+	w = serveAPI(t, HandleAPITenantUsage, http.MethodGet, "/api/v1/tenants/usage", "")
+	var usage listResponse[models.TenantUsage]
+	decodeBody(t, w, &usage)
+	want := []models.TenantUsage{
+		{TenantID: payments.ID, TenantName: "payments", Subnets: 1, Allocated: 2, Reserved: 1},
+		{TenantID: retail.ID, TenantName: "retail", Subnets: 0, Allocated: 1},
+		{TenantName: "Unassigned", Subnets: 1},
+	}
+	if len(usage.Items) != len(want) {
+		t.Fatalf("usage: expected %d entries, got %+v", len(want), usage.Items)
+	}
+	for i := range want {
+		if usage.Items[i] != want[i] {
+			t.Errorf("usage[%d]: expected %+v, got %+v", i, want[i], usage.Items[i])
+		}
+	}
+
+	// Addresses and subnets keep their tenant from being deleted.
+	for _, tenant := range []models.Tenant{payments, retail} {
+		tid := tenant.ID.String()
+		if w := serveAPI(t, HandleAPIDeleteTenant, http.MethodDelete, "/api/v1/tenants/"+tid, "", "id", tid); w.Code != http.StatusConflict {
+			t.Errorf("delete %s in use: expected 409, got %d", tenant.Name, w.Code)
+		}
+	}
+
+	// Clearing the address's tenant returns it to the subnet's tenant.
+	w = serveAPI(t, HandleAPIUpdateIP, http.MethodPatch, "/api/v1/subnets/"+id+"/ips/10.1.0.3", `{"tenant_id": null}`,
+		"id", id, "address", "10.1.0.3")
+	var ip models.IP
+	decodeBody(t, w, &ip)
+	if w.Code != http.StatusOK || ip.TenantID.Valid {
+		t.Errorf("clear IP tenant: got %d %+v", w.Code, ip)
+	}
+	tid := retail.ID.String()
+	if w := serveAPI(t, HandleAPIDeleteTenant, http.MethodDelete, "/api/v1/tenants/"+tid, "", "id", tid); w.Code != http.StatusNoContent {
+		t.Errorf("delete unused tenant: expected 204, got %d", w.Code)
+	}
+}
+
+func TestAPITenantErrors(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateTenant, http.MethodPost, "/api/v1/tenants", `{"name": "existing"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("setup: expected 201, got %d", w.Code)
+	}
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.0.0.0/24", "name": "lan"}`)
+	var lan models.Subnet
+	decodeBody(t, w, &lan)
+	id := lan.ID.String()
+	missing := "00000000-0000-0000-0000-000000000001"
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		path    []string
+		status  int
+		code    string
+	}{
+		{"duplicate name", HandleAPICreateTenant, http.MethodPost, `{"name": "existing"}`, nil, http.StatusConflict, "tenant_exists"},
+		{"missing name", HandleAPICreateTenant, http.MethodPost, `{"description": "x"}`, nil, http.StatusUnprocessableEntity, "missing_field"},
+		{"unknown tenant", HandleAPIGetTenant, http.MethodGet, "", []string{"id", missing}, http.StatusNotFound, "tenant_not_found"},
+		{"subnet with unknown tenant", HandleAPICreateSubnet, http.MethodPost,
+			`{"cidr": "10.9.0.0/24", "name": "x", "tenant_id": "` + missing + `"}`, nil, http.StatusUnprocessableEntity, "tenant_not_found"},
+		{"IP with unknown tenant", HandleAPIAllocateIP, http.MethodPost,
+			`{"address": "10.0.0.1", "tenant_id": "` + missing + `"}`, []string{"id", id}, http.StatusUnprocessableEntity, "tenant_not_found"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveAPI(t, tc.handler, tc.method, "/api/v1/tenants", tc.body, tc.path...)
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d; body: %s", tc.status, w.Code, w.Body.String())
+			}
+			var body apiErrorBody
+			decodeBody(t, w, &body)
+			if body.Error.Code != tc.code {
+				t.Errorf("expected code %s, got %s", tc.code, body.Error.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListVLANGroups handles GET /api/v1/vlan-groups.
+func HandleAPIListVLANGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := listVLANGroups(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if groups == nil {
+		groups = []models.VLANGroup{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.VLANGroup]{Items: groups})
+}
+
+// HandleAPICreateVLANGroup handles POST /api/v1/vlan-groups with a body such as
+// {"name": "dc1-fabric", "description": "DC1 leaf/spine"}.
+func HandleAPICreateVLANGroup(w http.ResponseWriter, r *http.Request) {
+	var req models.VLANGroup
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	g, err := createVLANGroup(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/vlan-groups/"+g.ID.String())
+	writeJSON(w, http.StatusCreated, g)
+}
+
+// HandleAPIGetVLANGroup handles GET /api/v1/vlan-groups/{id}.
+func HandleAPIGetVLANGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := getVLANGroup(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// HandleAPIUpdateVLANGroup handles PATCH /api/v1/vlan-groups/{id}.
+func HandleAPIUpdateVLANGroup(w http.ResponseWriter, r *http.Request) {
+	var patch vlanGroupPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	g, err := updateVLANGroup(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, g)
+}
+
+// HandleAPIDeleteVLANGroup handles DELETE /api/v1/vlan-groups/{id}. Groups
+// that still hold VLANs cannot be deleted.
+func HandleAPIDeleteVLANGroup(w http.ResponseWriter, r *http.Request) {
+	if err := deleteVLANGroup(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAPIListVLANs handles GET /api/v1/vlans.
+// Query parameters: group (a VLAN group ID, or "none"), status.
+func HandleAPIListVLANs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	vlans, err := listVLANs(r.Context(), vlanFilter{Group: q.Get("group"), Status: q.Get("status")})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if vlans == nil {
+		vlans = []models.VLAN{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.VLAN]{Items: vlans})
+}
+
+// HandleAPICreateVLAN handles POST /api/v1/vlans with a body such as
+// {"vid": 100, "name": "servers", "group_id": "...", "status": "active"}.
+func HandleAPICreateVLAN(w http.ResponseWriter, r *http.Request) {
+	var req models.VLAN
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	v, err := createVLAN(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/vlans/"+v.ID.String())
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// HandleAPIGetVLAN handles GET /api/v1/vlans/{id}.
+func HandleAPIGetVLAN(w http.ResponseWriter, r *http.Request) {
+	v, err := getVLAN(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// HandleAPIUpdateVLAN handles PATCH /api/v1/vlans/{id}.
+func HandleAPIUpdateVLAN(w http.ResponseWriter, r *http.Request) {
+	var patch vlanPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	v, err := updateVLAN(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// HandleAPIDeleteVLAN handles DELETE /api/v1/vlans/{id}. Linked subnets are
+// kept and unlinked.
+func HandleAPIDeleteVLAN(w http.ResponseWriter, r *http.Request) {
+	if err := deleteVLAN(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
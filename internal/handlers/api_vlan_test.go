@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIVLANLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateVLANGroup, http.MethodPost, "/api/v1/vlan-groups", `{"name": "dc1"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create group: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var group models.VLANGroup
+	decodeBody(t, w, &group)
+	groupID := group.ID.String()
+
+	w = serveAPI(t, HandleAPICreateVLAN, http.MethodPost, "/api/v1/vlans", `{"vid": 100, "name": "servers", "group_id": "`+groupID+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create VLAN: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var vlan models.VLAN
+	decodeBody(t, w, &vlan)
+	id := vlan.ID.String()
+	if vlan.VID != 100 || vlan.Status != models.VLANStatusActive || vlan.GroupID != group.ID {
+		t.Errorf("unexpected VLAN: %+v", vlan)
+	}
+
+	// The same VID may be used outside the group.
+	w = serveAPI(t, HandleAPICreateVLAN, http.MethodPost, "/api/v1/vlans", `{"vid": 100, "name": "other-site"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create VLAN without group: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+
+	w = serveAPI(t, HandleAPIListVLANs, http.MethodGet, "/api/v1/vlans?group="+groupID, "")
+	var list listResponse[models.VLAN]
+	decodeBody(t, w, &list)
+	if len(list.Items) != 1 || list.Items[0].Name != "servers" {
+		t.Errorf("list by group: expected only servers, got %+v", list.Items)
+	}
+
+	// Link a subnet, then delete the VLAN; the subnet is kept but unlinked.
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", `{"cidr": "10.100.0.0/24", "name": "srv", "vlan_id": "`+id+`"}`)
+	var subnet models.Subnet
+	decodeBody(t, w, &subnet)
+	if w.Code != http.StatusCreated || subnet.VLANID != vlan.ID {
+		t.Fatalf("create subnet on VLAN: got %d %+v", w.Code, subnet)
+	}
+	w = serveAPI(t, HandleAPIGetVLAN, http.MethodGet, "/api/v1/vlans/"+id, "", "id", id)
+	decodeBody(t, w, &vlan)
+	if vlan.SubnetCount != 1 {
+		t.Errorf("expected 1 linked subnet, got %d", vlan.SubnetCount)
+	}
+
+	w = serveAPI(t, HandleAPIUpdateVLAN, http.MethodPatch, "/api/v1/vlans/"+id, `{"status": "deprecated", "group_id": null}`, "id", id)
+	if w.Code != http.StatusConflict {
+		t.Errorf("moving VID 100 next to another VID 100: expected 409, got %d", w.Code)
+	}
+
+	w = serveAPI(t, HandleAPIDeleteVLANGroup, http.MethodDelete, "/api/v1/vlan-groups/"+groupID, "", "id", groupID)
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete group in use: expected 409, got %d", w.Code)
+	}
+
+	w = serveAPI(t, HandleAPIDeleteVLAN, http.MethodDelete, "/api/v1/vlans/"+id, "", "id", id)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete VLAN: expected 204, got %d", w.Code)
+	}
+	sid := subnet.ID.String()
+	w = serveAPI(t, HandleAPIGetSubnet, http.MethodGet, "/api/v1/subnets/"+sid, "", "id", sid)
+	decodeBody(t, w, &subnet)
+	if w.Code != http.StatusOK || subnet.VLANID.Valid {
+		t.Errorf("subnet after VLAN delete: got %d %+v", w.Code, subnet)
+	}
+}
+
+func TestAPIVLANErrors(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateVLAN, http.MethodPost, "/api/v1/vlans", `{"vid": 10, "name": "existing"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("setup: expected 201, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"vid zero", `{"vid": 0, "name": "x"}`, http.StatusUnprocessableEntity, "invalid_vid"},
+		{"vid too large", `{"vid": 4095, "name": "x"}`, http.StatusUnprocessableEntity, "invalid_vid"},
+		{"missing name", `{"vid": 11}`, http.StatusUnprocessableEntity, "missing_field"},
+		{"invalid status", `{"vid": 11, "name": "x", "status": "gone"}`, http.StatusUnprocessableEntity, "invalid_status"},
+		{"unknown group", `{"vid": 11, "name": "x", "group_id": "00000000-0000-0000-0000-000000000001"}`, http.StatusUnprocessableEntity, "vlan_group_not_found"},
+		{"duplicate vid", `{"vid": 10, "name": "dup"}`, http.StatusConflict, "vlan_exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAPI(t, HandleAPICreateVLAN, http.MethodPost, "/api/v1/vlans", tt.body)
+			var resp apiErrorBody
+			decodeBody(t, w, &resp)
+			if w.Code != tt.status || resp.Error.Code != tt.code {
+				t.Errorf("expected %d %s, got %d %s", tt.status, tt.code, w.Code, resp.Error.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleAPIListVRFs handles GET /api/v1/vrfs.
+func HandleAPIListVRFs(w http.ResponseWriter, r *http.Request) {
+	vrfs, err := listVRFs(r.Context())
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if vrfs == nil {
+		vrfs = []models.VRF{}
+	}
+	writeJSON(w, http.StatusOK, listResponse[models.VRF]{Items: vrfs})
+}
+
+// HandleAPICreateVRF handles POST /api/v1/vrfs with a body such as
+// {"name": "customer-a", "rd": "65000:100", "description": "Customer A"}.
+func HandleAPICreateVRF(w http.ResponseWriter, r *http.Request) {
+	var req models.VRF
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	v, err := createVRF(r.Context(), req)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/vrfs/"+v.ID.String())
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// HandleAPIGetVRF handles GET /api/v1/vrfs/{id}.
+func HandleAPIGetVRF(w http.ResponseWriter, r *http.Request) {
+	v, err := getVRF(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// HandleAPIUpdateVRF handles PATCH /api/v1/vrfs/{id}.
+func HandleAPIUpdateVRF(w http.ResponseWriter, r *http.Request) {
+	var patch vrfPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	v, err := updateVRF(r.Context(), r.PathValue("id"), patch)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// HandleAPIDeleteVRF handles DELETE /api/v1/vrfs/{id}. VRFs that still hold
+// subnets cannot be deleted.
+func HandleAPIDeleteVRF(w http.ResponseWriter, r *http.Request) {
+	if err := deleteVRF(r.Context(), r.PathValue("id")); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+func TestAPIVRFLifecycle(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateVRF, http.MethodPost, "/api/v1/vrfs", `{"name": "customer-a", "rd": "65000:100"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var vrf models.VRF
+	decodeBody(t, w, &vrf)
+	id := vrf.ID.String()
+	if vrf.Name != "customer-a" || vrf.RD == nil || *vrf.RD != "65000:100" {
+		t.Errorf("unexpected VRF: %+v", vrf)
+	}
+
+	// The same prefix may exist once in the global table and once per VRF.
+	for _, body := range []string{
+		`{"cidr": "192.168.1.0/24", "name": "global-lan"}`,
+		`{"cidr": "192.168.1.0/24", "name": "customer-lan", "vrf_id": "` + id + `"}`,
+	} {
+		if w := serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets", body); w.Code != http.StatusCreated {
+			t.Fatalf("create subnet %s: expected 201, got %d; body: %s", body, w.Code, w.Body.String())
+		}
+	}
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "192.168.1.128/25", "name": "dup", "vrf_id": "`+id+`"}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("overlap within VRF: expected 409, got %d", w.Code)
+	}
+
+	for _, tc := range []struct {
+		query string
+		name  string
+	}{
+		{"?vrf=" + id, "customer-lan"},
+		{"?vrf=global", "global-lan"},
+	} {
+		w := serveAPI(t, HandleAPIListSubnets, http.MethodGet, "/api/v1/subnets"+tc.query, "")
+		var list listResponse[models.Subnet]
+		decodeBody(t, w, &list)
+		if len(list.Items) != 1 || list.Items[0].Name != tc.name {
+			t.Errorf("list %s: expected only %s, got %+v", tc.query, tc.name, list.Items)
+		}
+	}
+
+	w = serveAPI(t, HandleAPIGetVRF, http.MethodGet, "/api/v1/vrfs/"+id, "", "id", id)
+	decodeBody(t, w, &vrf)
+	if w.Code != http.StatusOK || vrf.SubnetCount != 1 {
+		t.Errorf("get: expected 200 with 1 subnet, got %d %+v", w.Code, vrf)
+	}
+
+	w = serveAPI(t, HandleAPIDeleteVRF, http.MethodDelete, "/api/v1/vrfs/"+id, "", "id", id)
+	if w.Code != http.StatusConflict {
+		t.Errorf("delete VRF in use: expected 409, got %d", w.Code)
+	}
+
+	w = serveAPI(t, HandleAPIUpdateVRF, http.MethodPatch, "/api/v1/vrfs/"+id, `{"rd": "", "description": "moved"}`, "id", id)
+	decodeBody(t, w, &vrf)
+	if w.Code != http.StatusOK || vrf.RD != nil || vrf.Description != "moved" {
+		t.Errorf("update: got %d %+v", w.Code, vrf)
+	}
+}
+
+func TestAPIVRFErrors(t *testing.T) {
+	cleanDB(t)
+
+	w := serveAPI(t, HandleAPICreateVRF, http.MethodPost, "/api/v1/vrfs", `{"name": "existing", "rd": "65000:1"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("setup: expected 201, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"missing name", `{"rd": "65000:2"}`, http.StatusUnprocessableEntity, "missing_field"},
+		{"duplicate name", `{"name": "existing"}`, http.StatusConflict, "vrf_exists"},
+		{"duplicate rd", `{"name": "other", "rd": "65000:1"}`, http.StatusConflict, "vrf_exists"},
+		{"invalid rd", `{"name": "other", "rd": "nonsense"}`, http.StatusUnprocessableEntity, "invalid_rd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAPI(t, HandleAPICreateVRF, http.MethodPost, "/api/v1/vrfs", tt.body)
+			var resp apiErrorBody
+			decodeBody(t, w, &resp)
+			if w.Code != tt.status || resp.Error.Code != tt.code {
+				t.Errorf("expected %d %s, got %d %s", tt.status, tt.code, w.Code, resp.Error.Code)
+			}
+		})
+	}
+
+	w = serveAPI(t, HandleAPICreateSubnet, http.MethodPost, "/api/v1/subnets",
+		`{"cidr": "10.0.0.0/24", "name": "orphan", "vrf_id": "00000000-0000-0000-0000-000000000001"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("subnet in unknown VRF: expected 422, got %d", w.Code)
+	}
+}
+
+func TestValidateRD(t *testing.T) {
+	tests := []struct {
+		rd    string
+		valid bool
+	}{
+		{"65000:100", true},
+		{"4200000000:100", true},
+		{"4200000000:70000", false},
+		{"192.0.2.1:100", true},
+		{"192.0.2.1:70000", false},
+		{"65000", false},
+		{"a:b", false},
+	}
+	for _, tt := range tests {
+		_, err := validateRD(&tt.rd)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateRD(%q): valid=%v, err=%v", tt.rd, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+const (
+	// auditPageSize is the number of entries per page of the changelog.
+	auditPageSize = 50
+	// maxRequestIDLength bounds the X-Request-ID accepted from clients.
+	maxRequestIDLength = 128
+	// defaultAuditUserHeader carries the authenticated user, as set by an
+	// authenticating proxy such as oauth2-proxy.
+	defaultAuditUserHeader = "X-Forwarded-User"
+)
+
+// auditSource identifies who made a change and from where.
+type auditSource struct {
+	Actor     string
+	RequestID string
+	SourceIP  string // empty for changes made by the server itself
+}
+
+type auditSourceKey struct{}
+
+// auditSourceOf returns the source attached to ctx by WithAuditSource. Work
+// done outside a request, such as releasing expired addresses, is made by
+// the "system" actor.
+func auditSourceOf(ctx context.Context) auditSource {
+	if src, ok := ctx.Value(auditSourceKey{}).(auditSource); ok {
+		return src
+	}
+	return auditSource{Actor: "system"}
+}
+
+// auditUserHeader reads once from AUDIT_USER_HEADER the request header that
+// names the user making a request, X-Forwarded-User by default.
+var auditUserHeader = sync.OnceValue(func() string {
+	if h := strings.TrimSpace(os.Getenv("AUDIT_USER_HEADER")); h != "" {
+		return h
+	}
+	return defaultAuditUserHeader
+})
+
+// trustedProxies reads once from TRUSTED_PROXIES the reverse proxies, as
+// comma-separated addresses or CIDRs, whose user and X-Forwarded-For
+// headers are believed. Invalid entries are logged and ignored.
+var trustedProxies = sync.OnceValue(func() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				log.Printf("Ignoring %q in TRUSTED_PROXIES: expected an address or CIDR", s)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+})
+
+// WithAuditSource attaches the source of each request to its context, so
+// that the changes it makes are recorded with it: the user, the
+// X-Request-ID, generated when missing and echoed in the response, and the
+// client address. The user (from AUDIT_USER_HEADER) and X-Forwarded-For
+// are only believed from the proxies in TRUSTED_PROXIES; other requests are
+// made by "anonymous" from their remote address.
+func WithAuditSource(next http.Handler) http.Handler {
+	return auditSourceHandler(next, trustedProxies())
+}
+
+// auditSourceHandler is WithAuditSource with the given trusted proxies.
+func auditSourceHandler(next http.Handler, trusted []netip.Prefix) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		src := requestAuditSource(r, trusted)
+		w.Header().Set("X-Request-ID", src.RequestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auditSourceKey{}, src)))
+	})
+}
+
+// requestAuditSource derives the source of a request from its remote
+// address and, when that is a trusted proxy, from its headers.
+func requestAuditSource(r *http.Request, trusted []netip.Prefix) auditSource {
+	src := auditSource{Actor: "anonymous", RequestID: r.Header.Get("X-Request-ID")}
+	if src.RequestID == "" || len(src.RequestID) > maxRequestIDLength || strings.ContainsFunc(src.RequestID, isControl) {
+		b := make([]byte, 16)
+		rand.Read(b)
+		src.RequestID = hex.EncodeToString(b)
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return src
+	}
+	remote = remote.Unmap()
+	src.SourceIP = remote.String()
+	if !isTrustedProxy(remote, trusted) {
+		return src
+	}
+	if user := strings.TrimSpace(r.Header.Get(auditUserHeader())); user != "" {
+		src.Actor = user
+	}
+	// Each proxy appends the address it received the request from, so the
+	// client is the last address not belonging to a trusted proxy.
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		src.SourceIP = hop.Unmap().String()
+		if !isTrustedProxy(hop.Unmap(), trusted) {
+			break
+		}
+	}
+	return src
+}
+
+// isTrustedProxy reports whether addr is one of the trusted proxies.
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	return slices.ContainsFunc(trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// isControl reports whether r is an ASCII control character.
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
+
+// auditEntry is a change to be appended to the audit log.
+type auditEntry struct {
+	Action     string
+	ObjectType string
+	ObjectID   string
+	Label      string
+	SubnetID   pgtype.UUID
+	Before     any // nil when the object was created
+	After      any // nil when the object was deleted
+}
+
+// subnetAudit describes a change to a subnet; before is nil when it was
+// created and after is nil when it was deleted.
+func subnetAudit(action string, before, after *models.Subnet) auditEntry {
+	s := after
+	if s == nil {
+		s = before
+	}
+	e := auditEntry{Action: action, ObjectType: models.AuditObjectSubnet, ObjectID: s.ID.String(), Label: s.CIDR, SubnetID: s.ID}
+	if before != nil {
+		e.Before = before
+	}
+	if after != nil {
+		e.After = after
+	}
+	return e
+}
+
+// rangeAudit describes a change to a range; before is nil when it was
+// created and after is nil when it was deleted.
+func rangeAudit(action string, before, after *models.IPRange) auditEntry {
+	rg := after
+	if rg == nil {
+		rg = before
+	}
+	e := auditEntry{Action: action, ObjectType: models.AuditObjectRange, ObjectID: rg.ID.String(),
+		Label: rg.StartAddress + "-" + rg.EndAddress, SubnetID: rg.SubnetID}
+	if before != nil {
+		e.Before = before
+	}
+	if after != nil {
+		e.After = after
+	}
+	return e
+}
+
+// ipAudit describes a change to an address. Available addresses are not
+// stored, so they are recorded as absent.
+func ipAudit(action string, before, after models.IP) auditEntry {
+	ip := after
+	if ip.Address == "" {
+		ip = before
+	}
+	e := auditEntry{Action: action, ObjectType: models.AuditObjectIP, ObjectID: ip.Address, Label: ip.Address, SubnetID: ip.SubnetID}
+	if before.Status != "" && before.Status != "available" {
+		e.Before = before
+	}
+	if after.Status != "" && after.Status != "available" {
+		e.After = after
+	}
+	return e
+}
+
+// objectAudit describes a change to an object other than a subnet, range or
+// address, identified by its UUID and labelled by its name; before is nil
+// when it was created and after is nil when it was deleted.
+func objectAudit[T any](action, objectType string, id pgtype.UUID, label string, before, after *T) auditEntry {
+	e := auditEntry{Action: action, ObjectType: objectType, ObjectID: id.String(), Label: label}
+	if before != nil {
+		e.Before = before
+	}
+	if after != nil {
+		e.After = after
+	}
+	return e
+}
+
+// ipUpdateAudit is ipAudit for recordChanges.
+func ipUpdateAudit(action string, before, after *models.IP) auditEntry {
+	return ipAudit(action, *before, *after)
+}
+
+// recordChanges runs mutate in tx and records an "update" for each of the
+// objects ids that it changes as a side effect, such as the subnets of a
+// deleted VLAN, comparing the snapshots taken by load before and after it.
+// Objects that mutate removes are left out.
+func recordChanges[T any](ctx context.Context, tx pgx.Tx, ids []pgtype.UUID,
+	load func(context.Context, database.Querier, []pgtype.UUID) (map[pgtype.UUID]T, error),
+	audit func(action string, before, after *T) auditEntry, mutate func() error) error {
+	if len(ids) == 0 {
+		return mutate()
+	}
+	before, err := load(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	if err := mutate(); err != nil {
+		return err
+	}
+	after, err := load(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	var entries []auditEntry
+	for _, id := range ids {
+		b, okBefore := before[id]
+		a, okAfter := after[id]
+		if okBefore && okAfter {
+			entries = append(entries, audit("update", &b, &a))
+		}
+	}
+	return recordAudit(ctx, tx, entries...)
+}
+
+// recordAudit appends entries to the audit log with the source of ctx. It is
+// called with the transaction making the changes, so that they are recorded
+// if and only if they are committed. Entries that change nothing are
+// skipped.
+func recordAudit(ctx context.Context, q database.Querier, entries ...auditEntry) error {
+	var actions, types, ids, labels []string
+	var subnetIDs []pgtype.UUID
+	var befores, afters []*string
+	for _, e := range entries {
+		before, err := auditJSON(e.Before)
+		if err != nil {
+			return err
+		}
+		after, err := auditJSON(e.After)
+		if err != nil {
+			return err
+		}
+		if before != nil && after != nil && *before == *after {
+			continue
+		}
+		actions, types, ids, labels = append(actions, e.Action), append(types, e.ObjectType), append(ids, e.ObjectID), append(labels, e.Label)
+		subnetIDs = append(subnetIDs, e.SubnetID)
+		befores, afters = append(befores, before), append(afters, after)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	src := auditSourceOf(ctx)
+	var sourceIP any
+	if src.SourceIP != "" {
+		sourceIP = src.SourceIP
+	}
+	_, err := q.Exec(ctx,
+		`INSERT INTO audit_log (actor, action, object_type, object_id, object_label, subnet_id, before, after, request_id, source_ip)
+		 SELECT $1, e.action, e.object_type, e.object_id, e.label, e.subnet_id, e.before::jsonb, e.after::jsonb, $2, $3::inet
+		 FROM unnest($4::text[], $5::text[], $6::text[], $7::text[], $8::uuid[], $9::text[], $10::text[])
+		   WITH ORDINALITY AS e(action, object_type, object_id, label, subnet_id, before, after, n)
+		 ORDER BY e.n`,
+		src.Actor, src.RequestID, sourceIP, actions, types, ids, labels, subnetIDs, befores, afters)
+	return err
+}
+
+// auditJSON encodes a snapshot for the audit log; nil stays NULL.
+func auditJSON(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+// auditFilter narrows down listAudit; zero fields do not filter.
+type auditFilter struct {
+	ObjectType string    // e.g. subnet, ip or device
+	Object     string    // object ID, or a part of its label
+	ObjectID   string    // exact object ID, e.g. an address
+	Action     string    // e.g. allocate
+	Actor      string    // exact actor
+	SubnetID   string    // the subnet, its ranges and its addresses
+	Since      time.Time // entries at or after
+	Until      time.Time // entries before
+}
+
+// auditFilterFrom reads an auditFilter from the query parameters type,
+// object, action, actor, subnet, since and until (dates or RFC 3339 times).
+func auditFilterFrom(q url.Values) (auditFilter, error) {
+	f := auditFilter{
+		ObjectType: q.Get("type"),
+		Object:     strings.TrimSpace(q.Get("object")),
+		Action:     q.Get("action"),
+		Actor:      strings.TrimSpace(q.Get("actor")),
+		SubnetID:   q.Get("subnet"),
+	}
+	if f.ObjectType != "" && !slices.Contains(models.AuditObjectTypes, f.ObjectType) {
+		return f, errInvalid("invalid_object_type", "type must be one of %s", strings.Join(models.AuditObjectTypes, ", "))
+	}
+	var err error
+	if f.Since, err = parseAuditTime(q.Get("since"), false); err != nil {
+		return f, err
+	}
+	if f.Until, err = parseAuditTime(q.Get("until"), true); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// parseAuditTime parses a bound of the audit time filter: an RFC 3339 time,
+// or a date in the server's time zone. A date taken as an upper bound
+// includes the whole day.
+func parseAuditTime(s string, upper bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return t, errInvalid("invalid_time", "%q is not a date or time", s)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// where returns the SQL condition selecting the filtered entries and its arguments.
+func (f auditFilter) where() (string, []any, error) {
+	conds := []string{"TRUE"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.ObjectType != "" {
+		add("object_type = $%d", f.ObjectType)
+	}
+	if f.Object != "" {
+		add("(object_id = $%[1]d OR object_label ILIKE '%%' || $%[1]d || '%%')", f.Object)
+	}
+	if f.ObjectID != "" {
+		add("object_id = $%d", f.ObjectID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.SubnetID != "" {
+		id, err := parseRefID(f.SubnetID, "invalid_subnet", "Invalid subnet ID")
+		if err != nil {
+			return "", nil, err
+		}
+		add("subnet_id = $%d", id)
+	}
+	if !f.Since.IsZero() {
+		add("occurred_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("occurred_at < $%d", f.Until)
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// listAudit returns a page of the entries selected by f, newest first, and
+// the number of entries selected.
+func listAudit(ctx context.Context, f auditFilter, page, pageSize int) ([]models.AuditEntry, int, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	if err := database.DB.QueryRow(ctx, "SELECT COUNT(*) FROM audit_log WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := database.DB.Query(ctx,
+		fmt.Sprintf(`SELECT id, occurred_at, actor, action, object_type, object_id, object_label, subnet_id,
+		   before, after, request_id, COALESCE(host(source_ip), '')
+		 FROM audit_log WHERE %s ORDER BY id DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)),
+		args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &e.Action, &e.ObjectType, &e.ObjectID, &e.ObjectLabel, &e.SubnetID,
+			(*[]byte)(&e.Before), (*[]byte)(&e.After), &e.RequestID, &e.SourceIP); err != nil {
+			return nil, 0, err
+		}
+		e.Changes = auditChanges(e.Before, e.After)
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// auditChanges lists the top-level fields that differ between two
+// snapshots, in field order. Fields that are empty on both sides, as most
+// are on creation or deletion, are left out.
+func auditChanges(before, after json.RawMessage) []models.AuditChange {
+	var b, a map[string]json.RawMessage
+	json.Unmarshal(before, &b)
+	json.Unmarshal(after, &a)
+	var changes []models.AuditChange
+	fields := append(slices.Collect(maps.Keys(b)), slices.Collect(maps.Keys(a))...)
+	slices.Sort(fields)
+	for _, field := range slices.Compact(fields) {
+		bv, av := auditValue(b[field]), auditValue(a[field])
+		if bv != av {
+			changes = append(changes, models.AuditChange{Field: field, Before: bv, After: av})
+		}
+	}
+	return changes
+}
+
+// auditValue renders a snapshot field for display; null and empty values
+// are "".
+func auditValue(v json.RawMessage) string {
+	s := string(bytes.TrimSpace(v))
+	switch s {
+	case "null", `""`, "[]", "{}":
+		return ""
+	}
+	return s
+}
+
+// auditSubnets returns the subnets of entries that still exist, keyed by ID.
+func auditSubnets(ctx context.Context, entries []models.AuditEntry) (map[string]models.Subnet, error) {
+	refs := make([]models.IP, 0, len(entries))
+	for _, e := range entries {
+		if e.SubnetID.Valid {
+			refs = append(refs, models.IP{SubnetID: e.SubnetID})
+		}
+	}
+	return subnetsOf(ctx, refs)
+}
+
+// auditPage loads the page ?page= of the entries selected by f for the
+// changelog or a history tab at base.
+func auditPage(r *http.Request, base string, f auditFilter) (templates.AuditPage, error) {
+	p := templates.AuditPage{BaseURL: base, Query: r.URL.Query(), Page: queryInt(r, "page", 1), PageSize: auditPageSize}
+	var err error
+	if p.Entries, p.Total, err = listAudit(r.Context(), f, p.Page, p.PageSize); err != nil {
+		return p, err
+	}
+	p.Subnets, err = auditSubnets(r.Context(), p.Entries)
+	return p, err
+}
+
+// HandleChangelog renders the audit log across all subnets, filtered by the
+// query parameters read by auditFilterFrom.
+func HandleChangelog(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilterFrom(r.URL.Query())
+	if err != nil {
+		writeError(w, err, "Failed to fetch the changelog")
+		return
+	}
+	p, err := auditPage(r, "/changelog", f)
+	if err != nil {
+		writeError(w, err, "Failed to fetch the changelog")
+		return
+	}
+	templates.Changelog(p).Render(r.Context(), w)
+}
+
+// HandleSubnetHistory renders the history tab of a subnet: the changes to
+// the subnet, its ranges and its addresses.
+func HandleSubnetHistory(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getSubnet(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnet")
+		return
+	}
+	ancestors, err := ancestorSubnets(r.Context(), subnet)
+	if err != nil {
+		writeError(w, err, "Failed to fetch parent subnets")
+		return
+	}
+	f, err := auditFilterFrom(r.URL.Query())
+	if err != nil {
+		writeError(w, err, "Failed to fetch history")
+		return
+	}
+	f.SubnetID = subnet.ID.String()
+	p, err := auditPage(r, fmt.Sprintf("/subnets/%s/history", subnet.ID), f)
+	if err != nil {
+		writeError(w, err, "Failed to fetch history")
+		return
+	}
+	templates.SubnetHistory(subnet, ancestors, p).Render(r.Context(), w)
+}
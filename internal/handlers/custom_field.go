@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// customFieldColumns is the column list scanned by scanCustomField.
+const customFieldColumns = `id, object_type, name, label, type, choices, description, created_at`
+
+// customFieldFormPrefix prefixes the names of custom field inputs in forms
+// and of custom field filters in query strings, e.g. cf_owner_email.
+const customFieldFormPrefix = "cf_"
+
+// validCustomFieldName matches the names custom fields are stored under.
+var validCustomFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+func HandleCustomFieldList(w http.ResponseWriter, r *http.Request) {
+	fields, err := listCustomFields(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch custom fields")
+		return
+	}
+
+	component := templates.CustomFieldList(fields)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateCustomField(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in := models.CustomField{
+		ObjectType:  r.FormValue("object_type"),
+		Name:        r.FormValue("name"),
+		Label:       r.FormValue("label"),
+		Type:        r.FormValue("type"),
+		Choices:     splitChoices(r.FormValue("choices")),
+		Description: r.FormValue("description"),
+	}
+	if _, err := createCustomField(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create custom field")
+		return
+	}
+
+	HandleCustomFieldList(w, r)
+}
+
+func HandleDeleteCustomField(w http.ResponseWriter, r *http.Request) {
+	if err := deleteCustomField(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete custom field")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// splitChoices splits the comma-separated enum choices of the create form.
+func splitChoices(s string) []string {
+	var choices []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// scanCustomField scans a row selected with customFieldColumns.
+func scanCustomField(row interface{ Scan(...any) error }, f *models.CustomField) error {
+	return row.Scan(&f.ID, &f.ObjectType, &f.Name, &f.Label, &f.Type, &f.Choices, &f.Description, &f.CreatedAt)
+}
+
+// listCustomFields returns the custom fields of objectType ("subnet" or "ip"),
+// or of both when it is empty, in the order they were created.
+func listCustomFields(ctx context.Context, objectType string) ([]models.CustomField, error) {
+	rows, err := database.DB.Query(ctx,
+		"SELECT "+customFieldColumns+" FROM custom_fields WHERE $1 = '' OR object_type = $1 ORDER BY object_type, created_at, name",
+		objectType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fields []models.CustomField
+	for rows.Next() {
+		var f models.CustomField
+		if err := scanCustomField(rows, &f); err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+func getCustomField(ctx context.Context, id string) (models.CustomField, error) {
+	var f models.CustomField
+	err := scanCustomField(database.DB.QueryRow(ctx, "SELECT "+customFieldColumns+" FROM custom_fields WHERE id = $1", id), &f)
+	if isNoRows(err) {
+		return f, errNotFound("custom_field_not_found", "Custom field not found")
+	}
+	return f, err
+}
+
+// validateChoices checks the choices of a custom field of type typ: enums
+// need at least one, other types none.
+func validateChoices(typ string, choices []string) ([]string, error) {
+	if typ != models.CustomFieldEnum {
+		if len(choices) > 0 {
+			return nil, errInvalid("invalid_choices", "only enum fields have choices")
+		}
+		return []string{}, nil
+	}
+	if len(choices) == 0 {
+		return nil, errInvalid("invalid_choices", "enum fields need at least one choice")
+	}
+	for i, c := range choices {
+		if c == "" || slices.Contains(choices[:i], c) {
+			return nil, errInvalid("invalid_choices", "choices must be non-empty and distinct")
+		}
+	}
+	return choices, nil
+}
+
+func createCustomField(ctx context.Context, in models.CustomField) (models.CustomField, error) {
+	var f models.CustomField
+	if in.ObjectType == "" || in.Name == "" || in.Type == "" {
+		return f, errInvalid("missing_field", "object_type, name and type are required")
+	}
+	if in.ObjectType != models.CustomFieldObjectSubnet && in.ObjectType != models.CustomFieldObjectIP {
+		return f, errInvalid("invalid_object_type", "object_type must be subnet or ip")
+	}
+	if !validCustomFieldName.MatchString(in.Name) {
+		return f, errInvalid("invalid_name", "name must start with a lowercase letter and contain only lowercase letters, digits and underscores")
+	}
+	if !slices.Contains(models.CustomFieldTypes, in.Type) {
+		return f, errInvalid("invalid_type", "type must be one of text, integer, boolean, date, enum or url")
+	}
+	choices, err := validateChoices(in.Type, in.Choices)
+	if err != nil {
+		return f, err
+	}
+	if in.Label == "" {
+		in.Label = in.Name
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanCustomField(tx.QueryRow(ctx,
+			`INSERT INTO custom_fields (object_type, name, label, type, choices, description) VALUES ($1, $2, $3, $4, $5, $6)
+			 RETURNING `+customFieldColumns,
+			in.ObjectType, in.Name, in.Label, in.Type, choices, in.Description), &f); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, customFieldAudit("create", nil, &f))
+	})
+	return f, uniqueWriteError(err, "custom_field_exists", "A custom field with this name already exists")
+}
+
+// customFieldPatch lists the custom field settings that may be changed; nil
+// fields are left as is. The object type, name and type are fixed because
+// stored values depend on them. Values no longer among the choices of an enum
+// are kept until the object is next edited.
+type customFieldPatch struct {
+	Label       *string   `json:"label"`
+	Choices     *[]string `json:"choices"`
+	Description *string   `json:"description"`
+}
+
+func updateCustomField(ctx context.Context, id string, patch customFieldPatch) (models.CustomField, error) {
+	f, err := getCustomField(ctx, id)
+	if err != nil {
+		return f, err
+	}
+	if patch.Label != nil && *patch.Label == "" {
+		return f, errInvalid("missing_field", "label must not be empty")
+	}
+	var choices []string
+	if patch.Choices != nil {
+		// The type is fixed, so the choices can be checked before locking.
+		if choices, err = validateChoices(f.Type, *patch.Choices); err != nil {
+			return f, err
+		}
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.CustomField
+		if err := scanCustomField(tx.QueryRow(ctx, "SELECT "+customFieldColumns+" FROM custom_fields WHERE id = $1 FOR UPDATE", f.ID), &before); err != nil {
+			return err
+		}
+		f = before
+		if patch.Label != nil {
+			f.Label = *patch.Label
+		}
+		if patch.Choices != nil {
+			f.Choices = choices
+		}
+		if patch.Description != nil {
+			f.Description = *patch.Description
+		}
+		if err := scanCustomField(tx.QueryRow(ctx,
+			"UPDATE custom_fields SET label = $2, choices = $3, description = $4 WHERE id = $1 RETURNING "+customFieldColumns,
+			f.ID, f.Label, f.Choices, f.Description), &f); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, customFieldAudit("update", &before, &f))
+	})
+	if isNoRows(err) {
+		return f, errNotFound("custom_field_not_found", "Custom field not found")
+	}
+	return f, err
+}
+
+// deleteCustomField removes a custom field and its values from every object.
+// Each subnet or address that loses a value is recorded as changed.
+func deleteCustomField(ctx context.Context, id string) error {
+	f, err := getCustomField(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		table := "subnets"
+		if f.ObjectType == models.CustomFieldObjectIP {
+			table = "ips"
+		}
+		ids, err := queryIDs(ctx, tx, "SELECT id FROM "+table+" WHERE custom_fields ? $1", f.Name)
+		if err != nil {
+			return err
+		}
+		strip := func() error {
+			_, err := tx.Exec(ctx, "UPDATE "+table+" SET custom_fields = custom_fields - $1 WHERE custom_fields ? $1", f.Name)
+			return err
+		}
+		if f.ObjectType == models.CustomFieldObjectIP {
+			err = recordChanges(ctx, tx, ids, ipSnapshots, ipUpdateAudit, strip)
+		} else {
+			err = recordChanges(ctx, tx, ids, subnetSnapshots, subnetAudit, strip)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM custom_fields WHERE id = $1", f.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, customFieldAudit("delete", &f, nil))
+	})
+}
+
+// customFieldAudit describes a change to a custom field; before is nil when
+// it was created and after is nil when it was deleted.
+func customFieldAudit(action string, before, after *models.CustomField) auditEntry {
+	f := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectCustomField, f.ID, f.Name, before, after)
+}
+
+// customFieldValue checks a value of field f and returns it as stored: a
+// string, an int64 or a bool. Forms submit every type as a string. nil and
+// the empty string clear the field and return nil.
+func customFieldValue(f models.CustomField, v any) (any, error) {
+	invalid := func(format string, args ...any) error {
+		return errInvalid("invalid_custom_field", "%s: %s", f.Name, fmt.Sprintf(format, args...))
+	}
+	if v == nil || v == "" {
+		return nil, nil
+	}
+	switch f.Type {
+	case models.CustomFieldInteger:
+		switch n := v.(type) {
+		case float64: // JSON numbers
+			if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+				return nil, invalid("must be an integer")
+			}
+			return int64(n), nil
+		case string:
+			i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+			if err != nil {
+				return nil, invalid("must be an integer")
+			}
+			return i, nil
+		}
+		return nil, invalid("must be an integer")
+	case models.CustomFieldBoolean:
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			if parsed, err := strconv.ParseBool(b); err == nil {
+				return parsed, nil
+			}
+		}
+		return nil, invalid("must be true or false")
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, invalid("must be a string")
+	}
+	switch f.Type {
+	case models.CustomFieldDate:
+		if _, err := time.Parse(time.DateOnly, s); err != nil {
+			return nil, invalid("must be a date such as 2024-01-31")
+		}
+	case models.CustomFieldEnum:
+		if !slices.Contains(f.Choices, s) {
+			return nil, invalid("must be one of %s", strings.Join(f.Choices, ", "))
+		}
+	case models.CustomFieldURL:
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, invalid("must be an http or https URL")
+		}
+	}
+	return s, nil
+}
+
+// applyCustomFields validates values against the custom fields of objectType
+// and returns current with them applied; nil or empty values remove a field.
+// The result is never nil.
+func applyCustomFields(ctx context.Context, objectType string, current, values map[string]any) (map[string]any, error) {
+	result := maps.Clone(current)
+	if result == nil {
+		result = map[string]any{}
+	}
+	if len(values) == 0 {
+		return result, nil
+	}
+	fields, err := listCustomFields(ctx, objectType)
+	if err != nil {
+		return nil, err
+	}
+	for name, v := range values {
+		i := slices.IndexFunc(fields, func(f models.CustomField) bool { return f.Name == name })
+		if i < 0 {
+			return nil, errInvalid("unknown_custom_field", "%s is not a custom field of %s objects", name, objectType)
+		}
+		value, err := customFieldValue(fields[i], v)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			delete(result, name)
+		} else {
+			result[name] = value
+		}
+	}
+	return result, nil
+}
+
+// customFieldsFromForm returns the custom field inputs (cf_<name>) of a form.
+// They are validated by applyCustomFields.
+func customFieldsFromForm(form url.Values) map[string]any {
+	values := make(map[string]any)
+	for key := range form {
+		if name, ok := strings.CutPrefix(key, customFieldFormPrefix); ok {
+			values[name] = form.Get(key)
+		}
+	}
+	return values
+}
+
+// customFieldFilters reads the custom field filters (cf_<name>=<value>) of a
+// query string for objectType. It returns each value in its stored text form,
+// as compared with custom_fields->>name.
+func customFieldFilters(ctx context.Context, objectType string, query url.Values) (map[string]string, error) {
+	values := customFieldsFromForm(query)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	fields, err := listCustomFields(ctx, objectType)
+	if err != nil {
+		return nil, err
+	}
+	filters := make(map[string]string, len(values))
+	for name, v := range values {
+		i := slices.IndexFunc(fields, func(f models.CustomField) bool { return f.Name == name })
+		if i < 0 {
+			return nil, errInvalid("unknown_custom_field", "%s is not a custom field of %s objects", name, objectType)
+		}
+		value, err := customFieldValue(fields[i], v)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			filters[name] = fmt.Sprint(value)
+		}
+	}
+	return filters, nil
+}
+
+// customFieldConds returns SQL conditions matching the custom field filters,
+// appending their arguments to args.
+func customFieldConds(filters map[string]string, args *[]any) []string {
+	var conds []string
+	for _, name := range slices.Sorted(maps.Keys(filters)) {
+		*args = append(*args, name, filters[name])
+		conds = append(conds, fmt.Sprintf("custom_fields->>$%d = $%d", len(*args)-1, len(*args)))
+	}
+	return conds
+}
@@ -0,0 +1,660 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// deviceColumns is the column list scanned by scanDevice.
+const deviceColumns = `id, name, role, platform,
+	site_id, COALESCE((SELECT st.name FROM sites st WHERE st.id = devices.site_id), ''),
+	description, primary_ip_id,
+	(SELECT i.address FROM ips i WHERE i.id = devices.primary_ip_id),
+	created_at,
+	(SELECT COUNT(*) FROM interfaces f WHERE f.device_id = devices.id)`
+
+// interfaceColumns is the column list scanned by scanInterface.
+const interfaceColumns = `id, device_id,
+	COALESCE((SELECT d.name FROM devices d WHERE d.id = interfaces.device_id), ''),
+	name, type, mac_address, created_at,
+	(SELECT COUNT(*) FROM ips i WHERE i.interface_id = interfaces.id)`
+
+func HandleDeviceList(w http.ResponseWriter, r *http.Request) {
+	siteFilter := r.URL.Query().Get("site")
+	devices, err := listDevices(r.Context(), siteFilter)
+	if err != nil {
+		writeError(w, err, "Failed to fetch devices")
+		return
+	}
+	sites, err := listSites(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch sites")
+		return
+	}
+
+	component := templates.DeviceList(devices, sites, siteFilter)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateDevice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in, err := deviceFromForm(r)
+	if err != nil {
+		writeError(w, err, "Failed to create device")
+		return
+	}
+	if _, err := createDevice(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create device")
+		return
+	}
+
+	HandleDeviceList(w, r)
+}
+
+func HandleDeleteDevice(w http.ResponseWriter, r *http.Request) {
+	if err := deleteDevice(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete device")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleDeviceDetail shows a device with its interfaces and the addresses
+// assigned to them across all subnets.
+func HandleDeviceDetail(w http.ResponseWriter, r *http.Request) {
+	d, err := getDevice(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch device")
+		return
+	}
+	interfaces, err := listInterfaces(r.Context(), d.ID.String())
+	if err != nil {
+		writeError(w, err, "Failed to fetch interfaces")
+		return
+	}
+	ips, err := deviceIPs(r.Context(), d.ID)
+	if err != nil {
+		writeError(w, err, "Failed to fetch IPs")
+		return
+	}
+	subnets, err := subnetsOf(r.Context(), ips)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+	sites, err := listSites(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch sites")
+		return
+	}
+
+	component := templates.DeviceDetail(d, interfaces, ips, subnets, sites)
+	component.Render(r.Context(), w)
+}
+
+// HandleUpdateDevice saves the edit form of the device detail page.
+func HandleUpdateDevice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in, err := deviceFromForm(r)
+	if err != nil {
+		writeError(w, err, "Failed to update device")
+		return
+	}
+	patch := devicePatch{
+		Name:        &in.Name,
+		Role:        &in.Role,
+		Platform:    &in.Platform,
+		SiteID:      nullableUUID{Set: true, ID: in.SiteID},
+		Description: &in.Description,
+	}
+	if _, err := updateDevice(r.Context(), r.PathValue("id"), patch); err != nil {
+		writeError(w, err, "Failed to update device")
+		return
+	}
+
+	HandleDeviceDetail(w, r)
+}
+
+// HandleSetPrimaryIP makes the address chosen on the device detail page the
+// device's primary IP; an empty ip_id clears it.
+func HandleSetPrimaryIP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	ipID, err := parseRefID(r.FormValue("ip_id"), "invalid_ip", "Invalid IP ID")
+	if err != nil {
+		writeError(w, err, "Failed to set primary IP")
+		return
+	}
+	if _, err := updateDevice(r.Context(), r.PathValue("id"), devicePatch{PrimaryIPID: nullableUUID{Set: true, ID: ipID}}); err != nil {
+		writeError(w, err, "Failed to set primary IP")
+		return
+	}
+
+	HandleDeviceDetail(w, r)
+}
+
+// HandleCreateInterface adds an interface from the device detail page.
+func HandleCreateInterface(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	mac := r.FormValue("mac_address")
+	in := models.Interface{Name: r.FormValue("name"), Type: r.FormValue("type"), MACAddress: &mac}
+	if _, err := createInterface(r.Context(), r.PathValue("id"), in); err != nil {
+		writeError(w, err, "Failed to create interface")
+		return
+	}
+
+	HandleDeviceDetail(w, r)
+}
+
+// HandleDeleteInterface removes an interface of a device; its addresses are
+// kept but no longer assigned.
+func HandleDeleteInterface(w http.ResponseWriter, r *http.Request) {
+	f, err := getInterface(r.Context(), r.PathValue("interfaceID"))
+	if err != nil {
+		writeError(w, err, "Failed to delete interface")
+		return
+	}
+	if f.DeviceID.String() != r.PathValue("id") {
+		writeError(w, errNotFound("interface_not_found", "Interface is not part of this device"), "Failed to delete interface")
+		return
+	}
+	if err := deleteInterface(r.Context(), f.ID.String()); err != nil {
+		writeError(w, err, "Failed to delete interface")
+		return
+	}
+
+	HandleDeviceDetail(w, r)
+}
+
+// deviceFromForm reads the fields of the device create and edit forms.
+func deviceFromForm(r *http.Request) (models.Device, error) {
+	in := models.Device{
+		Name:        r.FormValue("name"),
+		Role:        r.FormValue("role"),
+		Platform:    r.FormValue("platform"),
+		Description: r.FormValue("description"),
+	}
+	var err error
+	in.SiteID, err = parseSiteID(r.FormValue("site_id"))
+	return in, err
+}
+
+// scanDevice scans a row selected with deviceColumns.
+func scanDevice(row interface{ Scan(...any) error }, d *models.Device) error {
+	return row.Scan(&d.ID, &d.Name, &d.Role, &d.Platform, &d.SiteID, &d.SiteName, &d.Description,
+		&d.PrimaryIPID, database.Addr(&d.PrimaryIP), &d.CreatedAt, &d.InterfaceCount)
+}
+
+// listDevices returns the devices of a site (a site ID, "none" for devices
+// without a site, or "" for all devices), ordered by name.
+func listDevices(ctx context.Context, site string) ([]models.Device, error) {
+	where, args := "TRUE", []any{}
+	switch site {
+	case "":
+	case "none":
+		where = "site_id IS NULL"
+	default:
+		id, err := parseSiteID(site)
+		if err != nil {
+			return nil, err
+		}
+		where, args = "site_id = $1", append(args, id)
+	}
+	rows, err := database.DB.Query(ctx, "SELECT "+deviceColumns+" FROM devices WHERE "+where+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []models.Device
+	for rows.Next() {
+		var d models.Device
+		if err := scanDevice(rows, &d); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+func getDevice(ctx context.Context, id string) (models.Device, error) {
+	var d models.Device
+	err := scanDevice(database.DB.QueryRow(ctx, "SELECT "+deviceColumns+" FROM devices WHERE id = $1", id), &d)
+	if isNoRows(err) {
+		return d, errNotFound("device_not_found", "Device not found")
+	}
+	return d, err
+}
+
+func createDevice(ctx context.Context, in models.Device) (models.Device, error) {
+	var d models.Device
+	if in.Name == "" {
+		return d, errInvalid("missing_field", "name is required")
+	}
+	if err := checkSiteExists(ctx, in.SiteID); err != nil {
+		return d, err
+	}
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanDevice(tx.QueryRow(ctx,
+			`INSERT INTO devices (name, role, platform, site_id, description) VALUES ($1, $2, $3, $4, $5)
+			 RETURNING `+deviceColumns,
+			in.Name, in.Role, in.Platform, in.SiteID, in.Description), &d); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, deviceAudit("create", nil, &d))
+	})
+	return d, uniqueWriteError(err, "device_exists", "A device with this name already exists")
+}
+
+// devicePatch lists the device fields that may be changed; nil fields are left as is.
+type devicePatch struct {
+	Name        *string      `json:"name"`
+	Role        *string      `json:"role"`
+	Platform    *string      `json:"platform"`
+	SiteID      nullableUUID `json:"site_id"` // null removes the device from its site
+	Description *string      `json:"description"`
+	PrimaryIPID nullableUUID `json:"primary_ip_id"` // an address assigned to one of the device's interfaces; null clears it
+}
+
+func updateDevice(ctx context.Context, id string, patch devicePatch) (models.Device, error) {
+	d, err := getDevice(ctx, id)
+	if err != nil {
+		return d, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return d, errInvalid("missing_field", "name must not be empty")
+	}
+	if patch.SiteID.Set {
+		if err := checkSiteExists(ctx, patch.SiteID.ID); err != nil {
+			return d, err
+		}
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Device
+		if err := scanDevice(tx.QueryRow(ctx, "SELECT "+deviceColumns+" FROM devices WHERE id = $1 FOR UPDATE", d.ID), &before); err != nil {
+			return err
+		}
+		d = before
+		if patch.Name != nil {
+			d.Name = *patch.Name
+		}
+		if patch.Role != nil {
+			d.Role = *patch.Role
+		}
+		if patch.Platform != nil {
+			d.Platform = *patch.Platform
+		}
+		if patch.SiteID.Set {
+			d.SiteID = patch.SiteID.ID
+		}
+		if patch.Description != nil {
+			d.Description = *patch.Description
+		}
+		if patch.PrimaryIPID.Set {
+			if err := checkPrimaryIP(ctx, tx, d.ID, patch.PrimaryIPID.ID); err != nil {
+				return err
+			}
+			d.PrimaryIPID = patch.PrimaryIPID.ID
+		}
+		if err := scanDevice(tx.QueryRow(ctx,
+			`UPDATE devices SET name = $2, role = $3, platform = $4, site_id = $5, description = $6, primary_ip_id = $7
+			 WHERE id = $1 RETURNING `+deviceColumns,
+			d.ID, d.Name, d.Role, d.Platform, d.SiteID, d.Description, d.PrimaryIPID), &d); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, deviceAudit("update", &before, &d))
+	})
+	if isNoRows(err) {
+		return d, errNotFound("device_not_found", "Device not found")
+	}
+	if database.ErrorCode(err) == database.ForeignKeyViolation {
+		return d, errIPChanged()
+	}
+	return d, uniqueWriteError(err, "device_exists", "A device with this name already exists")
+}
+
+// checkPrimaryIP reports an error unless ipID is an address assigned to an
+// interface of device deviceID, as seen by q. The invalid ID (no primary IP)
+// is always accepted.
+func checkPrimaryIP(ctx context.Context, q database.Querier, deviceID, ipID pgtype.UUID) error {
+	if !ipID.Valid {
+		return nil
+	}
+	var ok bool
+	err := q.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM ips i JOIN interfaces f ON f.id = i.interface_id
+		 WHERE i.id = $1 AND f.device_id = $2)`, ipID, deviceID).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalid("invalid_primary_ip", "The primary IP must be assigned to an interface of the device")
+	}
+	return nil
+}
+
+// clearStalePrimaryIP clears the primary IP designation of the address ipID
+// on any device it is no longer assigned to, recording the devices changed.
+func clearStalePrimaryIP(ctx context.Context, tx pgx.Tx, ipID pgtype.UUID) error {
+	deviceIDs, err := queryIDs(ctx, tx, "SELECT id FROM devices WHERE primary_ip_id = $1", ipID)
+	if err != nil {
+		return err
+	}
+	return recordChanges(ctx, tx, deviceIDs, deviceSnapshots, deviceAudit, func() error {
+		_, err := tx.Exec(ctx,
+			`UPDATE devices d SET primary_ip_id = NULL WHERE d.primary_ip_id = $1 AND NOT EXISTS (
+			   SELECT 1 FROM ips i JOIN interfaces f ON f.id = i.interface_id WHERE i.id = $1 AND f.device_id = d.id)`,
+			ipID)
+		return err
+	})
+}
+
+// recordPrimaryIPChanges runs del, which deletes the addresses ipIDs in tx,
+// and records the devices that lose their primary IP with them.
+func recordPrimaryIPChanges(ctx context.Context, tx pgx.Tx, ipIDs []pgtype.UUID, del func() error) error {
+	deviceIDs, err := queryIDs(ctx, tx, "SELECT id FROM devices WHERE primary_ip_id = ANY($1)", ipIDs)
+	if err != nil {
+		return err
+	}
+	return recordChanges(ctx, tx, deviceIDs, deviceSnapshots, deviceAudit, del)
+}
+
+// deleteDevice removes a device and its interfaces. Addresses assigned to
+// them are kept but no longer assigned.
+func deleteDevice(ctx context.Context, id string) error {
+	d, err := getDevice(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		interfaces, err := queryInterfacesOn(ctx, tx, "SELECT "+interfaceColumns+" FROM interfaces WHERE device_id = $1 ORDER BY name", d.ID)
+		if err != nil {
+			return err
+		}
+		ipIDs, err := queryIDs(ctx, tx,
+			"SELECT id FROM ips WHERE interface_id IN (SELECT id FROM interfaces WHERE device_id = $1)", d.ID)
+		if err != nil {
+			return err
+		}
+		if err := recordChanges(ctx, tx, ipIDs, ipSnapshots, ipUpdateAudit, func() error {
+			_, err := tx.Exec(ctx, "DELETE FROM devices WHERE id = $1", d.ID)
+			return err
+		}); err != nil {
+			return err
+		}
+		entries := []auditEntry{deviceAudit("delete", &d, nil)}
+		for _, f := range interfaces {
+			entries = append(entries, interfaceAudit("delete", &f, nil))
+		}
+		return recordAudit(ctx, tx, entries...)
+	})
+}
+
+// deviceSnapshots locks the devices ids on q and returns them keyed by ID,
+// for recordChanges.
+func deviceSnapshots(ctx context.Context, q database.Querier, ids []pgtype.UUID) (map[pgtype.UUID]models.Device, error) {
+	rows, err := q.Query(ctx, "SELECT "+deviceColumns+" FROM devices WHERE id = ANY($1) FOR UPDATE", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := make(map[pgtype.UUID]models.Device)
+	for rows.Next() {
+		var d models.Device
+		if err := scanDevice(rows, &d); err != nil {
+			return nil, err
+		}
+		devices[d.ID] = d
+	}
+	return devices, rows.Err()
+}
+
+// deviceAudit describes a change to a device; before is nil when it was
+// created and after is nil when it was deleted.
+func deviceAudit(action string, before, after *models.Device) auditEntry {
+	d := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectDevice, d.ID, d.Name, before, after)
+}
+
+// deviceIPs returns the addresses assigned to the interfaces of a device,
+// across all subnets.
+func deviceIPs(ctx context.Context, deviceID pgtype.UUID) ([]models.IP, error) {
+	return queryIPs(ctx,
+		"SELECT "+ipColumns+" FROM ips WHERE interface_id IN (SELECT id FROM interfaces WHERE device_id = $1) ORDER BY address",
+		deviceID)
+}
+
+// parseInterfaceID parses an interface reference from a form; empty means no interface.
+func parseInterfaceID(s string) (pgtype.UUID, error) {
+	return parseRefID(s, "invalid_interface", "Invalid interface ID")
+}
+
+// checkInterfaceExists reports an error if id refers to an interface that
+// does not exist. The invalid ID (no interface) always exists.
+func checkInterfaceExists(ctx context.Context, id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM interfaces WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("interface_not_found", "Interface not found")
+	}
+	return nil
+}
+
+// validateInterfaceType checks an interface type and returns the value to
+// store; empty means ethernet.
+func validateInterfaceType(typ string) (string, error) {
+	if typ == "" {
+		return models.InterfaceEthernet, nil
+	}
+	if !slices.Contains(models.InterfaceTypes, typ) {
+		return "", errInvalid("invalid_interface_type", "type must be one of ethernet, virtual, lag, wireless or loopback")
+	}
+	return typ, nil
+}
+
+// scanInterface scans a row selected with interfaceColumns.
+func scanInterface(row interface{ Scan(...any) error }, f *models.Interface) error {
+	if err := row.Scan(&f.ID, &f.DeviceID, &f.DeviceName, &f.Name, &f.Type, &f.MACAddress, &f.CreatedAt, &f.IPCount); err != nil {
+		return err
+	}
+	f.MACVendor = macVendor(f.MACAddress)
+	return nil
+}
+
+// listInterfaces returns the interfaces of a device (of all devices when
+// deviceID is empty), ordered by device and name.
+func listInterfaces(ctx context.Context, deviceID string) ([]models.Interface, error) {
+	where, args := "TRUE", []any{}
+	if deviceID != "" {
+		id, err := parseRefID(deviceID, "invalid_device", "Invalid device ID")
+		if err != nil {
+			return nil, err
+		}
+		where, args = "device_id = $1", append(args, id)
+	}
+	return queryInterfacesOn(ctx, database.DB,
+		"SELECT "+interfaceColumns+" FROM interfaces WHERE "+where+
+			" ORDER BY (SELECT d.name FROM devices d WHERE d.id = interfaces.device_id), name", args...)
+}
+
+// queryInterfacesOn runs a query selecting interfaceColumns on q and scans
+// the result.
+func queryInterfacesOn(ctx context.Context, q database.Querier, sql string, args ...any) ([]models.Interface, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interfaces []models.Interface
+	for rows.Next() {
+		var f models.Interface
+		if err := scanInterface(rows, &f); err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, f)
+	}
+	return interfaces, rows.Err()
+}
+
+func getInterface(ctx context.Context, id string) (models.Interface, error) {
+	var f models.Interface
+	err := scanInterface(database.DB.QueryRow(ctx, "SELECT "+interfaceColumns+" FROM interfaces WHERE id = $1", id), &f)
+	if isNoRows(err) {
+		return f, errNotFound("interface_not_found", "Interface not found")
+	}
+	return f, err
+}
+
+// createInterface adds the interface in to device deviceID.
+func createInterface(ctx context.Context, deviceID string, in models.Interface) (models.Interface, error) {
+	var f models.Interface
+	d, err := getDevice(ctx, deviceID)
+	if err != nil {
+		return f, err
+	}
+	if in.Name == "" {
+		return f, errInvalid("missing_field", "name is required")
+	}
+	typ, err := validateInterfaceType(in.Type)
+	if err != nil {
+		return f, err
+	}
+	var mac *string
+	if in.MACAddress != nil {
+		if mac, err = normalizeMAC(*in.MACAddress); err != nil {
+			return f, err
+		}
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanInterface(tx.QueryRow(ctx,
+			"INSERT INTO interfaces (device_id, name, type, mac_address) VALUES ($1, $2, $3, $4) RETURNING "+interfaceColumns,
+			d.ID, in.Name, typ, mac), &f); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, interfaceAudit("create", nil, &f))
+	})
+	return f, uniqueWriteError(err, "interface_exists", fmt.Sprintf("%s already has an interface with this name", d.Name))
+}
+
+// interfacePatch lists the interface fields that may be changed; nil fields are left as is.
+type interfacePatch struct {
+	Name       *string `json:"name"`
+	Type       *string `json:"type"`
+	MACAddress *string `json:"mac_address"` // "" removes it
+}
+
+func updateInterface(ctx context.Context, id string, patch interfacePatch) (models.Interface, error) {
+	f, err := getInterface(ctx, id)
+	if err != nil {
+		return f, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return f, errInvalid("missing_field", "name must not be empty")
+	}
+	var typ string
+	if patch.Type != nil {
+		if typ, err = validateInterfaceType(*patch.Type); err != nil {
+			return f, err
+		}
+	}
+	var mac *string
+	if patch.MACAddress != nil {
+		if mac, err = normalizeMAC(*patch.MACAddress); err != nil {
+			return f, err
+		}
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Interface
+		if err := scanInterface(tx.QueryRow(ctx, "SELECT "+interfaceColumns+" FROM interfaces WHERE id = $1 FOR UPDATE", f.ID), &before); err != nil {
+			return err
+		}
+		f = before
+		if patch.Name != nil {
+			f.Name = *patch.Name
+		}
+		if patch.Type != nil {
+			f.Type = typ
+		}
+		if patch.MACAddress != nil {
+			f.MACAddress = mac
+		}
+		if err := scanInterface(tx.QueryRow(ctx,
+			"UPDATE interfaces SET name = $2, type = $3, mac_address = $4 WHERE id = $1 RETURNING "+interfaceColumns,
+			f.ID, f.Name, f.Type, f.MACAddress), &f); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, interfaceAudit("update", &before, &f))
+	})
+	if isNoRows(err) {
+		return f, errNotFound("interface_not_found", "Interface not found")
+	}
+	return f, uniqueWriteError(err, "interface_exists", fmt.Sprintf("%s already has an interface with this name", f.DeviceName))
+}
+
+// deleteInterface removes an interface. Its addresses are kept but no longer
+// assigned; if one of them was the device's primary IP, the device has none.
+func deleteInterface(ctx context.Context, id string) error {
+	f, err := getInterface(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		ipIDs, err := queryIDs(ctx, tx, "SELECT id FROM ips WHERE interface_id = $1", f.ID)
+		if err != nil {
+			return err
+		}
+		deviceIDs, err := queryIDs(ctx, tx, "SELECT id FROM devices WHERE primary_ip_id = ANY($1)", ipIDs)
+		if err != nil {
+			return err
+		}
+		if err := recordChanges(ctx, tx, deviceIDs, deviceSnapshots, deviceAudit, func() error {
+			return recordChanges(ctx, tx, ipIDs, ipSnapshots, ipUpdateAudit, func() error {
+				if _, err := tx.Exec(ctx, "UPDATE devices SET primary_ip_id = NULL WHERE id = ANY($1)", deviceIDs); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "DELETE FROM interfaces WHERE id = $1", f.ID)
+				return err
+			})
+		}); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, interfaceAudit("delete", &f, nil))
+	})
+}
+
+// interfaceAudit describes a change to an interface, labelled with its
+// device; before is nil when it was created and after is nil when it was
+// deleted.
+func interfaceAudit(action string, before, after *models.Interface) auditEntry {
+	f := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectInterface, f.ID, f.DeviceName+" "+f.Name, before, after)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+)
+
+// appError is an error caused by the client's request. It carries a
+// machine-readable code for the JSON API and a message safe to show to users.
+type appError struct {
+	Status  int    // HTTP status used by the JSON API
+	Code    string // machine-readable code, e.g. "subnet_overlap"
+	Message string
+}
+
+func (e *appError) Error() string { return e.Message }
+
+// errInvalid reports input that failed validation (422 in the API, 400 in the UI).
+func errInvalid(code, format string, args ...any) error {
+	return &appError{Status: http.StatusUnprocessableEntity, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errNotFound reports a missing subnet, IP or other object.
+func errNotFound(code, format string, args ...any) error {
+	return &appError{Status: http.StatusNotFound, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errConflict reports a request that clashes with the current state.
+func errConflict(code, format string, args ...any) error {
+	return &appError{Status: http.StatusConflict, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// isNoRows reports whether err means the looked-up row does not exist. A
+// malformed UUID in the URL is treated the same way as an unknown one.
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || database.ErrorCode(err) == database.InvalidTextRepresentation
+}
+
+// writeError reports err to a browser. Validation failures use 400 here, as
+// the HTML forms always have; unexpected errors are logged and hidden.
+func writeError(w http.ResponseWriter, err error, fallback string) {
+	var ae *appError
+	if errors.As(err, &ae) {
+		status := ae.Status
+		if status == http.StatusUnprocessableEntity {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, ae.Message, status)
+		return
+	}
+	log.Printf("%s: %v", fallback, err)
+	http.Error(w, fallback, http.StatusInternalServerError)
+}
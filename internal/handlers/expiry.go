@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+const (
+	// defaultExpiringDays is how far ahead the expiring view looks by default.
+	defaultExpiringDays = 7
+	// defaultExtendDays is how long Extend adds to an expiry by default.
+	defaultExtendDays = 7
+	// maxExpiryDays bounds the days accepted for the view and for extensions.
+	maxExpiryDays = 3650
+	// maxExpiryResults caps the addresses and releases listed by the expiring view.
+	maxExpiryResults = 500
+	// defaultExpiryInterval is how often expired addresses are released.
+	defaultExpiryInterval = time.Minute
+)
+
+// expiryFormLayout is the format of <input type="datetime-local">.
+const expiryFormLayout = "2006-01-02T15:04"
+
+// HandleExpiringList renders the addresses expiring within ?days= (7 by
+// default) across all subnets, and the addresses released most recently
+// because they expired.
+func HandleExpiringList(w http.ResponseWriter, r *http.Request) {
+	days := min(queryInt(r, "days", defaultExpiringDays), maxExpiryDays)
+	ips, err := expiringIPs(r.Context(), days)
+	if err != nil {
+		writeError(w, err, "Failed to fetch expiring IPs")
+		return
+	}
+	expirations, err := recentExpirations(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch expired IPs")
+		return
+	}
+	// The subnets of both lists, for their CIDRs and names.
+	refs := slices.Clone(ips)
+	for _, e := range expirations {
+		refs = append(refs, models.IP{SubnetID: e.SubnetID})
+	}
+	subnets, err := subnetsOf(r.Context(), refs)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+
+	templates.ExpiringList(days, ips, expirations, subnets).Render(r.Context(), w)
+}
+
+// HandleExtendIP pushes the expiry of an address back by form value days (7
+// by default) and returns the re-rendered row.
+func HandleExtendIP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	days := defaultExtendDays
+	if s := r.FormValue("days"); s != "" {
+		var err error
+		if days, err = strconv.Atoi(s); err != nil {
+			renderIPRow(w, r, models.IP{}, errInvalid("invalid_days", "days must be a number"))
+			return
+		}
+	}
+	ip, err := extendIP(r.Context(), r.PathValue("id"), r.PathValue("address"), days)
+	renderIPRow(w, r, ip, err)
+}
+
+// parseExpiry reads an expiry from a form, either as a datetime-local value
+// in the server's time zone or in RFC 3339. Empty means no expiry.
+func parseExpiry(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(expiryFormLayout, s, time.Local)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, errInvalid("invalid_expiry", "%q is not a date and time", s)
+		}
+	}
+	return &t, nil
+}
+
+// validateExpiry checks an optional expiry, which must lie in the future.
+func validateExpiry(t *time.Time) error {
+	if t != nil && !t.After(time.Now()) {
+		return errInvalid("invalid_expiry", "expires_at must be in the future")
+	}
+	return nil
+}
+
+// extendIP pushes the expiry of an allocated or reserved address back by
+// days, counted from the current expiry or from now if that is later.
+// Addresses without an expiry are permanent and cannot be extended.
+func extendIP(ctx context.Context, subnetID, address string, days int) (models.IP, error) {
+	ip, err := getIP(ctx, subnetID, address)
+	if err != nil {
+		return ip, err
+	}
+	if days < 1 || days > maxExpiryDays {
+		return ip, errInvalid("invalid_days", "days must be between 1 and %d", maxExpiryDays)
+	}
+	switch {
+	case ip.Status == "available":
+		return ip, errConflict("ip_not_in_use", "IP address is not allocated or reserved")
+	case ip.ExpiresAt == nil:
+		return ip, errConflict("ip_not_expiring", "%s does not expire", ip.Address)
+	}
+	from := time.Now()
+	if ip.ExpiresAt.After(from) {
+		from = *ip.ExpiresAt
+	}
+	expiresAt := from.AddDate(0, 0, days)
+	before := ip
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanIP(tx.QueryRow(ctx,
+			"UPDATE ips SET expires_at = $3 WHERE id = $1 AND status = $2 RETURNING "+ipColumns,
+			ip.ID, ip.Status, expiresAt), &ip); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, ipAudit("extend", before, ip))
+	})
+	if isNoRows(err) {
+		return ip, errIPChanged()
+	}
+	return ip, err
+}
+
+// expiringIPs returns the addresses whose expiry falls within days from now,
+// soonest first.
+func expiringIPs(ctx context.Context, days int) ([]models.IP, error) {
+	return queryIPs(ctx,
+		`SELECT `+ipColumns+` FROM ips
+		 WHERE expires_at <= CURRENT_TIMESTAMP + make_interval(days => $1)
+		 ORDER BY expires_at, address LIMIT $2`,
+		days, maxExpiryResults)
+}
+
+// recentExpirations returns the latest releases of expired addresses.
+func recentExpirations(ctx context.Context) ([]models.IPExpiration, error) {
+	rows, err := database.DB.Query(ctx,
+		`SELECT id, subnet_id, address, status, hostname, expires_at, released_at, reason
+		 FROM ip_expirations ORDER BY released_at DESC, address LIMIT $1`, maxExpiryResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expirations []models.IPExpiration
+	for rows.Next() {
+		var e models.IPExpiration
+		if err := rows.Scan(&e.ID, &e.SubnetID, database.Addr(&e.Address), &e.Status, &e.Hostname,
+			&e.ExpiresAt, &e.ReleasedAt, &e.Reason); err != nil {
+			return nil, err
+		}
+		expirations = append(expirations, e)
+	}
+	return expirations, rows.Err()
+}
+
+// releaseExpired returns every address whose expiry has passed to the pool
+// and records why in ip_expirations and the audit log. Deleting and
+// recording happen in one transaction, so a release is never lost or
+// recorded twice.
+func releaseExpired(ctx context.Context) (int64, error) {
+	var released int64
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		ips, err := queryIPsOn(ctx, tx,
+			"SELECT "+ipColumns+" FROM ips WHERE expires_at <= CURRENT_TIMESTAMP ORDER BY expires_at, address FOR UPDATE")
+		if err != nil || len(ips) == 0 {
+			return err
+		}
+		ids := make([]pgtype.UUID, len(ips))
+		entries := make([]auditEntry, len(ips))
+		for i, ip := range ips {
+			ids[i] = ip.ID
+			entries[i] = ipAudit("expire", ip, models.IP{})
+		}
+		if err := recordPrimaryIPChanges(ctx, tx, ids, func() error {
+			result, err := tx.Exec(ctx,
+				`WITH expired AS (
+				   DELETE FROM ips WHERE id = ANY($1)
+				   RETURNING subnet_id, address, status, hostname, expires_at
+				 )
+				 INSERT INTO ip_expirations (subnet_id, address, status, hostname, expires_at, reason)
+				 SELECT subnet_id, address, status, hostname, expires_at,
+				   CASE status WHEN 'reserved' THEN 'reservation expired' ELSE 'allocation expired' END
+				 FROM expired`, ids)
+			released = result.RowsAffected()
+			return err
+		}); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, entries...); err != nil {
+			return err
+		}
+		return dropGateways(ctx, tx, ips)
+	})
+	return released, err
+}
+
+// expiryInterval reads once from EXPIRY_INTERVAL (a duration such as "30s")
+// how often expired addresses are released. Missing or invalid values fall
+// back to a minute.
+var expiryInterval = sync.OnceValue(func() time.Duration {
+	s := os.Getenv("EXPIRY_INTERVAL")
+	if s == "" {
+		return defaultExpiryInterval
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		log.Printf("Ignoring EXPIRY_INTERVAL=%q: expected a positive duration such as 30s", s)
+		return defaultExpiryInterval
+	}
+	return d
+})
+
+// RunExpiryWorker releases expired addresses every EXPIRY_INTERVAL until ctx
+// is done. It is started by the server next to the HTTP listener.
+func RunExpiryWorker(ctx context.Context) {
+	ticker := time.NewTicker(expiryInterval())
+	defer ticker.Stop()
+	for {
+		n, err := releaseExpired(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Failed to release expired IPs: %v", err)
+		case n > 0:
+			log.Printf("Released %d expired IP(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strings"
+
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// HandleExportSubnets downloads the subnets shown on the subnet list, with the
+// same filters, as CSV. Custom fields follow the fixed columns, one column per
+// field named after it.
+func HandleExportSubnets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := subnetFilter{VRF: q.Get("vrf"), Site: q.Get("site"), Tenant: q.Get("tenant"), Tag: q.Get("tag")}
+	var err error
+	if filter.Fields, err = customFieldFilters(r.Context(), models.CustomFieldObjectSubnet, q); err != nil {
+		writeError(w, err, "Failed to export subnets")
+		return
+	}
+	subnets, err := listSubnets(r.Context(), filter)
+	if err != nil {
+		writeError(w, err, "Failed to export subnets")
+		return
+	}
+	fields, err := listCustomFields(r.Context(), models.CustomFieldObjectSubnet)
+	if err != nil {
+		writeError(w, err, "Failed to export subnets")
+		return
+	}
+
+	header := []string{"cidr", "name", "kind", "vrf", "vlan", "site", "tenant", "tags"}
+	rows := make([][]string, 0, len(subnets))
+	for _, s := range subnets {
+		rows = append(rows, append([]string{s.CIDR, s.Name, s.Kind, s.VRFName, s.VLANLabel, s.SiteName, s.TenantName, tagNames(s.Tags)},
+			customFieldTexts(fields, s.CustomFields)...))
+	}
+	writeCSV(w, "subnets.csv", append(header, customFieldNames(fields)...), rows)
+}
+
+// HandleExportIPs downloads the allocated and reserved addresses of a subnet
+// as CSV. The tenant column is empty for addresses that belong to the
+// subnet's tenant.
+func HandleExportIPs(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getNetwork(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to export IPs")
+		return
+	}
+	ips, err := storedIPs(r.Context(), subnet)
+	if err != nil {
+		writeError(w, err, "Failed to export IPs")
+		return
+	}
+	fields, err := listCustomFields(r.Context(), models.CustomFieldObjectIP)
+	if err != nil {
+		writeError(w, err, "Failed to export IPs")
+		return
+	}
+
+	header := []string{"address", "status", "hostname", "mac_address", "tenant", "tags"}
+	rows := make([][]string, 0, len(ips))
+	for _, ip := range ips {
+		rows = append(rows, append([]string{ip.Address, ip.Status, hostnameOf(ip), macOf(ip), ip.TenantName, tagNames(ip.Tags)},
+			customFieldTexts(fields, ip.CustomFields)...))
+	}
+	writeCSV(w, strings.NewReplacer("/", "_", ":", "_").Replace(subnet.CIDR)+".csv", append(header, customFieldNames(fields)...), rows)
+}
+
+// storedIPs returns the allocated and reserved addresses of a subnet.
+func storedIPs(ctx context.Context, subnet models.Subnet) ([]models.IP, error) {
+	return queryIPs(ctx,
+		"SELECT "+ipColumns+" FROM ips WHERE subnet_id = $1 AND status <> 'available' ORDER BY address", subnet.ID)
+}
+
+// writeCSV sends header and rows as a CSV attachment named filename.
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+}
+
+// tagNames joins the names of tags with semicolons.
+func tagNames(tags []models.TagRef) string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ";")
+}
+
+// customFieldNames returns the names of fields, used as column headers.
+func customFieldNames(fields []models.CustomField) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// customFieldTexts returns the values of fields in values as text.
+func customFieldTexts(fields []models.CustomField, values map[string]any) []string {
+	texts := make([]string, len(fields))
+	for i, f := range fields {
+		texts[i] = f.Text(values)
+	}
+	return texts
+}
@@ -2,12 +2,23 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"regexp"
 	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
 	"github.com/ttani03/goth-ipam/internal/models"
 	"github.com/ttani03/goth-ipam/internal/templates"
 )
@@ -19,107 +30,170 @@ var hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0
 // validPageSizes lists the allowed per-page values for IP list pagination.
 var validPageSizes = map[int]bool{30: true, 50: true, 100: true}
 
+// maxAllocateChoices caps the number of free addresses offered in the allocate dropdown.
+const maxAllocateChoices = 256
+
+// ipColumns is the column list scanned by scanIP.
+const ipColumns = `id, subnet_id, address, status, hostname,
+	mac_address, (SELECT COUNT(*) FROM ips o WHERE o.mac_address = ips.mac_address AND o.id <> ips.id),
+	tenant_id, COALESCE((SELECT t.name FROM tenants t WHERE t.id = ips.tenant_id), ''),
+	interface_id, COALESCE((SELECT f.name FROM interfaces f WHERE f.id = ips.interface_id), ''),
+	(SELECT f.device_id FROM interfaces f WHERE f.id = ips.interface_id),
+	COALESCE((SELECT d.name FROM interfaces f JOIN devices d ON d.id = f.device_id WHERE f.id = ips.interface_id), ''),
+	COALESCE((SELECT json_agg(json_build_object('id', t.id, 'name', t.name, 'color', t.color) ORDER BY t.name)
+	 FROM ip_tags x JOIN tags t ON t.id = x.tag_id WHERE x.ip_id = ips.id), '[]'),
+	custom_fields, COALESCE(reservation_reason, ''), expires_at, created_at`
+
+// Strategies for picking the address in allocateNextIP.
+const (
+	strategyLowest  = "lowest"
+	strategyHighest = "highest"
+	strategyRandom  = "random"
+)
+
+// maxAllocateAttempts bounds how often allocateNextIP retries when a single-address
+// allocation takes its candidate between the read and the insert.
+const maxAllocateAttempts = 3
+
 func HandleSubnetDetail(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	// --- pagination parameters ---
-	pageSize := 30
+	q := ipQuery{Page: 1, PageSize: 30}
 	if ps, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && validPageSizes[ps] {
-		pageSize = ps
+		q.PageSize = ps
 	}
-	page := 1
 	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
-		page = p
+		q.Page = p
 	}
-	offset := (page - 1) * pageSize
 
 	// Optional status filter (empty = all)
-	statusFilter := r.URL.Query().Get("status")
+	q.Status = r.URL.Query().Get("status")
 
-	var subnet models.Subnet
-	err := database.DB.QueryRow(context.Background(), "SELECT id, cidr, name, created_at FROM subnets WHERE id = $1", id).Scan(&subnet.ID, &subnet.CIDR, &subnet.Name, &subnet.CreatedAt)
+	// Optional tenant filter (empty = all)
+	tenantID, err := parseTenantID(r.URL.Query().Get("tenant"))
 	if err != nil {
-		http.Error(w, "Subnet not found", http.StatusNotFound)
+		writeError(w, err, "Failed to fetch IPs")
 		return
 	}
+	q.Tenant = tenantID
 
-	// --- count total IPs (for pagination meta) ---
-	var totalCount int
-	if statusFilter != "" && statusFilter != "all" {
-		err = database.DB.QueryRow(context.Background(),
-			"SELECT COUNT(*) FROM ips WHERE subnet_id = $1 AND status = $2", id, statusFilter).Scan(&totalCount)
-	} else {
-		err = database.DB.QueryRow(context.Background(),
-			"SELECT COUNT(*) FROM ips WHERE subnet_id = $1", id).Scan(&totalCount)
-	}
+	// Optional tag filter (empty = all)
+	tagID, err := parseTagID(r.URL.Query().Get("tag"))
 	if err != nil {
-		http.Error(w, "Failed to count IPs", http.StatusInternalServerError)
+		writeError(w, err, "Failed to fetch IPs")
 		return
 	}
+	q.Tag = tagID
+
+	// Optional custom field filters (cf_<name>=<value>)
+	if q.Fields, err = customFieldFilters(r.Context(), models.CustomFieldObjectIP, r.URL.Query()); err != nil {
+		writeError(w, err, "Failed to fetch IPs")
+		return
+	}
+
+	// Optional jump to the page containing a given address (unfiltered view only).
+	if target, err := netip.ParseAddr(r.URL.Query().Get("goto")); err == nil {
+		q.Goto = target
+	}
 
-	// --- fetch paginated IPs ---
-	var rows interface {
-		Next() bool
-		Scan(...any) error
-		Close()
+	subnet, err := getSubnet(r.Context(), id)
+	if err == nil {
+		subnet, err = subnetWithStats(r.Context(), subnet)
 	}
-	var queryErr error
-	if statusFilter != "" && statusFilter != "all" {
-		rows, queryErr = database.DB.Query(context.Background(),
-			"SELECT id, subnet_id, address, status, hostname, created_at FROM ips WHERE subnet_id = $1 AND status = $2 ORDER BY address::inet LIMIT $3 OFFSET $4",
-			id, statusFilter, pageSize, offset)
-	} else {
-		rows, queryErr = database.DB.Query(context.Background(),
-			"SELECT id, subnet_id, address, status, hostname, created_at FROM ips WHERE subnet_id = $1 ORDER BY address::inet LIMIT $2 OFFSET $3",
-			id, pageSize, offset)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnet")
+		return
 	}
-	if queryErr != nil {
-		http.Error(w, "Failed to fetch IPs", http.StatusInternalServerError)
+	ancestors, err := ancestorSubnets(r.Context(), subnet)
+	if err != nil {
+		writeError(w, err, "Failed to fetch parent subnets")
 		return
 	}
-	defer rows.Close()
 
-	var ips []models.IP
-	for rows.Next() {
-		var ip models.IP
-		if err := rows.Scan(&ip.ID, &ip.SubnetID, &ip.Address, &ip.Status, &ip.Hostname, &ip.CreatedAt); err != nil {
-			continue
+	// Containers hold child subnets instead of host addresses.
+	if subnet.Kind == models.SubnetKindContainer {
+		descendants, err := descendantSubnets(r.Context(), subnet)
+		if err == nil {
+			err = fillSubnetStats(r.Context(), descendants)
 		}
-		ips = append(ips, ip)
+		if err != nil {
+			writeError(w, err, "Failed to fetch child subnets")
+			return
+		}
+		fields, err := listCustomFields(r.Context(), models.CustomFieldObjectSubnet)
+		if err != nil {
+			writeError(w, err, "Failed to fetch custom fields")
+			return
+		}
+		templates.ContainerDetail(subnet, ancestors, subnetTree(descendants, subnet.ID), fields).Render(r.Context(), w)
+		return
 	}
 
-	// Fetch available (unassigned) IPs for the allocate dropdown (no pagination needed here)
-	availRows, err := database.DB.Query(context.Background(), "SELECT id, subnet_id, address, status, hostname, created_at FROM ips WHERE subnet_id = $1 AND status = 'available' ORDER BY address::inet", id)
+	result, err := listIPs(r.Context(), subnet, q)
+	if err != nil {
+		writeError(w, err, "Failed to fetch IPs")
+		return
+	}
+	ranges, err := listRanges(r.Context(), subnet.ID)
 	if err != nil {
-		http.Error(w, "Failed to fetch available IPs", http.StatusInternalServerError)
+		writeError(w, err, "Failed to fetch ranges")
 		return
 	}
-	defer availRows.Close()
+	setRanges(result.IPs, ranges)
 
-	var availableIPs []models.IP
-	for availRows.Next() {
-		var ip models.IP
-		if err := availRows.Scan(&ip.ID, &ip.SubnetID, &ip.Address, &ip.Status, &ip.Hostname, &ip.CreatedAt); err != nil {
-			continue
-		}
-		availableIPs = append(availableIPs, ip)
+	// Offer the lowest free addresses in the allocate dropdown.
+	availableIPs, err := availableChoices(r.Context(), subnet)
+	if err != nil {
+		writeError(w, err, "Failed to fetch available IPs")
+		return
+	}
+	tenants, err := listTenants(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tenants")
+		return
+	}
+	tags, err := listTags(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tags")
+		return
+	}
+	interfaces, err := listInterfaces(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch interfaces")
+		return
+	}
+	// Subnet fields are shown in the header, IP fields in the table.
+	fields, err := listCustomFields(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch custom fields")
+		return
 	}
 
 	// Build pagination metadata
-	totalPages := (totalCount + pageSize - 1) / pageSize
-	if totalPages == 0 {
-		totalPages = 1
+	totalCount := clampInt(result.Total)
+	totalPages := totalCount / q.PageSize
+	if totalCount%q.PageSize != 0 || totalPages == 0 {
+		totalPages++
 	}
 	pagination := templates.PaginationMeta{
-		Page:         page,
-		PageSize:     pageSize,
+		Page:         result.Page,
+		PageSize:     q.PageSize,
 		TotalCount:   totalCount,
+		TotalLabel:   result.Total.String(),
 		TotalPages:   totalPages,
-		StatusFilter: statusFilter,
+		StatusFilter: q.Status,
 	}
+	if q.Tenant.Valid {
+		pagination.TenantFilter = q.Tenant.String()
+	}
+	if q.Tag.Valid {
+		pagination.TagFilter = q.Tag.String()
+	}
+	pagination.FieldFilters = q.Fields
 
-	component := templates.SubnetDetail(subnet, ips, availableIPs, pagination)
-	component.Render(context.Background(), w)
+	component := templates.SubnetDetail(subnet, ancestors, result.IPs, availableIPs, ranges, tenants, tags, interfaces, fields, pagination)
+	component.Render(r.Context(), w)
 }
 
 func HandleAllocateIP(w http.ResponseWriter, r *http.Request) {
@@ -130,32 +204,927 @@ func HandleAllocateIP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	address := r.FormValue("address")
-	hostname := r.FormValue("hostname")
+	in, err := ipFromForm(r)
+	if err != nil {
+		writeError(w, err, "Failed to allocate IP")
+		return
+	}
+	if _, err := allocateIP(r.Context(), subnetID, in); err != nil {
+		writeError(w, err, "Failed to allocate IP")
+		return
+	}
+
+	http.Redirect(w, r, allocatedURL(r, subnetID, "/subnets/"+subnetID), http.StatusSeeOther)
+}
+
+// HandleAllocateNextIP allocates the next free address of a subnet and shows
+// the page containing it.
+func HandleAllocateNextIP(w http.ResponseWriter, r *http.Request) {
+	subnetID := r.PathValue("id")
 
-	if hostname != "" && !hostnameRegex.MatchString(hostname) {
-		http.Error(w, "Invalid hostname format", http.StatusBadRequest)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
-	var hostnameArg interface{}
-	if hostname != "" {
-		hostnameArg = hostname
+	in, err := ipFromForm(r)
+	if err != nil {
+		writeError(w, err, "Failed to allocate IP")
+		return
+	}
+	rangeID, err := parseRangeID(r.FormValue("range_id"))
+	if err != nil {
+		writeError(w, err, "Failed to allocate IP")
+		return
+	}
+	ip, err := allocateNextIP(r.Context(), subnetID, in, r.FormValue("strategy"), rangeID)
+	if err != nil {
+		writeError(w, err, "Failed to allocate IP")
+		return
 	}
 
-	result, err := database.DB.Exec(context.Background(),
-		"UPDATE ips SET status = 'allocated', hostname = $1 WHERE subnet_id = $2 AND address = $3 AND status = 'available'",
-		hostnameArg, subnetID, address)
+	http.Redirect(w, r, allocatedURL(r, subnetID, "/subnets/"+subnetID+"?goto="+url.QueryEscape(ip.Address)), http.StatusSeeOther)
+}
+
+// allocatedURL is where the allocate form returns to: the map it was opened
+// from (form value view=map, with the prefix zoomed into), otherwise table.
+func allocatedURL(r *http.Request, subnetID, table string) string {
+	if r.FormValue("view") != "map" {
+		return table
+	}
+	u := "/subnets/" + subnetID + "/map"
+	if prefix := r.FormValue("prefix"); prefix != "" {
+		u += "?prefix=" + url.QueryEscape(prefix)
+	}
+	return u
+}
+
+// HandleIPRow renders a single row of the IP table, e.g. when an inline edit is cancelled.
+func HandleIPRow(w http.ResponseWriter, r *http.Request) {
+	ip, err := getIP(r.Context(), r.PathValue("id"), r.PathValue("address"))
+	renderIPRow(w, r, ip, err)
+}
+
+// HandleEditIPRow renders a row of the IP table with an inline edit form.
+func HandleEditIPRow(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getSubnet(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnet")
+		return
+	}
+	ip, err := getIP(r.Context(), r.PathValue("id"), r.PathValue("address"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch IP")
+		return
+	}
+	if ip.Status == "available" {
+		renderIPRow(w, r, ip, errConflict("ip_not_in_use", "Only allocated or reserved addresses have a hostname"))
+		return
+	}
+	tenants, err := listTenants(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tenants")
+		return
+	}
+	interfaces, err := listInterfaces(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch interfaces")
+		return
+	}
+	fields, err := listCustomFields(r.Context(), models.CustomFieldObjectIP)
 	if err != nil {
-		log.Printf("Error allocating IP: %v", err)
-		http.Error(w, "Failed to allocate IP", http.StatusInternalServerError)
+		writeError(w, err, "Failed to fetch custom fields")
 		return
 	}
+	templates.IPRowEdit(subnet, ip, tenants, interfaces, fields).Render(r.Context(), w)
+}
+
+// HandleUpdateIP saves the hostname, MAC address, tenant, interface, expiry and custom fields submitted from the inline edit form.
+func HandleUpdateIP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	hostname, mac := r.FormValue("hostname"), r.FormValue("mac_address")
+	patch := ipPatch{Hostname: &hostname, MACAddress: &mac, CustomFields: customFieldsFromForm(r.PostForm)}
+	// The tenant select is only shown once tenants exist.
+	if r.Form.Has("tenant_id") {
+		id, err := parseTenantID(r.FormValue("tenant_id"))
+		if err != nil {
+			renderIPRow(w, r, models.IP{}, err)
+			return
+		}
+		patch.TenantID = nullableUUID{Set: true, ID: id}
+	}
+	// Likewise the interface select once interfaces exist.
+	if r.Form.Has("interface_id") {
+		id, err := parseInterfaceID(r.FormValue("interface_id"))
+		if err != nil {
+			renderIPRow(w, r, models.IP{}, err)
+			return
+		}
+		patch.InterfaceID = nullableUUID{Set: true, ID: id}
+	}
+	if r.Form.Has("expires_at") {
+		t, err := parseExpiry(r.FormValue("expires_at"))
+		if err != nil {
+			renderIPRow(w, r, models.IP{}, err)
+			return
+		}
+		patch.ExpiresAt = nullableTime{Set: true, Time: t}
+	}
+	ip, err := updateIP(r.Context(), r.PathValue("id"), r.PathValue("address"), patch)
+	renderIPRow(w, r, ip, err)
+}
 
-	if result.RowsAffected() == 0 {
-		http.Error(w, "IP address not available or not found", http.StatusConflict)
+// ipFromForm reads the address, hostname, MAC address, tenant, interface, expiry and custom fields of the allocate form.
+func ipFromForm(r *http.Request) (models.IP, error) {
+	hostname, mac := r.FormValue("hostname"), r.FormValue("mac_address")
+	in := models.IP{
+		Address:      r.FormValue("address"),
+		Hostname:     &hostname,
+		MACAddress:   &mac,
+		CustomFields: customFieldsFromForm(r.PostForm),
+	}
+	var err error
+	if in.TenantID, err = parseTenantID(r.FormValue("tenant_id")); err != nil {
+		return in, err
+	}
+	if in.ExpiresAt, err = parseExpiry(r.FormValue("expires_at")); err != nil {
+		return in, err
+	}
+	in.InterfaceID, err = parseInterfaceID(r.FormValue("interface_id"))
+	return in, err
+}
+
+// HandleReserveIP reserves an address; form value force=true is needed for allocated ones.
+func HandleReserveIP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	ip, err := reserveIP(r.Context(), r.PathValue("id"), r.PathValue("address"), nil, nil, r.FormValue("force") == "true")
+	renderIPRow(w, r, ip, err)
+}
+
+// HandleUnreserveIP returns a reserved address to the available pool.
+func HandleUnreserveIP(w http.ResponseWriter, r *http.Request) {
+	ip, err := unreserveIP(r.Context(), r.PathValue("id"), r.PathValue("address"))
+	renderIPRow(w, r, ip, err)
+}
+
+// HandleReleaseIP returns an allocated address to the available pool; form
+// value force=true is needed for reserved ones.
+func HandleReleaseIP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	ip, err := releaseIP(r.Context(), r.PathValue("id"), r.PathValue("address"), r.FormValue("force") == "true")
+	renderIPRow(w, r, ip, err)
+}
+
+// renderIPRow answers an inline row action with the re-rendered row. A refused
+// action (e.g. an invalid transition) re-renders the current row with the reason,
+// because htmx does not swap in error responses.
+func renderIPRow(w http.ResponseWriter, r *http.Request, ip models.IP, err error) {
+	subnet, serr := getSubnet(r.Context(), r.PathValue("id"))
+	if serr != nil {
+		writeError(w, serr, "Failed to fetch subnet")
+		return
+	}
+	var errMsg string
+	var ae *appError
+	if errors.As(err, &ae) && ae.Status != http.StatusNotFound {
+		errMsg = ae.Message
+		if ip, err = getIP(r.Context(), r.PathValue("id"), r.PathValue("address")); err != nil {
+			writeError(w, err, "Failed to fetch IP")
+			return
+		}
+	}
+	if err != nil {
+		writeError(w, err, "Failed to update IP")
+		return
+	}
+	fields, err := listCustomFields(r.Context(), models.CustomFieldObjectIP)
+	if err != nil {
+		writeError(w, err, "Failed to fetch custom fields")
+		return
+	}
+	ranges, err := listRanges(r.Context(), subnet.ID)
+	if err != nil {
+		writeError(w, err, "Failed to fetch ranges")
 		return
 	}
+	ips := []models.IP{ip}
+	setRanges(ips, ranges)
+	templates.IPRow(subnet, ips[0], fields, errMsg).Render(r.Context(), w)
+}
+
+// ipQuery selects one page of a subnet's addresses.
+type ipQuery struct {
+	Status   string            // "" or "all", "available", or a stored status such as "allocated"
+	Tenant   pgtype.UUID       // if valid, only addresses owned by this tenant
+	Tag      pgtype.UUID       // if valid, only addresses carrying this tag
+	Fields   map[string]string // if set, only addresses with these custom field values (see customFieldFilters)
+	Page     int               // 1-indexed
+	PageSize int               // addresses per page
+	Goto     netip.Addr        // if valid, overrides Page with the page containing this address (unfiltered view only)
+}
+
+// ipPage is one page of a subnet's addresses.
+type ipPage struct {
+	IPs   []models.IP
+	Page  int      // page actually returned (differs from ipQuery.Page when Goto is used)
+	Total *big.Int // number of addresses matching the filter; may exceed int64 for IPv6
+}
+
+// listIPs returns one page of a subnet's addresses. Available addresses are
+// computed from the CIDR; stored rows are overlaid where they exist.
+func listIPs(ctx context.Context, subnet models.Subnet, q ipQuery) (ipPage, error) {
+	result := ipPage{Page: q.Page, Total: new(big.Int)}
+	prefix, err := netip.ParsePrefix(subnet.CIDR)
+	if err != nil {
+		return result, err
+	}
+	first, last := ipcalc.HostRange(prefix)
+	if q.Tenant.Valid || q.Tag.Valid || len(q.Fields) > 0 {
+		return listStoredIPs(ctx, subnet, q)
+	}
+
+	switch q.Status {
+	case "", "all":
+		if target := q.Goto; target.IsValid() {
+			if prefix.Addr().Is4() {
+				target = target.Unmap()
+			}
+			if !target.Less(first) && !last.Less(target) {
+				result.Page = int(ipcalc.Distance(first, target)/uint64(q.PageSize)) + 1
+			}
+		}
+		offset := uint64(result.Page-1) * uint64(q.PageSize)
 
-	http.Redirect(w, r, "/subnets/"+subnetID, http.StatusSeeOther)
+		// Page through the whole host range and overlay any stored rows.
+		result.Total = ipcalc.HostCount(prefix)
+		var addrs []netip.Addr
+		if start, ok := ipcalc.Add(first, offset); ok && !last.Less(start) {
+			for a := start; len(addrs) < q.PageSize; a = a.Next() {
+				addrs = append(addrs, a)
+				if a == last {
+					break
+				}
+			}
+		}
+		result.IPs, err = ipsForAddrs(ctx, subnet, addrs)
+	case "available":
+		// Addresses that are allocated or reserved; everything else in the CIDR is available.
+		used, err := usedAddrs(ctx, database.DB, subnet.ID)
+		if err != nil {
+			return result, err
+		}
+		if result.Total.Sub(ipcalc.HostCount(prefix), big.NewInt(int64(len(used)))); result.Total.Sign() < 0 {
+			result.Total.SetInt64(0)
+		}
+		offset := uint64(q.Page-1) * uint64(q.PageSize)
+		result.IPs, err = ipsForAddrs(ctx, subnet, ipcalc.FreeAddrs(first, last, used, offset, q.PageSize))
+		return result, err
+	default:
+		var count int64
+		if err := database.DB.QueryRow(ctx,
+			"SELECT COUNT(*) FROM ips WHERE subnet_id = $1 AND status = $2", subnet.ID, q.Status).Scan(&count); err != nil {
+			return result, err
+		}
+		result.Total.SetInt64(count)
+		result.IPs, err = queryIPs(ctx,
+			"SELECT "+ipColumns+" FROM ips WHERE subnet_id = $1 AND status = $2 ORDER BY address LIMIT $3 OFFSET $4",
+			subnet.ID, q.Status, q.PageSize, (q.Page-1)*q.PageSize)
+	}
+	return result, err
+}
+
+// listStoredIPs returns one page of the addresses of subnet matching the
+// tenant, tag and custom field filters of q. A tenant owns an address either
+// directly or through the subnet. Available addresses are not stored and
+// belong to nobody and carry no tags or custom fields, so they never match.
+func listStoredIPs(ctx context.Context, subnet models.Subnet, q ipQuery) (ipPage, error) {
+	result := ipPage{Page: q.Page, Total: new(big.Int)}
+	where := "subnet_id = $1 AND status <> 'available'"
+	args := []any{subnet.ID}
+	if q.Tenant.Valid {
+		args = append(args, subnet.TenantID, q.Tenant)
+		where += fmt.Sprintf(" AND COALESCE(tenant_id, $%d::uuid) = $%d", len(args)-1, len(args))
+	}
+	if q.Tag.Valid {
+		args = append(args, q.Tag)
+		where += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM ip_tags x WHERE x.ip_id = ips.id AND x.tag_id = $%d)", len(args))
+	}
+	for _, cond := range customFieldConds(q.Fields, &args) {
+		where += " AND " + cond
+	}
+	switch q.Status {
+	case "", "all":
+	case "available":
+		return result, nil
+	default:
+		args = append(args, q.Status)
+		where += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	var count int64
+	if err := database.DB.QueryRow(ctx, "SELECT COUNT(*) FROM ips WHERE "+where, args...).Scan(&count); err != nil {
+		return result, err
+	}
+	result.Total.SetInt64(count)
+	args = append(args, q.PageSize, (q.Page-1)*q.PageSize)
+	var err error
+	result.IPs, err = queryIPs(ctx,
+		fmt.Sprintf("SELECT %s FROM ips WHERE %s ORDER BY address LIMIT $%d OFFSET $%d", ipColumns, where, len(args)-1, len(args)),
+		args...)
+	return result, err
+}
+
+// freeAddrs returns up to limit of the lowest available addresses of a subnet.
+func freeAddrs(ctx context.Context, subnet models.Subnet, limit int) ([]netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(subnet.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	used, err := usedAddrs(ctx, database.DB, subnet.ID)
+	if err != nil {
+		return nil, err
+	}
+	first, last := ipcalc.HostRange(prefix)
+	return ipcalc.FreeAddrs(first, last, used, 0, limit), nil
+}
+
+// subnetAddr parses address and checks that it is a usable host address of subnet.
+func subnetAddr(subnet models.Subnet, address string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return addr, errInvalid("invalid_address", "Invalid IP address")
+	}
+	prefix, err := netip.ParsePrefix(subnet.CIDR)
+	if err != nil {
+		return addr, err
+	}
+	// Accept IPv4-mapped input (::ffff:a.b.c.d) for IPv4 subnets and use the plain form.
+	if prefix.Addr().Is4() {
+		addr = addr.Unmap()
+	}
+	if first, last := ipcalc.HostRange(prefix); addr.Less(first) || last.Less(addr) {
+		return addr, errInvalid("address_outside_subnet", "IP address is not a usable host in this subnet")
+	}
+	return addr, nil
+}
+
+// validateHostname checks an optional hostname and returns the value to store (nil when empty).
+func validateHostname(hostname string) (any, error) {
+	if hostname == "" {
+		return nil, nil
+	}
+	if !hostnameRegex.MatchString(hostname) {
+		return nil, errInvalid("invalid_hostname", "Invalid hostname format")
+	}
+	return hostname, nil
+}
+
+// getIP returns the address of a subnet, whether stored or computed as available.
+func getIP(ctx context.Context, subnetID, address string) (models.IP, error) {
+	subnet, err := getNetwork(ctx, subnetID)
+	if err != nil {
+		return models.IP{}, err
+	}
+	addr, err := subnetAddr(subnet, address)
+	if err != nil {
+		return models.IP{}, errNotFound("ip_not_found", "IP address not found in this subnet")
+	}
+	ips, err := ipsForAddrs(ctx, subnet, []netip.Addr{addr})
+	if err != nil {
+		return models.IP{}, err
+	}
+	return ips[0], nil
+}
+
+// hostnameOf returns the hostname of ip, or "" when it has none.
+func hostnameOf(ip models.IP) string {
+	if ip.Hostname == nil {
+		return ""
+	}
+	return *ip.Hostname
+}
+
+// macOf returns the MAC address of ip as given, or "" when it has none.
+func macOf(ip models.IP) string {
+	if ip.MACAddress == nil {
+		return ""
+	}
+	return *ip.MACAddress
+}
+
+// allocateIP allocates the Address of in with its Hostname, MACAddress, TenantID, InterfaceID, ExpiresAt and CustomFields.
+func allocateIP(ctx context.Context, subnetID string, in models.IP) (models.IP, error) {
+	var ip models.IP
+	hostnameArg, err := validateHostname(hostnameOf(in))
+	if err != nil {
+		return ip, err
+	}
+	if err := validateExpiry(in.ExpiresAt); err != nil {
+		return ip, err
+	}
+	mac, err := normalizeMAC(macOf(in))
+	if err != nil {
+		return ip, err
+	}
+	if err := checkTenantExists(ctx, in.TenantID); err != nil {
+		return ip, err
+	}
+	if err := checkInterfaceExists(ctx, in.InterfaceID); err != nil {
+		return ip, err
+	}
+	customFields, err := applyCustomFields(ctx, models.CustomFieldObjectIP, nil, in.CustomFields)
+	if err != nil {
+		return ip, err
+	}
+	subnet, err := getNetwork(ctx, subnetID)
+	if err != nil {
+		return ip, err
+	}
+	addr, err := subnetAddr(subnet, in.Address)
+	if err != nil {
+		return ip, err
+	}
+
+	// Available addresses have no row yet, so allocation inserts one. An existing row is
+	// only taken over while it is still available.
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanIP(tx.QueryRow(ctx,
+			`INSERT INTO ips (subnet_id, address, status, hostname, tenant_id, custom_fields, interface_id, mac_address, expires_at)
+			 VALUES ($1, $2, 'allocated', $3, $4, $5, $6, $7, $8)
+			 ON CONFLICT (subnet_id, address) DO UPDATE
+			 SET status = 'allocated', hostname = EXCLUDED.hostname, tenant_id = EXCLUDED.tenant_id,
+			     custom_fields = EXCLUDED.custom_fields, interface_id = EXCLUDED.interface_id, mac_address = EXCLUDED.mac_address,
+			     expires_at = EXCLUDED.expires_at
+			 WHERE ips.status = 'available'
+			 RETURNING `+ipColumns,
+			subnet.ID, addr, hostnameArg, in.TenantID, customFields, in.InterfaceID, mac, in.ExpiresAt), &ip); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, ipAudit("allocate", models.IP{}, ip))
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ip, errConflict("ip_in_use", "IP address is already in use")
+	}
+	return ip, err
+}
+
+// allocateNextIP allocates a free address of the subnet chosen by strategy
+// ("lowest" when empty, "highest" or "random") with the Hostname, MACAddress,
+// TenantID, InterfaceID, ExpiresAt and CustomFields of in. If rangeID is valid, the address
+// is taken from that range of the subnet. Concurrent callers are serialized on the subnet row, so no two of
+// them receive the same address.
+func allocateNextIP(ctx context.Context, subnetID string, in models.IP, strategy string, rangeID pgtype.UUID) (models.IP, error) {
+	var ip models.IP
+	switch strategy {
+	case "":
+		strategy = strategyLowest
+	case strategyLowest, strategyHighest, strategyRandom:
+	default:
+		return ip, errInvalid("invalid_strategy", "strategy must be one of lowest, highest or random")
+	}
+	hostnameArg, err := validateHostname(hostnameOf(in))
+	if err != nil {
+		return ip, err
+	}
+	if err := validateExpiry(in.ExpiresAt); err != nil {
+		return ip, err
+	}
+	mac, err := normalizeMAC(macOf(in))
+	if err != nil {
+		return ip, err
+	}
+	if err := checkTenantExists(ctx, in.TenantID); err != nil {
+		return ip, err
+	}
+	if err := checkInterfaceExists(ctx, in.InterfaceID); err != nil {
+		return ip, err
+	}
+	customFields, err := applyCustomFields(ctx, models.CustomFieldObjectIP, nil, in.CustomFields)
+	if err != nil {
+		return ip, err
+	}
+	subnet, err := getNetwork(ctx, subnetID)
+	if err != nil {
+		return ip, err
+	}
+	prefix, err := netip.ParsePrefix(subnet.CIDR)
+	if err != nil {
+		return ip, err
+	}
+	first, last := ipcalc.HostRange(prefix)
+	full := errConflict("subnet_full", "No available IP addresses in %s", subnet.CIDR)
+	if rangeID.Valid {
+		rg, err := getRange(ctx, rangeID.String())
+		var ae *appError
+		if errors.As(err, &ae) || err == nil && rg.SubnetID != subnet.ID {
+			return ip, errInvalid("range_not_found", "Range not found in this subnet")
+		}
+		if err != nil {
+			return ip, err
+		}
+		first, _ = netip.ParseAddr(rg.StartAddress)
+		last, _ = netip.ParseAddr(rg.EndAddress)
+		full = errConflict("range_full", "No available IP addresses in range %s-%s", first, last)
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return ip, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the subnet row until commit. Other next-free allocations wait here and
+	// then see the row inserted below.
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM subnets WHERE id = $1 FOR UPDATE", subnet.ID); err != nil {
+		return ip, err
+	}
+
+	for attempt := 0; attempt < maxAllocateAttempts; attempt++ {
+		used, err := usedAddrs(ctx, tx, subnet.ID)
+		if err != nil {
+			return ip, err
+		}
+		addr, ok, err := pickFreeAddr(first, last, used, strategy)
+		if err != nil {
+			return ip, err
+		}
+		if !ok {
+			return ip, full
+		}
+
+		// Single-address allocations do not take the subnet lock, so the candidate may
+		// have been taken meanwhile; in that case nothing is returned and we pick again.
+		err = scanIP(tx.QueryRow(ctx,
+			`INSERT INTO ips (subnet_id, address, status, hostname, tenant_id, custom_fields, interface_id, mac_address, expires_at)
+			 VALUES ($1, $2, 'allocated', $3, $4, $5, $6, $7, $8)
+			 ON CONFLICT (subnet_id, address) DO UPDATE
+			 SET status = 'allocated', hostname = EXCLUDED.hostname, tenant_id = EXCLUDED.tenant_id,
+			     custom_fields = EXCLUDED.custom_fields, interface_id = EXCLUDED.interface_id, mac_address = EXCLUDED.mac_address,
+			     expires_at = EXCLUDED.expires_at
+			 WHERE ips.status = 'available'
+			 RETURNING `+ipColumns,
+			subnet.ID, addr, hostnameArg, in.TenantID, customFields, in.InterfaceID, mac, in.ExpiresAt), &ip)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return ip, err
+		}
+		if err := recordAudit(ctx, tx, ipAudit("allocate", models.IP{}, ip)); err != nil {
+			return ip, err
+		}
+		return ip, tx.Commit(ctx)
+	}
+	return ip, errConflict("ip_in_use", "Could not allocate an address; please retry")
+}
+
+// pickFreeAddr chooses an address between first and last (inclusive) that is
+// not in used according to strategy. ok is false when there is none.
+func pickFreeAddr(first, last netip.Addr, used []netip.Addr, strategy string) (addr netip.Addr, ok bool, err error) {
+	switch strategy {
+	case strategyHighest:
+		addr, ok = ipcalc.LastFree(first, last, used)
+		return addr, ok, nil
+	case strategyRandom:
+		// Start at a uniformly random address and take the first free address from
+		// there, wrapping around to first.
+		offset, err := rand.Int(rand.Reader, ipcalc.RangeSize(first, last))
+		if err != nil {
+			return addr, false, err
+		}
+		start, _ := ipcalc.AddBig(first, offset)
+		if free := ipcalc.FreeAddrs(start, last, used, 0, 1); len(free) > 0 {
+			return free[0], true, nil
+		}
+	}
+	if free := ipcalc.FreeAddrs(first, last, used, 0, 1); len(free) > 0 {
+		return free[0], true, nil
+	}
+	return addr, false, nil
+}
+
+// ipPatch lists the IP fields that may be changed; nil fields are left as is.
+type ipPatch struct {
+	Hostname     *string        `json:"hostname"`
+	MACAddress   *string        `json:"mac_address"`   // "" removes the MAC
+	TenantID     nullableUUID   `json:"tenant_id"`     // null leaves the address to the subnet's tenant
+	InterfaceID  nullableUUID   `json:"interface_id"`  // null unassigns the address from its interface
+	ExpiresAt    nullableTime   `json:"expires_at"`    // null makes the address permanent
+	CustomFields map[string]any `json:"custom_fields"` // merged into the current values; null removes a value
+}
+
+// updateIP changes the details of an allocated or reserved address. The
+// patch is applied to the address as locked in the transaction, so that
+// concurrent changes to other fields are kept.
+func updateIP(ctx context.Context, subnetID, address string, patch ipPatch) (models.IP, error) {
+	ip, err := getIP(ctx, subnetID, address)
+	if err != nil {
+		return ip, err
+	}
+	if ip.Status == "available" {
+		return ip, errConflict("ip_not_in_use", "IP address is not allocated or reserved")
+	}
+	if patch.Hostname == nil && patch.MACAddress == nil && !patch.TenantID.Set && !patch.InterfaceID.Set && !patch.ExpiresAt.Set && patch.CustomFields == nil {
+		return ip, nil
+	}
+	var mac *string
+	if patch.MACAddress != nil {
+		if mac, err = normalizeMAC(*patch.MACAddress); err != nil {
+			return ip, err
+		}
+	}
+	if patch.TenantID.Set {
+		if err := checkTenantExists(ctx, patch.TenantID.ID); err != nil {
+			return ip, err
+		}
+	}
+	if patch.InterfaceID.Set {
+		if err := checkInterfaceExists(ctx, patch.InterfaceID.ID); err != nil {
+			return ip, err
+		}
+	}
+	if patch.ExpiresAt.Set {
+		if err := validateExpiry(patch.ExpiresAt.Time); err != nil {
+			return ip, err
+		}
+	}
+	// An address moved to another device stops being the primary IP of the old one.
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.IP
+		if err := scanIP(tx.QueryRow(ctx, "SELECT "+ipColumns+" FROM ips WHERE id = $1 FOR UPDATE", ip.ID), &before); err != nil {
+			return err
+		}
+		ip = before
+		if patch.Hostname != nil {
+			ip.Hostname = patch.Hostname
+		}
+		hostnameArg, err := validateHostname(hostnameOf(ip))
+		if err != nil {
+			return err
+		}
+		if patch.MACAddress != nil {
+			ip.MACAddress = mac
+		}
+		if patch.TenantID.Set {
+			ip.TenantID = patch.TenantID.ID
+		}
+		if patch.InterfaceID.Set {
+			ip.InterfaceID = patch.InterfaceID.ID
+		}
+		if patch.ExpiresAt.Set {
+			ip.ExpiresAt = patch.ExpiresAt.Time
+		}
+		if ip.CustomFields, err = applyCustomFields(ctx, models.CustomFieldObjectIP, ip.CustomFields, patch.CustomFields); err != nil {
+			return err
+		}
+		if err := scanIP(tx.QueryRow(ctx,
+			`UPDATE ips SET hostname = $2, tenant_id = $3, custom_fields = $4, interface_id = $5, mac_address = $6, expires_at = $7
+			 WHERE id = $1 RETURNING `+ipColumns,
+			ip.ID, hostnameArg, ip.TenantID, ip.CustomFields, ip.InterfaceID, ip.MACAddress, ip.ExpiresAt), &ip); err != nil {
+			return err
+		}
+		if err := clearStalePrimaryIP(ctx, tx, ip.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, ipAudit("update", before, ip))
+	})
+	switch {
+	case isNoRows(err):
+		return ip, errIPChanged()
+	case database.ErrorCode(err) == database.ForeignKeyViolation:
+		return ip, errInvalid("interface_not_found", "Interface not found")
+	}
+	return ip, err
+}
+
+// releaseIP returns an allocated address to the available pool. The row is
+// deleted because available addresses are not stored. Reserved addresses are
+// normally freed with unreserveIP; releasing one requires force.
+func releaseIP(ctx context.Context, subnetID, address string, force bool) (models.IP, error) {
+	ip, err := getIP(ctx, subnetID, address)
+	if err != nil {
+		return ip, err
+	}
+	switch {
+	case ip.Status == "available":
+		return ip, errConflict("ip_not_in_use", "IP address is already available")
+	case ip.Status == "reserved" && !force:
+		return ip, errConflict("ip_reserved", "%s is reserved; unreserve it or release with force", ip.Address)
+	}
+	return deleteIP(ctx, ip, "release")
+}
+
+// reserveIP marks an address as reserved. hostname, if not nil, replaces the
+// stored hostname; expiresAt, if not nil, ends the reservation at that time.
+// Reserving an allocated address takes it away from its holder and therefore
+// requires force.
+func reserveIP(ctx context.Context, subnetID, address string, hostname *string, expiresAt *time.Time, force bool) (models.IP, error) {
+	ip, err := getIP(ctx, subnetID, address)
+	if err != nil {
+		return ip, err
+	}
+	if err := validateExpiry(expiresAt); err != nil {
+		return ip, err
+	}
+	var hostnameArg any
+	if hostname != nil {
+		if hostnameArg, err = validateHostname(*hostname); err != nil {
+			return ip, err
+		}
+	}
+
+	var query string
+	var args []any
+	switch ip.Status {
+	case "available":
+		query = `INSERT INTO ips (subnet_id, address, status, hostname, expires_at) VALUES ($1, $2, 'reserved', $3, $4)
+			 ON CONFLICT (subnet_id, address) DO UPDATE SET status = 'reserved', hostname = EXCLUDED.hostname,
+			   expires_at = EXCLUDED.expires_at
+			 WHERE ips.status = 'available'
+			 RETURNING ` + ipColumns
+		args = []any{ip.SubnetID, ip.Address, hostnameArg, expiresAt}
+	case "allocated":
+		if !force {
+			return ip, errConflict("ip_allocated", "%s is allocated; reserve it with force to take it over", ip.Address)
+		}
+		// A nil hostname keeps the current one. The expiry of the allocation
+		// does not carry over to the reservation.
+		query = `UPDATE ips SET status = 'reserved', hostname = CASE WHEN $3 THEN $2 ELSE hostname END, expires_at = $4
+			 WHERE id = $1 AND status = 'allocated'
+			 RETURNING ` + ipColumns
+		args = []any{ip.ID, hostnameArg, hostname != nil, expiresAt}
+	default:
+		return ip, errConflict("ip_already_reserved", "%s is already reserved", ip.Address)
+	}
+	before := ip
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanIP(tx.QueryRow(ctx, query, args...), &ip); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, ipAudit("reserve", before, ip))
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ip, errIPChanged()
+	}
+	return ip, err
+}
+
+// unreserveIP returns a reserved address to the available pool.
+func unreserveIP(ctx context.Context, subnetID, address string) (models.IP, error) {
+	ip, err := getIP(ctx, subnetID, address)
+	if err != nil {
+		return ip, err
+	}
+	if ip.Status != "reserved" {
+		return ip, errConflict("ip_not_reserved", "%s is not reserved", ip.Address)
+	}
+	return deleteIP(ctx, ip, "unreserve")
+}
+
+// deleteIP removes the stored row of ip, provided its status has not changed
+// since it was read, records it as action in the audit log and returns the
+// address as available. Freeing the gateway of the subnet clears it.
+func deleteIP(ctx context.Context, ip models.IP, action string) (models.IP, error) {
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := recordPrimaryIPChanges(ctx, tx, []pgtype.UUID{ip.ID}, func() error {
+			result, err := tx.Exec(ctx, "DELETE FROM ips WHERE id = $1 AND status = $2", ip.ID, ip.Status)
+			if err != nil {
+				return err
+			}
+			if result.RowsAffected() == 0 {
+				return errIPChanged()
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, ipAudit(action, ip, models.IP{})); err != nil {
+			return err
+		}
+		return dropGateways(ctx, tx, []models.IP{ip})
+	})
+	if err != nil {
+		return ip, err
+	}
+	return models.IP{SubnetID: ip.SubnetID, Address: ip.Address, Status: "available"}, nil
+}
+
+// errIPChanged reports that another request changed an address between reading and writing it.
+func errIPChanged() error {
+	return errConflict("ip_changed", "IP address was changed by another request; please retry")
+}
+
+// usedAddrs returns the allocated and reserved addresses of a subnet in ascending order.
+func usedAddrs(ctx context.Context, q database.Querier, subnetID pgtype.UUID) ([]netip.Addr, error) {
+	rows, err := q.Query(ctx,
+		"SELECT address FROM ips WHERE subnet_id = $1 AND status <> 'available' ORDER BY address", subnetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addrs []netip.Addr
+	for rows.Next() {
+		var a netip.Addr
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, a)
+	}
+	return addrs, rows.Err()
+}
+
+// ipsForAddrs returns one IP per address, using the stored row when there is one
+// and an available placeholder otherwise.
+func ipsForAddrs(ctx context.Context, subnet models.Subnet, addrs []netip.Addr) ([]models.IP, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	stored, err := queryIPs(ctx,
+		"SELECT "+ipColumns+" FROM ips WHERE subnet_id = $1 AND address = ANY($2)",
+		subnet.ID, addrs)
+	if err != nil {
+		return nil, err
+	}
+	byAddr := make(map[string]models.IP, len(stored))
+	for _, ip := range stored {
+		byAddr[ip.Address] = ip
+	}
+
+	ips := make([]models.IP, len(addrs))
+	for i, a := range addrs {
+		if ip, ok := byAddr[a.String()]; ok {
+			ips[i] = ip
+			continue
+		}
+		ips[i] = models.IP{SubnetID: subnet.ID, Address: a.String(), Status: "available"}
+	}
+	return ips, nil
+}
+
+// scanIP scans a row selected with ipColumns.
+func scanIP(row interface{ Scan(...any) error }, ip *models.IP) error {
+	if err := row.Scan(&ip.ID, &ip.SubnetID, database.Addr(&ip.Address), &ip.Status, &ip.Hostname,
+		&ip.MACAddress, &ip.MACConflicts, &ip.TenantID, &ip.TenantName,
+		&ip.InterfaceID, &ip.InterfaceName, &ip.DeviceID, &ip.DeviceName, &ip.Tags, &ip.CustomFields, &ip.ReservationReason, &ip.ExpiresAt, &ip.CreatedAt); err != nil {
+		return err
+	}
+	ip.MACVendor = macVendor(ip.MACAddress)
+	return nil
+}
+
+// queryIPs runs a query selecting ipColumns and scans the result.
+func queryIPs(ctx context.Context, sql string, args ...any) ([]models.IP, error) {
+	return queryIPsOn(ctx, database.DB, sql, args...)
+}
+
+// queryIPsOn is queryIPs on q, such as a transaction.
+func queryIPsOn(ctx context.Context, q database.Querier, sql string, args ...any) ([]models.IP, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ips []models.IP
+	for rows.Next() {
+		var ip models.IP
+		if err := scanIP(rows, &ip); err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
+
+// ipSnapshots locks the addresses ids on q and returns them keyed by ID, for
+// recordChanges.
+func ipSnapshots(ctx context.Context, q database.Querier, ids []pgtype.UUID) (map[pgtype.UUID]models.IP, error) {
+	ips, err := queryIPsOn(ctx, q, "SELECT "+ipColumns+" FROM ips WHERE id = ANY($1) FOR UPDATE", ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[pgtype.UUID]models.IP, len(ips))
+	for _, ip := range ips {
+		byID[ip.ID] = ip
+	}
+	return byID, nil
+}
+
+// clampInt converts n to an int, saturating at math.MaxInt.
+// IPv6 subnets routinely hold more addresses than an int can count.
+func clampInt(n *big.Int) int {
+	if !n.IsInt64() || n.Int64() > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(n.Int64())
 }
@@ -100,7 +100,14 @@ func TestHandleAllocateIP_NotAvailable(t *testing.T) {
 		t.Fatalf("failed to insert subnet: %v", err)
 	}
 
-	// Try to allocate a non-existent address
+	// Mark the address as already allocated
+	if _, err := database.DB.Exec(context.Background(),
+		"INSERT INTO ips (subnet_id, address, status, hostname) VALUES ($1, $2, 'allocated', 'taken')",
+		subnetID, "10.0.16.99",
+	); err != nil {
+		t.Fatalf("failed to insert IP: %v", err)
+	}
+
 	form := url.Values{
 		"address":  {"10.0.16.99"},
 		"hostname": {"myhost"},
@@ -167,3 +174,448 @@ func TestHandleAllocateIP_Success(t *testing.T) {
 		t.Errorf("expected hostname 'myhost', got %v", hostname)
 	}
 }
+
+func TestHandleAllocateIP_OutsideSubnet(t *testing.T) {
+	cleanDB(t)
+
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"10.0.16.0/24", "alloc-test",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+
+	// Network address, broadcast address and an address in another subnet
+	for _, address := range []string{"10.0.16.0", "10.0.16.255", "10.0.17.1", "not-an-ip"} {
+		t.Run(address, func(t *testing.T) {
+			form := url.Values{"address": {address}, "hostname": {"myhost"}}
+			req := httptest.NewRequest(http.MethodPost, "/subnets/"+subnetID+"/ips", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetPathValue("id", subnetID)
+			w := httptest.NewRecorder()
+
+			HandleAllocateIP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleAllocateIP_WithoutStoredRow(t *testing.T) {
+	cleanDB(t)
+
+	// A /8 has no materialized rows; any host address can be allocated directly.
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"10.0.0.0/8", "large",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+
+	form := url.Values{"address": {"10.200.3.4"}, "hostname": {"myhost"}}
+	req := httptest.NewRequest(http.MethodPost, "/subnets/"+subnetID+"/ips", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", subnetID)
+	w := httptest.NewRecorder()
+
+	HandleAllocateIP(w, req)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", w.Code)
+	}
+
+	var status string
+	if err := database.DB.QueryRow(context.Background(),
+		"SELECT status FROM ips WHERE subnet_id = $1 AND address = $2",
+		subnetID, "10.200.3.4",
+	).Scan(&status); err != nil {
+		t.Fatalf("failed to query IP: %v", err)
+	}
+	if status != "allocated" {
+		t.Errorf("expected status 'allocated', got %q", status)
+	}
+}
+
+func TestHandleSubnetDetail_Pagination(t *testing.T) {
+	cleanDB(t)
+
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"10.0.0.0/8", "large",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+	for _, addr := range []string{"10.0.0.1", "10.0.0.32"} {
+		if _, err := database.DB.Exec(context.Background(),
+			"INSERT INTO ips (subnet_id, address, status, hostname) VALUES ($1, $2, 'allocated', 'host')",
+			subnetID, addr,
+		); err != nil {
+			t.Fatalf("failed to insert IP: %v", err)
+		}
+	}
+
+	// cell matches an address rendered in the IP table (not in the allocate dropdown).
+	cell := func(addr string) string { return `text-primary">` + addr + `</td>` }
+
+	tests := []struct {
+		name     string
+		query    string
+		contains []string
+		excludes []string
+	}{
+		{"first page", "?page=1", []string{cell("10.0.0.1"), cell("10.0.0.30"), "Total: 16777214 addresses"}, []string{cell("10.0.0.31")}},
+		{"second page", "?page=2", []string{cell("10.0.0.31"), cell("10.0.0.60")}, []string{cell("10.0.0.30")}},
+		{"available skips used", "?page=2&status=available", []string{cell("10.0.0.33"), cell("10.0.0.62"), "Total: 16777212 addresses"}, []string{cell("10.0.0.31"), cell("10.0.0.32")}},
+		{"allocated", "?status=allocated", []string{cell("10.0.0.1"), cell("10.0.0.32"), "Total: 2 addresses"}, []string{cell("10.0.0.2")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subnets/"+subnetID+tt.query, nil)
+			req.SetPathValue("id", subnetID)
+			w := httptest.NewRecorder()
+
+			HandleSubnetDetail(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			body := w.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected body to contain %q", s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("expected body not to contain %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleAllocateIP_IPv6(t *testing.T) {
+	cleanDB(t)
+
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"2001:db8::/64", "v6",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+
+	tests := []struct {
+		address string
+		want    int
+	}{
+		{"2001:DB8:0:0:0:0:0:AB", http.StatusSeeOther}, // stored as 2001:db8::ab
+		{"2001:db8::ab", http.StatusConflict},          // same address, canonical spelling
+		{"2001:db8::", http.StatusBadRequest},          // Subnet-Router anycast
+		{"2001:db8:0:1::1", http.StatusBadRequest},     // outside the /64
+		{"10.0.0.1", http.StatusBadRequest},            // wrong address family
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			form := url.Values{"address": {tt.address}, "hostname": {"v6host"}}
+			req := httptest.NewRequest(http.MethodPost, "/subnets/"+subnetID+"/ips", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetPathValue("id", subnetID)
+			w := httptest.NewRecorder()
+
+			HandleAllocateIP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	var count int
+	if err := database.DB.QueryRow(context.Background(),
+		"SELECT COUNT(*) FROM ips WHERE subnet_id = $1 AND address = '2001:db8::ab'", subnetID,
+	).Scan(&count); err != nil {
+		t.Fatalf("failed to query IP: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected address stored in canonical form, found %d rows", count)
+	}
+}
+
+func TestHandleSubnetDetail_IPv6(t *testing.T) {
+	cleanDB(t)
+
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"2001:db8::/64", "v6",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+
+	cell := func(addr string) string { return `text-primary">` + addr + `</td>` }
+
+	tests := []struct {
+		name     string
+		query    string
+		contains []string
+	}{
+		{"first page", "", []string{cell("2001:db8::1"), cell("2001:db8::1e"), "Total: 18446744073709551615 addresses"}},
+		{"goto", "?goto=2001:db8::ffff:ffff:ffff:ffff", []string{cell("2001:db8::ffff:ffff:ffff:ffff")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subnets/"+subnetID+tt.query, nil)
+			req.SetPathValue("id", subnetID)
+			w := httptest.NewRecorder()
+
+			HandleSubnetDetail(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("expected body to contain %q", s)
+				}
+			}
+		})
+	}
+}
+
+func TestIPOutsideSubnetRejectedByDatabase(t *testing.T) {
+	cleanDB(t)
+
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"10.0.16.0/24", "check-test",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+
+	_, err := database.DB.Exec(context.Background(),
+		"INSERT INTO ips (subnet_id, address, status) VALUES ($1, $2, 'allocated')",
+		subnetID, "10.0.17.1")
+	if code := database.ErrorCode(err); code != database.CheckViolation {
+		t.Errorf("expected check violation, got %v", err)
+	}
+}
+
+func TestHandleAllocateNextIP_Strategies(t *testing.T) {
+	cleanDB(t)
+
+	// /29 hosts are .1 to .6; the lowest and highest ones are taken up front.
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"10.0.17.0/29", "next-test",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+	if _, err := database.DB.Exec(context.Background(),
+		"INSERT INTO ips (subnet_id, address, status) VALUES ($1, '10.0.17.1', 'allocated'), ($1, '10.0.17.6', 'reserved')",
+		subnetID,
+	); err != nil {
+		t.Fatalf("failed to insert IPs: %v", err)
+	}
+
+	allocate := func(strategy string) *httptest.ResponseRecorder {
+		form := url.Values{"hostname": {"next-host"}, "strategy": {strategy}}
+		req := httptest.NewRequest(http.MethodPost, "/subnets/"+subnetID+"/ips/next", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", subnetID)
+		w := httptest.NewRecorder()
+		HandleAllocateNextIP(w, req)
+		return w
+	}
+
+	for _, tt := range []struct{ strategy, want string }{
+		{"", "10.0.17.2"},
+		{"highest", "10.0.17.5"},
+		{"lowest", "10.0.17.3"},
+	} {
+		w := allocate(tt.strategy)
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("strategy %q: expected 303, got %d; body: %s", tt.strategy, w.Code, w.Body.String())
+		}
+		if loc := w.Header().Get("Location"); !strings.HasSuffix(loc, "goto="+tt.want) {
+			t.Errorf("strategy %q: expected redirect to %s, got %q", tt.strategy, tt.want, loc)
+		}
+	}
+
+	// Only .4 is left, so random must pick it.
+	if w := allocate("random"); !strings.HasSuffix(w.Header().Get("Location"), "goto=10.0.17.4") {
+		t.Errorf("random: expected 10.0.17.4, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := allocate("lowest"); w.Code != http.StatusConflict {
+		t.Errorf("full subnet: expected 409, got %d", w.Code)
+	}
+	if w := allocate("middle"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid strategy: expected 400, got %d", w.Code)
+	}
+
+	var hostname string
+	if err := database.DB.QueryRow(context.Background(),
+		"SELECT hostname FROM ips WHERE subnet_id = $1 AND address = '10.0.17.2'", subnetID,
+	).Scan(&hostname); err != nil || hostname != "next-host" {
+		t.Errorf("expected hostname next-host on 10.0.17.2, got %q (%v)", hostname, err)
+	}
+}
+
+func TestIPRowActions(t *testing.T) {
+	cleanDB(t)
+
+	var subnetID string
+	if err := database.DB.QueryRow(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
+		"10.0.18.0/24", "row-test",
+	).Scan(&subnetID); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+	if _, err := database.DB.Exec(context.Background(),
+		"INSERT INTO ips (subnet_id, address, status, hostname) VALUES ($1, '10.0.18.5', 'allocated', 'web-01')",
+		subnetID,
+	); err != nil {
+		t.Fatalf("failed to insert IP: %v", err)
+	}
+
+	// act calls a row handler for 10.0.18.5 and returns the rendered row.
+	act := func(h http.HandlerFunc, method string, form url.Values) string {
+		t.Helper()
+		req := httptest.NewRequest(method, "/subnets/"+subnetID+"/ips/10.0.18.5", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", subnetID)
+		req.SetPathValue("address", "10.0.18.5")
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d; body: %s", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+	status := func() string {
+		var s string
+		err := database.DB.QueryRow(context.Background(),
+			"SELECT status FROM ips WHERE subnet_id = $1 AND address = '10.0.18.5'", subnetID).Scan(&s)
+		if err != nil {
+			return "available"
+		}
+		return s
+	}
+
+	steps := []struct {
+		name       string
+		h          http.HandlerFunc
+		method     string
+		form       url.Values
+		wantStatus string
+		wantInBody string
+	}{
+		{"edit hostname", HandleUpdateIP, http.MethodPatch, url.Values{"hostname": {"web-02"}}, "allocated", "web-02"},
+		{"invalid hostname", HandleUpdateIP, http.MethodPatch, url.Values{"hostname": {"-bad"}}, "allocated", "Invalid hostname format"},
+		{"reserve allocated without force", HandleReserveIP, http.MethodPost, nil, "allocated", "reserve it with force"},
+		{"reserve allocated with force", HandleReserveIP, http.MethodPost, url.Values{"force": {"true"}}, "reserved", "web-02"},
+		{"release reserved without force", HandleReleaseIP, http.MethodPost, nil, "reserved", "unreserve it"},
+		{"unreserve", HandleUnreserveIP, http.MethodPost, nil, "available", `data-status="available"`},
+		{"unreserve available", HandleUnreserveIP, http.MethodPost, nil, "available", "is not reserved"},
+		{"reserve available", HandleReserveIP, http.MethodPost, nil, "reserved", `data-status="reserved"`},
+		{"release reserved with force", HandleReleaseIP, http.MethodPost, url.Values{"force": {"true"}}, "available", `data-status="available"`},
+		{"release available", HandleReleaseIP, http.MethodPost, nil, "available", "already available"},
+	}
+	for _, step := range steps {
+		body := act(step.h, step.method, step.form)
+		if got := status(); got != step.wantStatus {
+			t.Errorf("%s: expected status %q, got %q", step.name, step.wantStatus, got)
+		}
+		if !strings.Contains(body, step.wantInBody) {
+			t.Errorf("%s: expected row to contain %q, got:\n%s", step.name, step.wantInBody, body)
+		}
+	}
+}
+
+func TestHandleSubnetMap(t *testing.T) {
+	cleanDB(t)
+
+	subnets := make(map[string]string)
+	for _, cidr := range []string{"10.0.0.0/24", "10.1.0.0/16"} {
+		var id string
+		if err := database.DB.QueryRow(context.Background(),
+			"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id", cidr, cidr,
+		).Scan(&id); err != nil {
+			t.Fatalf("failed to insert subnet: %v", err)
+		}
+		subnets[cidr] = id
+	}
+	for _, row := range [][3]string{
+		{"10.0.0.0/24", "10.0.0.1", "allocated"},
+		{"10.0.0.0/24", "10.0.0.2", "reserved"},
+		{"10.1.0.0/16", "10.1.5.7", "allocated"},
+	} {
+		if _, err := database.DB.Exec(context.Background(),
+			"INSERT INTO ips (subnet_id, address, status, hostname) VALUES ($1, $2, $3, 'host-a')",
+			subnets[row[0]], row[1], row[2],
+		); err != nil {
+			t.Fatalf("failed to insert IP: %v", err)
+		}
+	}
+
+	// cell matches a cell of the map by its address and status.
+	cell := func(addr, status string) string {
+		return `data-address="` + addr + `" data-status="` + status + `"`
+	}
+
+	tests := []struct {
+		name     string
+		subnet   string
+		query    string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{"addresses", "10.0.0.0/24", "", http.StatusOK, []string{
+			cell("10.0.0.0", "unusable"), cell("10.0.0.1", "allocated"), cell("10.0.0.2", "reserved"),
+			cell("10.0.0.3", "available"), cell("10.0.0.255", "unusable"), "10.0.0.1 — allocated — host-a",
+			`$refs.address.value = &#39;10.0.0.3&#39;`,
+		}, []string{"data-block="}},
+		{"blocks", "10.1.0.0/16", "", http.StatusOK, []string{
+			`data-block="10.1.0.0/24"`, `data-block="10.1.255.0/24"`,
+			"10.1.5.0/24 — 1 allocated, 0 reserved, 255 available", "10.1.0.0/24 — 0 allocated, 0 reserved, 255 available",
+		}, []string{"data-address="}},
+		{"zoomed in", "10.1.0.0/16", "?prefix=10.1.5.0/24", http.StatusOK, []string{
+			cell("10.1.5.0", "available"), cell("10.1.5.7", "allocated"), `name="prefix" value="10.1.5.0/24"`, "Zoom out",
+		}, []string{"data-block="}},
+		{"zoom outside", "10.1.0.0/16", "?prefix=10.2.0.0/24", http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := subnets[tt.subnet]
+			req := httptest.NewRequest(http.MethodGet, "/subnets/"+id+"/map"+tt.query, nil)
+			req.SetPathValue("id", id)
+			w := httptest.NewRecorder()
+
+			HandleSubnetMap(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d; body: %s", tt.status, w.Code, w.Body.String())
+			}
+			body := w.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("expected body to contain %q", s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(body, s) {
+					t.Errorf("expected body not to contain %q", s)
+				}
+			}
+		})
+	}
+}
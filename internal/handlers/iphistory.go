@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// ipHistoryLimit caps the entries shown in the timeline of an address.
+const ipHistoryLimit = 100
+
+// HandleIPHistory renders the timeline of an address, newest change first,
+// into the timeline modal of the IP table.
+func HandleIPHistory(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getSubnet(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnet")
+		return
+	}
+	address, err := historyAddr(r.PathValue("address"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch history")
+		return
+	}
+	f := auditFilter{ObjectType: models.AuditObjectIP, ObjectID: address, SubnetID: subnet.ID.String()}
+	entries, total, err := listAudit(r.Context(), f, 1, ipHistoryLimit)
+	if err != nil {
+		writeError(w, err, "Failed to fetch history")
+		return
+	}
+	templates.IPTimeline(subnet, address, entries, total).Render(r.Context(), w)
+}
+
+// historyAddr parses the address of a history query into the form it is
+// recorded in.
+func historyAddr(address string) (string, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return "", errInvalid("invalid_address", "Invalid IP address")
+	}
+	return addr.Unmap().String(), nil
+}
+
+// historySubnetID parses the subnet of a history query. A deleted subnet is
+// still known by the entries it left in the audit log.
+func historySubnetID(ctx context.Context, s string) (pgtype.UUID, error) {
+	id, err := parseRefID(s, "invalid_subnet", "Invalid subnet ID")
+	if err != nil {
+		return id, err
+	}
+	var known bool
+	if err := database.DB.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM subnets WHERE id = $1)
+		     OR EXISTS (SELECT 1 FROM audit_log WHERE subnet_id = $1)`, id).Scan(&known); err != nil {
+		return id, err
+	}
+	if !known {
+		return id, errNotFound("subnet_not_found", "Subnet not found")
+	}
+	return id, nil
+}
+
+// ipsAt reconstructs the allocated and reserved addresses of a subnet at t,
+// in address order, from the latest snapshot of each address recorded at or
+// before t.
+func ipsAt(ctx context.Context, subnetID pgtype.UUID, t time.Time) ([]models.IP, error) {
+	rows, err := database.DB.Query(ctx,
+		`SELECT after FROM (
+		   SELECT DISTINCT ON (object_id) object_id, after FROM audit_log
+		   WHERE subnet_id = $1 AND object_type = 'ip' AND occurred_at <= $2
+		   ORDER BY object_id, id DESC
+		 ) latest
+		 WHERE after IS NOT NULL
+		 ORDER BY object_id::inet`, subnetID, t)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.IP, error) {
+		var snapshot []byte
+		var ip models.IP
+		if err := row.Scan(&snapshot); err != nil {
+			return ip, err
+		}
+		return ip, json.Unmarshal(snapshot, &ip)
+	})
+}
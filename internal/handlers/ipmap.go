@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/netip"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// mapBits is the number of address bits an address map spreads over its
+// cells: a prefix of up to 2^mapBits addresses (an IPv4 /24) gets one cell
+// per address, a larger one 2^mapBits blocks.
+const mapBits = 8
+
+// HandleSubnetMap renders a network as a map of its addresses, which shows at
+// a glance how fragmented it is. Subnets too large for one cell per address
+// are drawn as blocks; ?prefix= zooms into one of them.
+func HandleSubnetMap(w http.ResponseWriter, r *http.Request) {
+	subnet, err := getNetwork(r.Context(), r.PathValue("id"))
+	if err == nil {
+		subnet, err = subnetWithStats(r.Context(), subnet)
+	}
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnet")
+		return
+	}
+	ancestors, err := ancestorSubnets(r.Context(), subnet)
+	if err != nil {
+		writeError(w, err, "Failed to fetch parent subnets")
+		return
+	}
+	m, err := addressMap(r.Context(), subnet, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch IPs")
+		return
+	}
+	ranges, err := listRanges(r.Context(), subnet.ID)
+	if err != nil {
+		writeError(w, err, "Failed to fetch ranges")
+		return
+	}
+	for _, c := range m.Cells {
+		if c.IP != nil {
+			c.IP.Range = rangeOf(ranges, c.IP.Address)
+		}
+	}
+
+	// Clicking a free cell opens the same allocate form as the table.
+	availableIPs, err := availableChoices(r.Context(), subnet)
+	if err != nil {
+		writeError(w, err, "Failed to fetch available IPs")
+		return
+	}
+	tenants, err := listTenants(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tenants")
+		return
+	}
+	interfaces, err := listInterfaces(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch interfaces")
+		return
+	}
+	fields, err := listCustomFields(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch custom fields")
+		return
+	}
+	templates.SubnetMap(subnet, ancestors, m, availableIPs, ranges, tenants, interfaces, fields).Render(r.Context(), w)
+}
+
+// addressMap draws subnet, or the block zoom inside it, as a grid. A prefix
+// of up to 2^mapBits addresses gets a cell per address, including those that
+// cannot be assigned so that the rows line up; a larger one is split into
+// 2^mapBits equal blocks with the counts of their addresses.
+func addressMap(ctx context.Context, subnet models.Subnet, zoom string) (models.AddressMap, error) {
+	prefix, err := netip.ParsePrefix(subnet.CIDR)
+	if err != nil {
+		return models.AddressMap{}, err
+	}
+	view := prefix
+	if zoom != "" {
+		p, err := netip.ParsePrefix(zoom)
+		if err != nil || p.Bits() < prefix.Bits() || !prefix.Contains(p.Addr()) {
+			return models.AddressMap{}, errInvalid("invalid_prefix", "%s is not a prefix inside %s", zoom, subnet.CIDR)
+		}
+		view = p.Masked()
+	}
+	m := models.AddressMap{Prefix: view.String()}
+	if view != prefix {
+		parent := netip.PrefixFrom(view.Addr(), max(view.Bits()-mapBits, prefix.Bits())).Masked()
+		m.Parent = parent.String()
+	}
+
+	hostBits := view.Addr().BitLen() - view.Bits()
+	if hostBits <= mapBits {
+		m.Cells, err = addressCells(ctx, subnet, prefix, view)
+		return m, err
+	}
+	m.Blocks = true
+	m.Cells, err = blockCells(ctx, subnet, prefix, view)
+	return m, err
+}
+
+// addressCells returns a cell for every address of view, a prefix inside the
+// subnet.
+func addressCells(ctx context.Context, subnet models.Subnet, prefix, view netip.Prefix) ([]models.MapCell, error) {
+	first, last := ipcalc.HostRange(prefix)
+	var all, usable []netip.Addr
+	a := view.Addr()
+	for range 1 << (view.Addr().BitLen() - view.Bits()) {
+		all = append(all, a)
+		if !a.Less(first) && !last.Less(a) {
+			usable = append(usable, a)
+		}
+		a = a.Next()
+	}
+	ips, err := ipsForAddrs(ctx, subnet, usable)
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]models.MapCell, len(all))
+	for i, a := range all {
+		if len(ips) > 0 && ips[0].Address == a.String() {
+			cells[i].IP = &ips[0]
+			ips = ips[1:]
+			continue
+		}
+		cells[i].IP = &models.IP{SubnetID: subnet.ID, Address: a.String(), Status: models.IPStatusUnusable}
+	}
+	return cells, nil
+}
+
+// blockCells splits view, a prefix inside the subnet, into 2^mapBits blocks
+// and counts the allocated and reserved addresses of each.
+func blockCells(ctx context.Context, subnet models.Subnet, prefix, view netip.Prefix) ([]models.MapCell, error) {
+	bits := view.Bits() + mapBits
+	rows, err := database.DB.Query(ctx,
+		`SELECT network(set_masklen(address, $3)),
+		   COUNT(*) FILTER (WHERE status = 'allocated'),
+		   COUNT(*) FILTER (WHERE status = 'reserved')
+		 FROM ips WHERE subnet_id = $1 AND address << $2
+		 GROUP BY 1`,
+		subnet.ID, view, bits)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[netip.Prefix]*models.SubnetStats)
+	for rows.Next() {
+		var block netip.Prefix
+		st := new(models.SubnetStats)
+		if err := rows.Scan(&block, &st.Allocated, &st.Reserved); err != nil {
+			return nil, err
+		}
+		counts[block] = st
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	t := utilizationThresholds()
+	step := new(big.Int).Lsh(big.NewInt(1), uint(view.Addr().BitLen()-bits))
+	offset := new(big.Int)
+	cells := make([]models.MapCell, 1<<mapBits)
+	for i := range cells {
+		a, _ := ipcalc.AddBig(view.Addr(), offset)
+		offset.Add(offset, step)
+		block := netip.PrefixFrom(a, bits)
+		st := counts[block]
+		if st == nil {
+			st = new(models.SubnetStats)
+		}
+		st.Total = ipcalc.BlockHostCount(prefix, block)
+		t.finish(st)
+		cells[i] = models.MapCell{Block: block.String(), Stats: st}
+	}
+	return cells, nil
+}
+
+// availableChoices returns the lowest free addresses of a network, offered
+// in the allocate form.
+func availableChoices(ctx context.Context, subnet models.Subnet) ([]models.IP, error) {
+	free, err := freeAddrs(ctx, subnet, maxAllocateChoices)
+	if err != nil {
+		return nil, err
+	}
+	var ips []models.IP
+	for _, a := range free {
+		ips = append(ips, models.IP{SubnetID: subnet.ID, Address: a.String(), Status: "available"})
+	}
+	return ips, nil
+}
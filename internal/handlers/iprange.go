@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// rangeColumns is the column list scanned by scanRange.
+const rangeColumns = `id, subnet_id, start_address, end_address, role, description,
+	(SELECT COUNT(*) FROM ips i WHERE i.subnet_id = ip_ranges.subnet_id
+	   AND i.address BETWEEN ip_ranges.start_address AND ip_ranges.end_address
+	   AND i.status IN ('allocated', 'reserved')),
+	created_at`
+
+// HandleCreateRange adds a range from the subnet detail page.
+func HandleCreateRange(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in := models.IPRange{
+		StartAddress: r.FormValue("start_address"),
+		EndAddress:   r.FormValue("end_address"),
+		Role:         r.FormValue("role"),
+		Description:  r.FormValue("description"),
+	}
+	if _, err := createRange(r.Context(), r.PathValue("id"), in); err != nil {
+		writeError(w, err, "Failed to create range")
+		return
+	}
+
+	HandleSubnetDetail(w, r)
+}
+
+// HandleDeleteRange removes a range of a subnet; its addresses are kept.
+func HandleDeleteRange(w http.ResponseWriter, r *http.Request) {
+	rg, err := getRange(r.Context(), r.PathValue("rangeID"))
+	if err != nil {
+		writeError(w, err, "Failed to delete range")
+		return
+	}
+	if rg.SubnetID.String() != r.PathValue("id") {
+		writeError(w, errNotFound("range_not_found", "Range is not part of this subnet"), "Failed to delete range")
+		return
+	}
+	if err := deleteRange(r.Context(), rg.ID.String()); err != nil {
+		writeError(w, err, "Failed to delete range")
+		return
+	}
+
+	HandleSubnetDetail(w, r)
+}
+
+// scanRange scans a row selected with rangeColumns.
+func scanRange(row interface{ Scan(...any) error }, rg *models.IPRange) error {
+	if err := row.Scan(&rg.ID, &rg.SubnetID, database.Addr(&rg.StartAddress), database.Addr(&rg.EndAddress),
+		&rg.Role, &rg.Description, &rg.Used, &rg.CreatedAt); err != nil {
+		return err
+	}
+	first, err := netip.ParseAddr(rg.StartAddress)
+	if err != nil {
+		return err
+	}
+	last, err := netip.ParseAddr(rg.EndAddress)
+	if err != nil {
+		return err
+	}
+	rg.Size = ipcalc.RangeSize(first, last)
+	return nil
+}
+
+// listRanges returns the ranges of a subnet in address order.
+func listRanges(ctx context.Context, subnetID pgtype.UUID) ([]models.IPRange, error) {
+	return queryRangesOn(ctx, database.DB,
+		"SELECT "+rangeColumns+" FROM ip_ranges WHERE subnet_id = $1 ORDER BY start_address", subnetID)
+}
+
+// queryRangesOn runs a query selecting rangeColumns on q, such as a transaction.
+func queryRangesOn(ctx context.Context, q database.Querier, sql string, args ...any) ([]models.IPRange, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []models.IPRange
+	for rows.Next() {
+		var rg models.IPRange
+		if err := scanRange(rows, &rg); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, rg)
+	}
+	return ranges, rows.Err()
+}
+
+func getRange(ctx context.Context, id string) (models.IPRange, error) {
+	var rg models.IPRange
+	err := scanRange(database.DB.QueryRow(ctx, "SELECT "+rangeColumns+" FROM ip_ranges WHERE id = $1", id), &rg)
+	if isNoRows(err) {
+		return rg, errNotFound("range_not_found", "Range not found")
+	}
+	return rg, err
+}
+
+// createRange adds the range in to network subnetID.
+func createRange(ctx context.Context, subnetID string, in models.IPRange) (models.IPRange, error) {
+	var rg models.IPRange
+	subnet, err := getNetwork(ctx, subnetID)
+	if err != nil {
+		return rg, err
+	}
+	first, last, err := rangeBounds(subnet, in.StartAddress, in.EndAddress)
+	if err != nil {
+		return rg, err
+	}
+	if err := validateRangeRole(in.Role); err != nil {
+		return rg, err
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanRange(tx.QueryRow(ctx,
+			`INSERT INTO ip_ranges (subnet_id, start_address, end_address, role, description)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING `+rangeColumns,
+			subnet.ID, first, last, in.Role, in.Description), &rg); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, rangeAudit("create", nil, &rg))
+	})
+	return rg, rangeWriteError(ctx, err, subnet.ID, first, last, pgtype.UUID{})
+}
+
+// rangePatch lists the range fields that may be changed; nil fields are left as is.
+type rangePatch struct {
+	StartAddress *string `json:"start_address"`
+	EndAddress   *string `json:"end_address"`
+	Role         *string `json:"role"`
+	Description  *string `json:"description"`
+}
+
+func updateRange(ctx context.Context, id string, patch rangePatch) (models.IPRange, error) {
+	rg, err := getRange(ctx, id)
+	if err != nil {
+		return rg, err
+	}
+	subnet, err := getNetwork(ctx, rg.SubnetID.String())
+	if err != nil {
+		return rg, err
+	}
+	if patch.Role != nil {
+		if err := validateRangeRole(*patch.Role); err != nil {
+			return rg, err
+		}
+	}
+	var first, last netip.Addr
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.IPRange
+		if err := scanRange(tx.QueryRow(ctx, "SELECT "+rangeColumns+" FROM ip_ranges WHERE id = $1 FOR UPDATE", rg.ID), &before); err != nil {
+			return err
+		}
+		rg = before
+		if patch.StartAddress != nil {
+			rg.StartAddress = *patch.StartAddress
+		}
+		if patch.EndAddress != nil {
+			rg.EndAddress = *patch.EndAddress
+		}
+		if patch.Role != nil {
+			rg.Role = *patch.Role
+		}
+		if patch.Description != nil {
+			rg.Description = *patch.Description
+		}
+		if first, last, err = rangeBounds(subnet, rg.StartAddress, rg.EndAddress); err != nil {
+			return err
+		}
+		if err := scanRange(tx.QueryRow(ctx,
+			`UPDATE ip_ranges SET start_address = $2, end_address = $3, role = $4, description = $5
+			 WHERE id = $1 RETURNING `+rangeColumns,
+			rg.ID, first, last, rg.Role, rg.Description), &rg); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, rangeAudit("update", &before, &rg))
+	})
+	if isNoRows(err) {
+		return rg, errNotFound("range_not_found", "Range not found")
+	}
+	return rg, rangeWriteError(ctx, err, subnet.ID, first, last, rg.ID)
+}
+
+// deleteRange removes a range. The addresses inside it are kept.
+func deleteRange(ctx context.Context, id string) error {
+	rg, err := getRange(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanRange(tx.QueryRow(ctx, "SELECT "+rangeColumns+" FROM ip_ranges WHERE id = $1 FOR UPDATE", rg.ID), &rg); err != nil {
+			if isNoRows(err) {
+				return errNotFound("range_not_found", "Range not found")
+			}
+			return err
+		}
+		result, err := tx.Exec(ctx, "DELETE FROM ip_ranges WHERE id = $1", rg.ID)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return errNotFound("range_not_found", "Range not found")
+		}
+		return recordAudit(ctx, tx, rangeAudit("delete", &rg, nil))
+	})
+}
+
+// rangeBounds parses the bounds of a range, which must be usable host
+// addresses of subnet in ascending order.
+func rangeBounds(subnet models.Subnet, start, end string) (first, last netip.Addr, err error) {
+	if start == "" || end == "" {
+		return first, last, errInvalid("missing_field", "start_address and end_address are required")
+	}
+	if first, err = subnetAddr(subnet, start); err != nil {
+		return first, last, err
+	}
+	if last, err = subnetAddr(subnet, end); err != nil {
+		return first, last, err
+	}
+	if last.Less(first) {
+		return first, last, errInvalid("invalid_range", "start_address must not be after end_address")
+	}
+	return first, last, nil
+}
+
+// validateRangeRole checks the role of a range.
+func validateRangeRole(role string) error {
+	if !slices.Contains(models.RangeRoles, role) {
+		return errInvalid("invalid_range_role", "role must be one of dhcp, static or reserved")
+	}
+	return nil
+}
+
+// rangeWriteError translates a failed range write: an overlap names the
+// range of the subnet that is in the way.
+func rangeWriteError(ctx context.Context, err error, subnetID pgtype.UUID, first, last netip.Addr, self pgtype.UUID) error {
+	if database.ErrorCode(err) != database.ExclusionViolation {
+		return err
+	}
+	var start, end, role string
+	if database.DB.QueryRow(ctx,
+		`SELECT start_address, end_address, role FROM ip_ranges
+		 WHERE subnet_id = $1 AND inetrange(start_address, end_address, '[]') && inetrange($2, $3, '[]')
+		   AND id IS DISTINCT FROM $4
+		 ORDER BY start_address LIMIT 1`,
+		subnetID, first, last, self).Scan(database.Addr(&start), database.Addr(&end), &role) != nil {
+		return errConflict("range_overlap", "%s-%s overlaps an existing range", first, last)
+	}
+	return errConflict("range_overlap", "%s-%s overlaps %s range %s-%s", first, last, role, start, end)
+}
+
+// parseRangeID parses a range reference from a form; empty means no range.
+func parseRangeID(s string) (pgtype.UUID, error) {
+	return parseRefID(s, "invalid_range", "Invalid range ID")
+}
+
+// setRanges points each of ips at the range containing it, for the range
+// bands of the table.
+func setRanges(ips []models.IP, ranges []models.IPRange) {
+	for i := range ips {
+		ips[i].Range = rangeOf(ranges, ips[i].Address)
+	}
+}
+
+// rangeOf returns the range of ranges containing address, or nil.
+func rangeOf(ranges []models.IPRange, address string) *models.IPRange {
+	a, err := netip.ParseAddr(address)
+	if err != nil {
+		return nil
+	}
+	for i, rg := range ranges {
+		first, ferr := netip.ParseAddr(rg.StartAddress)
+		last, lerr := netip.ParseAddr(rg.EndAddress)
+		if ferr == nil && lerr == nil && !a.Less(first) && !last.Less(a) {
+			return &ranges[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/oui"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// maxMACResults caps the addresses and interfaces returned by a MAC search.
+const maxMACResults = 500
+
+// HandleMACSearch renders the MAC address page: the addresses and interfaces
+// matching ?q= and every MAC recorded on more than one address.
+func HandleMACSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var results macSearchResult
+	if query != "" {
+		var err error
+		if results, err = searchMACs(r.Context(), query); err != nil {
+			writeError(w, err, "Failed to search MAC addresses")
+			return
+		}
+	}
+	duplicates, err := duplicateMACs(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch duplicate MAC addresses")
+		return
+	}
+	ips := results.IPs
+	for _, d := range duplicates {
+		ips = append(ips, d.IPs...)
+	}
+	subnets, err := subnetsOf(r.Context(), ips)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+
+	component := templates.MACSearch(query, results.IPs, results.Interfaces, duplicates, subnets)
+	component.Render(r.Context(), w)
+}
+
+// normalizeMAC parses a MAC address in colon, dash or dotted (Cisco) notation
+// and returns it in lowercase colon form, or nil when s is empty.
+func normalizeMAC(s string) (*string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	hw, err := net.ParseMAC(s)
+	if err != nil || len(hw) != 6 {
+		return nil, errInvalid("invalid_mac", "%q is not a MAC address", s)
+	}
+	mac := hw.String()
+	return &mac, nil
+}
+
+// macVendor returns the vendor of an optional MAC address, or "" when it is
+// nil or its prefix is unknown.
+func macVendor(mac *string) string {
+	if mac == nil {
+		return ""
+	}
+	return oui.Lookup(*mac)
+}
+
+// macSearchDigits reduces a MAC search query in any notation, complete or
+// partial (e.g. "00:50:56", "0050.56aa" or "aa-bb"), to its lowercase hex
+// digits.
+func macSearchDigits(query string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r == ':' || r == '-' || r == '.':
+			return -1
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'f':
+			return r
+		case r >= 'A' && r <= 'F':
+			return r + 'a' - 'A'
+		}
+		return '!'
+	}, query)
+	if digits == "" || len(digits) > 12 || strings.ContainsRune(digits, '!') {
+		return "", errInvalid("invalid_mac_query", "%q is not a MAC address or part of one", query)
+	}
+	return digits, nil
+}
+
+// macSearchResult holds the addresses and interfaces matching a MAC search.
+type macSearchResult struct {
+	IPs        []models.IP        `json:"ips"`
+	Interfaces []models.Interface `json:"interfaces"`
+}
+
+// searchMACs returns the addresses and interfaces whose MAC contains the hex
+// digits of query, whatever the notation of either. A complete MAC matches
+// exactly.
+func searchMACs(ctx context.Context, query string) (macSearchResult, error) {
+	var result macSearchResult
+	digits, err := macSearchDigits(query)
+	if err != nil {
+		return result, err
+	}
+	cond := "replace(mac_address, ':', '') LIKE '%' || $1 || '%'"
+	if len(digits) == 12 {
+		cond = "replace(mac_address, ':', '') = $1"
+	}
+	if result.IPs, err = queryIPs(ctx,
+		"SELECT "+ipColumns+" FROM ips WHERE "+cond+" ORDER BY mac_address, address LIMIT $2",
+		digits, maxMACResults); err != nil {
+		return result, err
+	}
+	rows, err := database.DB.Query(ctx,
+		"SELECT "+interfaceColumns+" FROM interfaces WHERE "+cond+" ORDER BY mac_address LIMIT $2",
+		digits, maxMACResults)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f models.Interface
+		if err := scanInterface(rows, &f); err != nil {
+			return result, err
+		}
+		result.Interfaces = append(result.Interfaces, f)
+	}
+	return result, rows.Err()
+}
+
+// duplicateMACs returns the MAC addresses recorded on more than one address,
+// each with those addresses, ordered by MAC.
+func duplicateMACs(ctx context.Context) ([]models.MACDuplicate, error) {
+	ips, err := queryIPs(ctx,
+		`SELECT `+ipColumns+` FROM ips
+		 WHERE mac_address IN (SELECT mac_address FROM ips GROUP BY mac_address HAVING COUNT(*) > 1)
+		 ORDER BY mac_address, address`)
+	if err != nil {
+		return nil, err
+	}
+	var duplicates []models.MACDuplicate
+	for _, ip := range ips {
+		if n := len(duplicates); n == 0 || duplicates[n-1].MACAddress != *ip.MACAddress {
+			duplicates = append(duplicates, models.MACDuplicate{MACAddress: *ip.MACAddress, MACVendor: ip.MACVendor})
+		}
+		d := &duplicates[len(duplicates)-1]
+		d.IPs = append(d.IPs, ip)
+	}
+	return duplicates, nil
+}
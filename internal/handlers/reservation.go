@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// maxReservedPerEnd caps the first and last terms of a reservation policy, so
+// that a typo cannot store millions of rows for a large subnet.
+const maxReservedPerEnd = 256
+
+// reservationPolicy lists the addresses reserved when a network is created.
+// It is written as comma-separated terms, e.g. "gateway=first,first=3,last=1":
+//
+//	gateway=first|last  reserve the first or last usable address as the gateway
+//	first=N             reserve the first N usable addresses
+//	last=N              reserve the last N usable addresses
+//	none                reserve nothing
+type reservationPolicy struct {
+	Gateway     string // "first", "last" or "" for no gateway
+	First, Last int
+}
+
+// parseReservationPolicy parses a policy; "" and "none" reserve nothing.
+func parseReservationPolicy(s string) (reservationPolicy, error) {
+	var p reservationPolicy
+	for term := range strings.SplitSeq(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" || term == "none" {
+			continue
+		}
+		name, value, _ := strings.Cut(term, "=")
+		switch name = strings.TrimSpace(name); name {
+		case "gateway":
+			value = strings.TrimSpace(value)
+			if value == "" {
+				value = "first"
+			}
+			if value != "first" && value != "last" {
+				return p, errInvalid("invalid_reservation_policy", "gateway must be first or last, not %q", value)
+			}
+			p.Gateway = value
+		case "first", "last":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 || n > maxReservedPerEnd {
+				return p, errInvalid("invalid_reservation_policy", "%s must be a number between 0 and %d", name, maxReservedPerEnd)
+			}
+			if name == "first" {
+				p.First = n
+			} else {
+				p.Last = n
+			}
+		default:
+			return p, errInvalid("invalid_reservation_policy", "unknown reservation policy term %q; use gateway=first|last, first=N, last=N or none", term)
+		}
+	}
+	return p, nil
+}
+
+// String returns the canonical form of p, which is stored with the subnet;
+// it is empty when p reserves nothing.
+func (p reservationPolicy) String() string {
+	var terms []string
+	if p.Gateway != "" {
+		terms = append(terms, "gateway="+p.Gateway)
+	}
+	if p.First > 0 {
+		terms = append(terms, fmt.Sprintf("first=%d", p.First))
+	}
+	if p.Last > 0 {
+		terms = append(terms, fmt.Sprintf("last=%d", p.Last))
+	}
+	return strings.Join(terms, ",")
+}
+
+// reservedAddr is an address reserved by a policy, with the term that
+// reserved it.
+type reservedAddr struct {
+	Addr   netip.Addr
+	Reason string
+}
+
+// plan returns the gateway and the addresses p reserves in prefix, in
+// ascending order. The gateway's reason wins over a first or last term
+// covering the same address.
+func (p reservationPolicy) plan(prefix netip.Prefix) (gateway netip.Addr, reserved []reservedAddr) {
+	first, last := ipcalc.HostRange(prefix)
+	switch p.Gateway {
+	case "first":
+		gateway = first
+	case "last":
+		gateway = last
+	}
+
+	reasons := make(map[netip.Addr]string)
+	a := first
+	for range p.First {
+		reasons[a] = fmt.Sprintf("first=%d", p.First)
+		if a == last {
+			break
+		}
+		a = a.Next()
+	}
+	a = last
+	for range p.Last {
+		if _, ok := reasons[a]; !ok {
+			reasons[a] = fmt.Sprintf("last=%d", p.Last)
+		}
+		if a == first {
+			break
+		}
+		a = a.Prev()
+	}
+	if gateway.IsValid() {
+		reasons[gateway] = "gateway=" + p.Gateway
+	}
+
+	for a, reason := range reasons {
+		reserved = append(reserved, reservedAddr{Addr: a, Reason: reason})
+	}
+	slices.SortFunc(reserved, func(x, y reservedAddr) int { return x.Addr.Compare(y.Addr) })
+	return gateway, reserved
+}
+
+// defaultReservationPolicy reads the policy for networks created without one
+// once from RESERVATION_POLICY. A missing or invalid value reserves nothing.
+var defaultReservationPolicy = sync.OnceValue(func() reservationPolicy {
+	s := os.Getenv("RESERVATION_POLICY")
+	p, err := parseReservationPolicy(s)
+	if err != nil {
+		log.Printf("Ignoring RESERVATION_POLICY=%q: %v", s, err)
+		return reservationPolicy{}
+	}
+	return p
+})
+
+// subnetReservationPolicy returns the policy for a new network: s, or the
+// default when s is empty.
+func subnetReservationPolicy(s string) (reservationPolicy, error) {
+	if strings.TrimSpace(s) == "" {
+		return defaultReservationPolicy(), nil
+	}
+	return parseReservationPolicy(s)
+}
+
+// gatewayArg passes a planned gateway to a query; no gateway is NULL.
+func gatewayArg(gateway netip.Addr) any {
+	if !gateway.IsValid() {
+		return nil
+	}
+	return gateway
+}
+
+// reserveByPolicy stores the addresses planned by a reservation policy in the
+// new network subnetID, within the transaction creating it, and records them
+// in the audit log.
+func reserveByPolicy(ctx context.Context, tx pgx.Tx, subnetID pgtype.UUID, reserved []reservedAddr) error {
+	if len(reserved) == 0 {
+		return nil
+	}
+	addrs := make([]netip.Addr, len(reserved))
+	reasons := make([]string, len(reserved))
+	for i, r := range reserved {
+		addrs[i], reasons[i] = r.Addr, r.Reason
+	}
+	ips, err := queryIPsOn(ctx, tx,
+		`INSERT INTO ips (subnet_id, address, status, reservation_reason)
+		 SELECT $1, a, 'reserved', r FROM unnest($2::inet[], $3::text[]) AS t(a, r)
+		 RETURNING `+ipColumns,
+		subnetID, addrs, reasons)
+	if err != nil {
+		return err
+	}
+	entries := make([]auditEntry, len(ips))
+	for i, ip := range ips {
+		entries[i] = ipAudit("reserve", models.IP{}, ip)
+	}
+	return recordAudit(ctx, tx, entries...)
+}
+
+// dropGateways clears the gateway of the subnets whose gateway is among ips,
+// which are being returned to the pool: a freed gateway address may be
+// handed out, so it no longer is the gateway. It is called in the
+// transaction deleting the addresses, which records the change.
+func dropGateways(ctx context.Context, tx pgx.Tx, ips []models.IP) error {
+	ids := make([]pgtype.UUID, len(ips))
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		ids[i], addrs[i] = ip.SubnetID, ip.Address
+	}
+	subnets, err := querySubnetsOn(ctx, tx,
+		`SELECT `+subnetColumns+` FROM subnets
+		 WHERE (id, gateway) IN (SELECT * FROM unnest($1::uuid[], $2::inet[])) FOR UPDATE`, ids, addrs)
+	if err != nil || len(subnets) == 0 {
+		return err
+	}
+	entries := make([]auditEntry, len(subnets))
+	for i, before := range subnets {
+		if _, err := tx.Exec(ctx, "UPDATE subnets SET gateway = NULL WHERE id = $1", before.ID); err != nil {
+			return err
+		}
+		after := before
+		after.Gateway = ""
+		entries[i] = subnetAudit("update", &before, &after)
+	}
+	return recordAudit(ctx, tx, entries...)
+}
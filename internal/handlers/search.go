@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// maxSearchResults caps each kind of object returned by a search.
+const maxSearchResults = 25
+
+// minMACSearchDigits is the shortest partial MAC a search treats as one, so
+// that short words made of hex letters (e.g. "db") are not.
+const minMACSearchDigits = 4
+
+// HandleSearch handles GET /search?q=. The live results under the header
+// search box are requested by htmx and rendered as a dropdown; submitting the
+// box shows them as a page.
+func HandleSearch(w http.ResponseWriter, r *http.Request) {
+	results, err := search(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, err, "Failed to search")
+		return
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		templates.SearchDropdown(results).Render(r.Context(), w)
+		return
+	}
+	subnets, err := subnetsOf(r.Context(), results.IPs)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+	templates.SearchPage(results, subnets).Render(r.Context(), w)
+}
+
+// search matches query against subnets, addresses, devices and VLANs:
+//   - an IP address finds its stored rows and the subnets containing it,
+//     whether or not it is recorded;
+//   - a CIDR finds the subnet itself, the subnets containing it and those
+//     inside it;
+//   - any other text finds subnets by name, addresses by hostname, devices
+//     and VLANs by name or description, case-insensitively, and addresses by
+//     MAC when the text looks like (part of) one.
+//
+// Searches span all VRFs.
+func search(ctx context.Context, query string) (models.SearchResults, error) {
+	results := models.SearchResults{Query: strings.TrimSpace(query)}
+	if results.Query == "" {
+		return results, nil
+	}
+	var err error
+	if addr, perr := netip.ParseAddr(results.Query); perr == nil {
+		addr = addr.Unmap()
+		results.Address = addr.String()
+		if results.Subnets, err = querySubnets(ctx,
+			"SELECT "+subnetColumns+" FROM subnets WHERE cidr >>= $1 ORDER BY masklen(cidr) DESC, vrf_id NULLS FIRST LIMIT $2",
+			addr, maxSearchResults); err != nil {
+			return results, err
+		}
+		results.IPs, err = queryIPs(ctx, "SELECT "+ipColumns+" FROM ips WHERE address = $1 ORDER BY subnet_id LIMIT $2",
+			addr, maxSearchResults)
+		return results, err
+	}
+	if prefix, perr := netip.ParsePrefix(results.Query); perr == nil {
+		results.Subnets, err = querySubnets(ctx,
+			"SELECT "+subnetColumns+" FROM subnets WHERE cidr >>= $1 OR cidr << $1 ORDER BY cidr, vrf_id NULLS FIRST LIMIT $2",
+			prefix.Masked(), maxSearchResults)
+		return results, err
+	}
+
+	pattern := "%" + likeEscaper.Replace(results.Query) + "%"
+	if results.Subnets, err = querySubnets(ctx,
+		"SELECT "+subnetColumns+" FROM subnets WHERE name ILIKE $1 ORDER BY name, cidr LIMIT $2",
+		pattern, maxSearchResults); err != nil {
+		return results, err
+	}
+	cond, args := "hostname ILIKE $1", []any{pattern, maxSearchResults}
+	if digits, derr := macSearchDigits(results.Query); derr == nil && len(digits) >= minMACSearchDigits {
+		args = append(args, digits)
+		cond += " OR replace(mac_address, ':', '') LIKE '%' || $3 || '%'"
+	}
+	if results.IPs, err = queryIPs(ctx,
+		"SELECT "+ipColumns+" FROM ips WHERE "+cond+" ORDER BY hostname, address LIMIT $2", args...); err != nil {
+		return results, err
+	}
+	if results.Devices, err = searchDevices(ctx, pattern); err != nil {
+		return results, err
+	}
+	results.VLANs, err = searchVLANs(ctx, pattern)
+	return results, err
+}
+
+// likeEscaper escapes the wildcards of LIKE so that search text matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchDevices returns the devices whose name or description matches the
+// ILIKE pattern.
+func searchDevices(ctx context.Context, pattern string) ([]models.Device, error) {
+	rows, err := database.DB.Query(ctx,
+		"SELECT "+deviceColumns+" FROM devices WHERE name ILIKE $1 OR description ILIKE $1 ORDER BY name LIMIT $2",
+		pattern, maxSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []models.Device
+	for rows.Next() {
+		var d models.Device
+		if err := scanDevice(rows, &d); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// searchVLANs returns the VLANs whose name or description matches the ILIKE
+// pattern.
+func searchVLANs(ctx context.Context, pattern string) ([]models.VLAN, error) {
+	rows, err := database.DB.Query(ctx,
+		"SELECT "+vlanColumns+" FROM vlans WHERE name ILIKE $1 OR description ILIKE $1 ORDER BY vid LIMIT $2",
+		pattern, maxSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vlans []models.VLAN
+	for rows.Next() {
+		var v models.VLAN
+		if err := scanVLAN(rows, &v); err != nil {
+			return nil, err
+		}
+		vlans = append(vlans, v)
+	}
+	return vlans, rows.Err()
+}
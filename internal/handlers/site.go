@@ -0,0 +1,669 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/netip"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// regionColumns is the column list scanned by scanRegion.
+const regionColumns = `id, name, description, created_at,
+	(SELECT COUNT(*) FROM sites s WHERE s.region_id = regions.id)`
+
+// siteColumns is the column list scanned by scanSite.
+const siteColumns = `id, region_id, (SELECT r.name FROM regions r WHERE r.id = sites.region_id),
+	name, description, created_at,
+	(SELECT COUNT(*) FROM subnets s WHERE s.site_id = sites.id),
+	(SELECT COUNT(*) FROM vlans v WHERE v.site_id = sites.id)`
+
+// locationColumns is the column list scanned by scanLocation.
+const locationColumns = `id, site_id, name, description, created_at`
+
+// HandleSiteList shows regions with their sites, locations and utilization.
+func HandleSiteList(w http.ResponseWriter, r *http.Request) {
+	regions, err := listRegions(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch regions")
+		return
+	}
+	sites, err := listSites(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch sites")
+		return
+	}
+
+	component := templates.SiteList(regions, sites)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateRegion(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in := models.Region{Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if _, err := createRegion(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create region")
+		return
+	}
+
+	HandleSiteList(w, r)
+}
+
+func HandleCreateSite(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	regionID, err := parseRefID(r.FormValue("region_id"), "invalid_region", "Invalid region ID")
+	if err != nil {
+		writeError(w, err, "Failed to create site")
+		return
+	}
+	in := models.Site{RegionID: regionID, Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if _, err := createSite(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create site")
+		return
+	}
+
+	HandleSiteList(w, r)
+}
+
+func HandleCreateLocation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	siteID, err := parseSiteID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to create location")
+		return
+	}
+	in := models.Location{SiteID: siteID, Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if _, err := createLocation(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create location")
+		return
+	}
+
+	HandleSiteList(w, r)
+}
+
+func HandleDeleteRegion(w http.ResponseWriter, r *http.Request) {
+	if err := deleteRegion(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete region")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func HandleDeleteSite(w http.ResponseWriter, r *http.Request) {
+	if err := deleteSite(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete site")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func HandleDeleteLocation(w http.ResponseWriter, r *http.Request) {
+	if err := deleteLocation(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete location")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseRefID parses a reference to another object from a form or query;
+// empty means none. code and message describe a malformed ID.
+func parseRefID(s, code, message string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if s == "" {
+		return id, nil
+	}
+	if err := id.Scan(s); err != nil {
+		return id, errInvalid(code, "%s", message)
+	}
+	return id, nil
+}
+
+// parseSiteID parses a site reference from a form or query; empty means no site.
+func parseSiteID(s string) (pgtype.UUID, error) {
+	return parseRefID(s, "invalid_site", "Invalid site ID")
+}
+
+// checkSiteExists reports an error if id refers to a site that does not
+// exist. The invalid ID (no site) always exists.
+func checkSiteExists(ctx context.Context, id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM sites WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("site_not_found", "Site not found")
+	}
+	return nil
+}
+
+// scanRegion scans a row selected with regionColumns.
+func scanRegion(row interface{ Scan(...any) error }, g *models.Region) error {
+	return row.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.SiteCount)
+}
+
+// scanSite scans a row selected with siteColumns.
+func scanSite(row interface{ Scan(...any) error }, s *models.Site) error {
+	return row.Scan(&s.ID, &s.RegionID, &s.RegionName, &s.Name, &s.Description, &s.CreatedAt, &s.SubnetCount, &s.VLANCount)
+}
+
+// scanLocation scans a row selected with locationColumns.
+func scanLocation(row interface{ Scan(...any) error }, l *models.Location) error {
+	return row.Scan(&l.ID, &l.SiteID, &l.Name, &l.Description, &l.CreatedAt)
+}
+
+func listRegions(ctx context.Context) ([]models.Region, error) {
+	rows, err := database.DB.Query(ctx, "SELECT "+regionColumns+" FROM regions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var regions []models.Region
+	for rows.Next() {
+		var g models.Region
+		if err := scanRegion(rows, &g); err != nil {
+			return nil, err
+		}
+		regions = append(regions, g)
+	}
+	return regions, rows.Err()
+}
+
+func getRegion(ctx context.Context, id string) (models.Region, error) {
+	var g models.Region
+	err := scanRegion(database.DB.QueryRow(ctx, "SELECT "+regionColumns+" FROM regions WHERE id = $1", id), &g)
+	if isNoRows(err) {
+		return g, errNotFound("region_not_found", "Region not found")
+	}
+	return g, err
+}
+
+func createRegion(ctx context.Context, in models.Region) (models.Region, error) {
+	var g models.Region
+	if in.Name == "" {
+		return g, errInvalid("missing_field", "name is required")
+	}
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanRegion(tx.QueryRow(ctx,
+			"INSERT INTO regions (name, description) VALUES ($1, $2) RETURNING "+regionColumns,
+			in.Name, in.Description), &g); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, regionAudit("create", nil, &g))
+	})
+	return g, uniqueWriteError(err, "region_exists", "A region with this name already exists")
+}
+
+// regionPatch lists the region fields that may be changed; nil fields are left as is.
+type regionPatch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+func updateRegion(ctx context.Context, id string, patch regionPatch) (models.Region, error) {
+	g, err := getRegion(ctx, id)
+	if err != nil {
+		return g, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return g, errInvalid("missing_field", "name must not be empty")
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Region
+		if err := scanRegion(tx.QueryRow(ctx, "SELECT "+regionColumns+" FROM regions WHERE id = $1 FOR UPDATE", g.ID), &before); err != nil {
+			return err
+		}
+		g = before
+		if patch.Name != nil {
+			g.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			g.Description = *patch.Description
+		}
+		if err := scanRegion(tx.QueryRow(ctx,
+			"UPDATE regions SET name = $2, description = $3 WHERE id = $1 RETURNING "+regionColumns,
+			g.ID, g.Name, g.Description), &g); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, regionAudit("update", &before, &g))
+	})
+	if isNoRows(err) {
+		return g, errNotFound("region_not_found", "Region not found")
+	}
+	return g, uniqueWriteError(err, "region_exists", "A region with this name already exists")
+}
+
+// deleteRegion removes a region that no longer holds any sites.
+func deleteRegion(ctx context.Context, id string) error {
+	g, err := getRegion(ctx, id)
+	if err != nil {
+		return err
+	}
+	if g.SiteCount > 0 {
+		return errConflict("region_in_use", "Region %s still holds %d sites", g.Name, g.SiteCount)
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM regions WHERE id = $1", g.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, regionAudit("delete", &g, nil))
+	})
+	if database.ErrorCode(err) == database.ForeignKeyViolation {
+		return errConflict("region_in_use", "Region %s still holds sites", g.Name)
+	}
+	return err
+}
+
+// regionAudit describes a change to a region; before is nil when it was
+// created and after is nil when it was deleted.
+func regionAudit(action string, before, after *models.Region) auditEntry {
+	g := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectRegion, g.ID, g.Name, before, after)
+}
+
+// listSites returns the sites of a region (all sites when regionID is empty)
+// with their locations and utilization, ordered by region and name.
+func listSites(ctx context.Context, regionID string) ([]models.Site, error) {
+	where, args := "TRUE", []any{}
+	if regionID != "" {
+		id, err := parseRefID(regionID, "invalid_region", "Invalid region ID")
+		if err != nil {
+			return nil, err
+		}
+		where, args = "region_id = $1", append(args, id)
+	}
+	rows, err := database.DB.Query(ctx,
+		"SELECT "+siteColumns+" FROM sites WHERE "+where+
+			" ORDER BY (SELECT r.name FROM regions r WHERE r.id = sites.region_id), name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []models.Site
+	for rows.Next() {
+		var s models.Site
+		if err := scanSite(rows, &s); err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sites, fillSites(ctx, sites)
+}
+
+func getSite(ctx context.Context, id string) (models.Site, error) {
+	var s models.Site
+	err := scanSite(database.DB.QueryRow(ctx, "SELECT "+siteColumns+" FROM sites WHERE id = $1", id), &s)
+	if isNoRows(err) {
+		return s, errNotFound("site_not_found", "Site not found")
+	}
+	if err != nil {
+		return s, err
+	}
+	sites := []models.Site{s}
+	err = fillSites(ctx, sites)
+	return sites[0], err
+}
+
+// fillSites loads the locations and utilization of sites.
+func fillSites(ctx context.Context, sites []models.Site) error {
+	if len(sites) == 0 {
+		return nil
+	}
+	locations, err := listLocations(ctx, "")
+	if err != nil {
+		return err
+	}
+	usage, err := siteUtilization(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range sites {
+		for _, l := range locations {
+			if l.SiteID == sites[i].ID {
+				sites[i].Locations = append(sites[i].Locations, l)
+			}
+		}
+		sites[i].Utilization.Add(usage[sites[i].ID])
+	}
+	return nil
+}
+
+// siteUtilization sums up the utilization of the networks of each site.
+// Containers are left out, as their space is counted in their children.
+func siteUtilization(ctx context.Context) (map[pgtype.UUID]models.Utilization, error) {
+	rows, err := database.DB.Query(ctx,
+		`SELECT s.site_id, s.cidr,
+		   (SELECT COUNT(*) FROM ips i WHERE i.subnet_id = s.id AND i.status <> 'available')
+		 FROM subnets s WHERE s.site_id IS NOT NULL AND s.kind = $1`, models.SubnetKindNetwork)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[pgtype.UUID]models.Utilization)
+	for rows.Next() {
+		var siteID pgtype.UUID
+		var prefix netip.Prefix
+		var used int64
+		if err := rows.Scan(&siteID, &prefix, &used); err != nil {
+			return nil, err
+		}
+		u := usage[siteID]
+		u.Add(models.Utilization{Used: big.NewInt(used), Total: ipcalc.HostCount(prefix)})
+		usage[siteID] = u
+	}
+	return usage, rows.Err()
+}
+
+// validateSite checks the fields of a site about to be written.
+func validateSite(ctx context.Context, s models.Site) error {
+	if s.Name == "" {
+		return errInvalid("missing_field", "name is required")
+	}
+	if !s.RegionID.Valid {
+		return errInvalid("missing_field", "region_id is required")
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM regions WHERE id = $1)", s.RegionID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("region_not_found", "Region not found")
+	}
+	return nil
+}
+
+func createSite(ctx context.Context, in models.Site) (models.Site, error) {
+	var s models.Site
+	if err := validateSite(ctx, in); err != nil {
+		return s, err
+	}
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanSite(tx.QueryRow(ctx,
+			"INSERT INTO sites (region_id, name, description) VALUES ($1, $2, $3) RETURNING "+siteColumns,
+			in.RegionID, in.Name, in.Description), &s); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, siteAudit("create", nil, &s))
+	})
+	if err != nil {
+		return s, uniqueWriteError(err, "site_exists", "A site with this name already exists")
+	}
+	return getSite(ctx, s.ID.String())
+}
+
+// sitePatch lists the site fields that may be changed; nil fields are left as is.
+type sitePatch struct {
+	RegionID    *pgtype.UUID `json:"region_id"`
+	Name        *string      `json:"name"`
+	Description *string      `json:"description"`
+}
+
+func updateSite(ctx context.Context, id string, patch sitePatch) (models.Site, error) {
+	s, err := getSite(ctx, id)
+	if err != nil {
+		return s, err
+	}
+	// The snapshots leave out the locations and utilization loaded by getSite.
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Site
+		if err := scanSite(tx.QueryRow(ctx, "SELECT "+siteColumns+" FROM sites WHERE id = $1 FOR UPDATE", s.ID), &before); err != nil {
+			return err
+		}
+		s = before
+		if patch.RegionID != nil {
+			s.RegionID = *patch.RegionID
+		}
+		if patch.Name != nil {
+			s.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			s.Description = *patch.Description
+		}
+		if err := validateSite(ctx, s); err != nil {
+			return err
+		}
+		if err := scanSite(tx.QueryRow(ctx,
+			"UPDATE sites SET region_id = $2, name = $3, description = $4 WHERE id = $1 RETURNING "+siteColumns,
+			s.ID, s.RegionID, s.Name, s.Description), &s); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, siteAudit("update", &before, &s))
+	})
+	if isNoRows(err) {
+		return s, errNotFound("site_not_found", "Site not found")
+	}
+	if err != nil {
+		return s, uniqueWriteError(err, "site_exists", "A site with this name already exists")
+	}
+	return getSite(ctx, id)
+}
+
+// deleteSite removes a site, including its locations, once no subnets or
+// VLANs are assigned to it.
+func deleteSite(ctx context.Context, id string) error {
+	s, err := getSite(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.SubnetCount > 0 || s.VLANCount > 0 {
+		return errConflict("site_in_use", "Site %s still holds %d subnets and %d VLANs", s.Name, s.SubnetCount, s.VLANCount)
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Site
+		if err := scanSite(tx.QueryRow(ctx, "SELECT "+siteColumns+" FROM sites WHERE id = $1 FOR UPDATE", s.ID), &before); err != nil {
+			return err
+		}
+		locations, err := queryLocationsOn(ctx, tx, "SELECT "+locationColumns+" FROM locations WHERE site_id = $1 ORDER BY name", s.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM sites WHERE id = $1", s.ID); err != nil {
+			return err
+		}
+		// Its locations go with it.
+		entries := []auditEntry{siteAudit("delete", &before, nil)}
+		for _, l := range locations {
+			entries = append(entries, locationAudit("delete", &l, nil))
+		}
+		return recordAudit(ctx, tx, entries...)
+	})
+	if isNoRows(err) {
+		return errNotFound("site_not_found", "Site not found")
+	}
+	if database.ErrorCode(err) == database.ForeignKeyViolation {
+		return errConflict("site_in_use", "Site %s still holds subnets, VLANs or devices", s.Name)
+	}
+	return err
+}
+
+// siteAudit describes a change to a site; before is nil when it was created
+// and after is nil when it was deleted.
+func siteAudit(action string, before, after *models.Site) auditEntry {
+	s := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectSite, s.ID, s.Name, before, after)
+}
+
+// listLocations returns the locations of a site (all locations when siteID
+// is empty), ordered by name.
+func listLocations(ctx context.Context, siteID string) ([]models.Location, error) {
+	where, args := "TRUE", []any{}
+	if siteID != "" {
+		id, err := parseSiteID(siteID)
+		if err != nil {
+			return nil, err
+		}
+		where, args = "site_id = $1", append(args, id)
+	}
+	return queryLocationsOn(ctx, database.DB, "SELECT "+locationColumns+" FROM locations WHERE "+where+" ORDER BY name", args...)
+}
+
+// queryLocationsOn runs a query selecting locationColumns on q and scans
+// the result.
+func queryLocationsOn(ctx context.Context, q database.Querier, sql string, args ...any) ([]models.Location, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []models.Location
+	for rows.Next() {
+		var l models.Location
+		if err := scanLocation(rows, &l); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+func getLocation(ctx context.Context, id string) (models.Location, error) {
+	var l models.Location
+	err := scanLocation(database.DB.QueryRow(ctx, "SELECT "+locationColumns+" FROM locations WHERE id = $1", id), &l)
+	if isNoRows(err) {
+		return l, errNotFound("location_not_found", "Location not found")
+	}
+	return l, err
+}
+
+func createLocation(ctx context.Context, in models.Location) (models.Location, error) {
+	var l models.Location
+	if in.Name == "" {
+		return l, errInvalid("missing_field", "name is required")
+	}
+	if !in.SiteID.Valid {
+		return l, errInvalid("missing_field", "site_id is required")
+	}
+	if err := checkSiteExists(ctx, in.SiteID); err != nil {
+		return l, err
+	}
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanLocation(tx.QueryRow(ctx,
+			"INSERT INTO locations (site_id, name, description) VALUES ($1, $2, $3) RETURNING "+locationColumns,
+			in.SiteID, in.Name, in.Description), &l); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, locationAudit("create", nil, &l))
+	})
+	return l, uniqueWriteError(err, "location_exists", "A location with this name already exists at the site")
+}
+
+// locationPatch lists the location fields that may be changed; nil fields are left as is.
+type locationPatch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+func updateLocation(ctx context.Context, id string, patch locationPatch) (models.Location, error) {
+	l, err := getLocation(ctx, id)
+	if err != nil {
+		return l, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return l, errInvalid("missing_field", "name must not be empty")
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Location
+		if err := scanLocation(tx.QueryRow(ctx, "SELECT "+locationColumns+" FROM locations WHERE id = $1 FOR UPDATE", l.ID), &before); err != nil {
+			return err
+		}
+		l = before
+		if patch.Name != nil {
+			l.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			l.Description = *patch.Description
+		}
+		if err := scanLocation(tx.QueryRow(ctx,
+			"UPDATE locations SET name = $2, description = $3 WHERE id = $1 RETURNING "+locationColumns,
+			l.ID, l.Name, l.Description), &l); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, locationAudit("update", &before, &l))
+	})
+	if isNoRows(err) {
+		return l, errNotFound("location_not_found", "Location not found")
+	}
+	return l, uniqueWriteError(err, "location_exists", "A location with this name already exists at the site")
+}
+
+func deleteLocation(ctx context.Context, id string) error {
+	l, err := getLocation(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM locations WHERE id = $1", l.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, locationAudit("delete", &l, nil))
+	})
+}
+
+// locationAudit describes a change to a location; before is nil when it was
+// created and after is nil when it was deleted.
+func locationAudit(action string, before, after *models.Location) auditEntry {
+	l := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectLocation, l.ID, l.Name, before, after)
+}
+
+// uniqueWriteError turns unique violations into a conflict error with the given code and message.
+func uniqueWriteError(err error, code, message string) error {
+	if database.ErrorCode(err) == database.UniqueViolation {
+		return errConflict(code, "%s", message)
+	}
+	return err
+}
+
+// siteGroups groups top-level subnets by their site for the dashboard, in
+// the order of sites, followed by subnets without a site.
+func siteGroups(nodes []models.SubnetNode, sites []models.Site) []templates.SubnetGroup {
+	bySite := make(map[pgtype.UUID][]models.SubnetNode)
+	for _, n := range nodes {
+		bySite[n.SiteID] = append(bySite[n.SiteID], n)
+	}
+	var groups []templates.SubnetGroup
+	for _, s := range sites {
+		if len(bySite[s.ID]) > 0 {
+			groups = append(groups, templates.SubnetGroup{
+				Label:       fmt.Sprintf("%s · %s", s.RegionName, s.Name),
+				Site:        &s,
+				Subnets:     bySite[s.ID],
+				Utilization: s.Utilization,
+			})
+		}
+	}
+	if rest := bySite[pgtype.UUID{}]; len(rest) > 0 {
+		groups = append(groups, templates.SubnetGroup{Label: "No site", Subnets: rest})
+	}
+	return groups
+}
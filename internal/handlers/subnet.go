@@ -2,128 +2,852 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
 	"github.com/ttani03/goth-ipam/internal/models"
 	"github.com/ttani03/goth-ipam/internal/templates"
 )
 
+// subnetColumns is the column list scanned by scanSubnet. The parent is the
+// smallest other subnet of the same VRF enclosing this one, so nesting always
+// follows the CIDRs.
+const subnetColumns = `id, cidr, name, kind, vrf_id,
+	COALESCE((SELECT v.name FROM vrfs v WHERE v.id = subnets.vrf_id), ''),
+	vlan_id, COALESCE((SELECT format('%s (%s)', l.vid, l.name) FROM vlans l WHERE l.id = subnets.vlan_id), ''),
+	site_id, COALESCE((SELECT st.name FROM sites st WHERE st.id = subnets.site_id), ''),
+	tenant_id, COALESCE((SELECT t.name FROM tenants t WHERE t.id = subnets.tenant_id), ''),
+	COALESCE((SELECT json_agg(json_build_object('id', t.id, 'name', t.name, 'color', t.color) ORDER BY t.name)
+	 FROM subnet_tags x JOIN tags t ON t.id = x.tag_id WHERE x.subnet_id = subnets.id), '[]'),
+	custom_fields,
+	(SELECT p.id FROM subnets p
+	 WHERE p.cidr >> subnets.cidr AND p.vrf_id IS NOT DISTINCT FROM subnets.vrf_id
+	 ORDER BY masklen(p.cidr) DESC LIMIT 1),
+	gateway, reservation_policy, created_at`
+
 func HandleSubnetList(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(context.Background(), "SELECT id, cidr, name, created_at FROM subnets ORDER BY created_at DESC")
+	q := r.URL.Query()
+	filter := subnetFilter{VRF: q.Get("vrf"), Site: q.Get("site"), Tenant: q.Get("tenant"), Tag: q.Get("tag")}
+	fields, err := listCustomFields(r.Context(), models.CustomFieldObjectSubnet)
 	if err != nil {
-		http.Error(w, "Failed to fetch subnets", http.StatusInternalServerError)
+		writeError(w, err, "Failed to fetch custom fields")
+		return
+	}
+	if filter.Fields, err = customFieldFilters(r.Context(), models.CustomFieldObjectSubnet, q); err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+	sort, err := parseSubnetSort(q.Get("sort"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+	subnets, err := listSubnets(r.Context(), filter)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+	if err := fillSubnetStats(r.Context(), subnets); err != nil {
+		writeError(w, err, "Failed to fetch utilization")
+		return
+	}
+	vrfs, err := listVRFs(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch VRFs")
+		return
+	}
+	vlans, err := listVLANs(r.Context(), vlanFilter{})
+	if err != nil {
+		writeError(w, err, "Failed to fetch VLANs")
 		return
 	}
-	defer rows.Close()
 
-	var subnets []models.Subnet
-	for rows.Next() {
-		var s models.Subnet
-		// Assuming scanning works fine here correctly mapping types
-		if err := rows.Scan(&s.ID, &s.CIDR, &s.Name, &s.CreatedAt); err != nil {
-			continue
+	sites, err := listSites(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch sites")
+		return
+	}
+	tenants, err := listTenants(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tenants")
+		return
+	}
+	tags, err := listTags(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tags")
+		return
+	}
+
+	// Only the top-level cards are reordered; nested subnets stay in CIDR order.
+	groups := siteGroups(subnetTree(subnets, pgtype.UUID{}), sites)
+	if sort == sortUtilization {
+		for _, g := range groups {
+			slices.SortStableFunc(g.Subnets, func(a, b models.SubnetNode) int { return byUtilization(a.Subnet, b.Subnet) })
 		}
-		subnets = append(subnets, s)
 	}
 
-	component := templates.SubnetList(subnets)
-	component.Render(context.Background(), w)
+	component := templates.SubnetList(templates.SubnetListData{
+		Groups:       groups,
+		VRFs:         vrfs,
+		VLANs:        vlans,
+		Sites:        sites,
+		Tenants:      tenants,
+		Tags:         tags,
+		Fields:       fields,
+		VRFFilter:    filter.VRF,
+		SiteFilter:   filter.Site,
+		TenantFilter: filter.Tenant,
+		TagFilter:    filter.Tag,
+		FieldFilters: filter.Fields,
+		Sort:         sort,
+		Warning:      utilizationThresholds().Warning,
+		Critical:     utilizationThresholds().Critical,
+		Reservation:  defaultReservationPolicy().String(),
+	})
+	component.Render(r.Context(), w)
 }
 
-// minIPv4Prefix is the minimum allowed prefix length for IPv4 subnets.
-// Subnets broader than /16 (> 65536 addresses) are rejected to prevent resource exhaustion.
-const minIPv4Prefix = 16
-
 func HandleCreateSubnet(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
 	}
 
-	cidr := r.FormValue("cidr")
-	name := r.FormValue("name")
+	vrfID, err := parseVRFID(r.FormValue("vrf_id"))
+	if err != nil {
+		writeError(w, err, "Failed to create subnet")
+		return
+	}
+	vlanID, err := parseVLANID(r.FormValue("vlan_id"))
+	if err != nil {
+		writeError(w, err, "Failed to create subnet")
+		return
+	}
+	siteID, err := parseSiteID(r.FormValue("site_id"))
+	if err != nil {
+		writeError(w, err, "Failed to create subnet")
+		return
+	}
+	tenantID, err := parseTenantID(r.FormValue("tenant_id"))
+	if err != nil {
+		writeError(w, err, "Failed to create subnet")
+		return
+	}
+	in := models.Subnet{
+		CIDR:              r.FormValue("cidr"),
+		Name:              r.FormValue("name"),
+		Kind:              r.FormValue("kind"),
+		VRFID:             vrfID,
+		VLANID:            vlanID,
+		SiteID:            siteID,
+		TenantID:          tenantID,
+		CustomFields:      customFieldsFromForm(r.PostForm),
+		ReservationPolicy: r.FormValue("reservation_policy"),
+	}
+	if _, err := createSubnet(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create subnet")
+		return
+	}
+
+	// Return updated list
+	HandleSubnetList(w, r)
+}
 
-	// Basic input validation
-	if cidr == "" || name == "" {
-		http.Error(w, "cidr and name are required", http.StatusBadRequest)
+// HandleCarveSubnet creates a child subnet from the next free block of a
+// container and shows the new subnet.
+func HandleCarveSubnet(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	bits, err := strconv.Atoi(strings.TrimPrefix(r.FormValue("prefix_length"), "/"))
+	if err != nil {
+		http.Error(w, "Invalid prefix length", http.StatusBadRequest)
 		return
 	}
 
-	// Validate CIDR format and prefix length before any DB write
-	ip, ipNet, err := net.ParseCIDR(cidr)
+	s, err := carveSubnet(r.Context(), r.PathValue("id"), bits, r.FormValue("name"), r.FormValue("kind"))
 	if err != nil {
-		log.Printf("Invalid CIDR %s: %v", cidr, err)
-		http.Error(w, "Invalid CIDR format", http.StatusBadRequest)
+		writeError(w, err, "Failed to carve subnet")
 		return
 	}
-	if ipNet.IP.To4() != nil {
-		ones, _ := ipNet.Mask.Size()
-		if ones < minIPv4Prefix {
-			http.Error(w, fmt.Sprintf("CIDR prefix must be /%d or longer (e.g. /8, /24)", minIPv4Prefix), http.StatusBadRequest)
-			return
-		}
+
+	http.Redirect(w, r, "/subnets/"+s.ID.String(), http.StatusSeeOther)
+}
+
+// HandleUpdateSubnetFields saves the custom fields edited on a subnet's detail
+// page and shows the page again.
+func HandleUpdateSubnetFields(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	s, err := updateSubnet(r.Context(), r.PathValue("id"), subnetPatch{CustomFields: customFieldsFromForm(r.PostForm)})
+	if err != nil {
+		writeError(w, err, "Failed to update subnet")
+		return
 	}
 
-	// Insert subnet and get generated ID
-	var subnetID string
-	if err := database.DB.QueryRow(context.Background(),
-		"INSERT INTO subnets (cidr, name) VALUES ($1, $2) RETURNING id",
-		cidr, name).Scan(&subnetID); err != nil {
-		log.Printf("Error creating subnet: %v", err)
-		http.Error(w, "Failed to create subnet", http.StatusInternalServerError)
+	http.Redirect(w, r, "/subnets/"+s.ID.String(), http.StatusSeeOther)
+}
+
+func HandleDeleteSubnet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id") // Go 1.22+
+
+	// Subnets nested inside a deleted container move up a level, so the whole
+	// tree is redrawn instead of only removing the card.
+	var hasChildren bool
+	if err := database.DB.QueryRow(r.Context(),
+		`SELECT EXISTS (SELECT 1 FROM subnets c JOIN subnets p
+		 ON c.cidr << p.cidr AND c.vrf_id IS NOT DISTINCT FROM p.vrf_id WHERE p.id = $1)`, id).Scan(&hasChildren); err != nil {
+		writeError(w, err, "Failed to delete subnet")
 		return
 	}
 
-	// Collect all IPs in range (excluding network and broadcast for IPv4)
-	var addresses []string
-	for cur := cloneIP(ip.Mask(ipNet.Mask)); ipNet.Contains(cur); incrementIP(cur) {
-		addresses = append(addresses, cur.String())
+	if err := deleteSubnet(r.Context(), id); err != nil {
+		writeError(w, err, "Failed to delete subnet")
+		return
 	}
-	// Remove network address (first) and broadcast address (last) for IPv4
-	if len(addresses) > 2 && ipNet.IP.To4() != nil {
-		addresses = addresses[1 : len(addresses)-1]
+
+	if hasChildren {
+		w.Header().Set("HX-Refresh", "true")
 	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	for _, addr := range addresses {
-		if _, err := database.DB.Exec(context.Background(),
-			"INSERT INTO ips (subnet_id, address, status) VALUES ($1, $2, 'available')",
-			subnetID, addr); err != nil {
-			log.Printf("Error inserting IP %s: %v", addr, err)
+// scanSubnet scans a row selected with subnetColumns.
+func scanSubnet(row interface{ Scan(...any) error }, s *models.Subnet) error {
+	return row.Scan(&s.ID, database.CIDR(&s.CIDR), &s.Name, &s.Kind, &s.VRFID, &s.VRFName, &s.VLANID, &s.VLANLabel, &s.SiteID, &s.SiteName, &s.TenantID, &s.TenantName, &s.Tags, &s.CustomFields, &s.ParentID,
+		database.Addr(&s.Gateway), &s.ReservationPolicy, &s.CreatedAt)
+}
+
+// subnetTree nests subnets under their parents and returns the subnets whose
+// parent is root. With the zero UUID as root, the top-level subnets are
+// returned, including those whose parent was filtered out of subnets. Order
+// is preserved.
+func subnetTree(subnets []models.Subnet, root pgtype.UUID) []models.SubnetNode {
+	listed := make(map[pgtype.UUID]bool, len(subnets))
+	for _, s := range subnets {
+		listed[s.ID] = true
+	}
+	children := make(map[pgtype.UUID][]models.Subnet)
+	for _, s := range subnets {
+		parent := s.ParentID
+		if !root.Valid && !listed[parent] {
+			parent = root
+		}
+		children[parent] = append(children[parent], s)
+	}
+	var build func(parent pgtype.UUID) []models.SubnetNode
+	build = func(parent pgtype.UUID) []models.SubnetNode {
+		var nodes []models.SubnetNode
+		for _, s := range children[parent] {
+			nodes = append(nodes, models.SubnetNode{Subnet: s, Children: build(s.ID)})
 		}
+		return nodes
 	}
+	return build(root)
+}
 
-	// Return updated list
-	HandleSubnetList(w, r)
+// subnetFilter narrows down listSubnets; zero fields do not filter.
+type subnetFilter struct {
+	VRF    string            // VRF ID, or "global" for subnets outside any VRF
+	Site   string            // site ID, or "none" for subnets without a site
+	Tenant string            // tenant ID, or "none" for subnets without a tenant
+	Tag    string            // tag ID
+	Fields map[string]string // custom field values by name, as returned by customFieldFilters
+}
+
+// where returns the SQL condition selecting the filtered subnets and its arguments.
+func (f subnetFilter) where() (string, []any, error) {
+	conds := []string{"TRUE"}
+	var args []any
+	switch f.VRF {
+	case "":
+	case "global":
+		conds = append(conds, "vrf_id IS NULL")
+	default:
+		id, err := parseVRFID(f.VRF)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("vrf_id = $%d", len(args)))
+	}
+	switch f.Site {
+	case "":
+	case "none":
+		conds = append(conds, "site_id IS NULL")
+	default:
+		id, err := parseSiteID(f.Site)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("site_id = $%d", len(args)))
+	}
+	switch f.Tenant {
+	case "":
+	case "none":
+		conds = append(conds, "tenant_id IS NULL")
+	default:
+		id, err := parseTenantID(f.Tenant)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("tenant_id = $%d", len(args)))
+	}
+	if f.Tag != "" {
+		id, err := parseTagID(f.Tag)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("EXISTS (SELECT 1 FROM subnet_tags x WHERE x.subnet_id = subnets.id AND x.tag_id = $%d)", len(args)))
+	}
+	conds = append(conds, customFieldConds(f.Fields, &args)...)
+	return strings.Join(conds, " AND "), args, nil
+}
+
+func listSubnets(ctx context.Context, f subnetFilter) ([]models.Subnet, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, err
+	}
+	return querySubnets(ctx, "SELECT "+subnetColumns+" FROM subnets WHERE "+where+" ORDER BY vrf_id NULLS FIRST, cidr", args...)
+}
+
+// ancestorSubnets returns the containers of the same VRF enclosing subnet, outermost first.
+func ancestorSubnets(ctx context.Context, subnet models.Subnet) ([]models.Subnet, error) {
+	return querySubnets(ctx,
+		"SELECT "+subnetColumns+" FROM subnets WHERE cidr >> $1 AND vrf_id IS NOT DISTINCT FROM $2 ORDER BY masklen(cidr)",
+		subnet.CIDR, subnet.VRFID)
 }
 
-func cloneIP(ip net.IP) net.IP {
-	clone := make(net.IP, len(ip))
-	copy(clone, ip)
-	return clone
+// descendantSubnets returns every subnet of the same VRF nested inside subnet, ordered by CIDR.
+func descendantSubnets(ctx context.Context, subnet models.Subnet) ([]models.Subnet, error) {
+	return querySubnets(ctx,
+		"SELECT "+subnetColumns+" FROM subnets WHERE cidr << $1 AND vrf_id IS NOT DISTINCT FROM $2 ORDER BY cidr",
+		subnet.CIDR, subnet.VRFID)
 }
 
-func incrementIP(ip net.IP) {
-	for i := len(ip) - 1; i >= 0; i-- {
-		ip[i]++
-		if ip[i] != 0 {
-			break
+// querySubnets runs a query selecting subnetColumns and scans the result.
+func querySubnets(ctx context.Context, sql string, args ...any) ([]models.Subnet, error) {
+	return querySubnetsOn(ctx, database.DB, sql, args...)
+}
+
+// querySubnetsOn is querySubnets on q, e.g. a transaction.
+func querySubnetsOn(ctx context.Context, q database.Querier, sql string, args ...any) ([]models.Subnet, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subnets []models.Subnet
+	for rows.Next() {
+		var s models.Subnet
+		if err := scanSubnet(rows, &s); err != nil {
+			return nil, err
 		}
+		subnets = append(subnets, s)
 	}
+	return subnets, rows.Err()
 }
 
-func HandleDeleteSubnet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id") // Go 1.22+
+// subnetSnapshots locks the subnets ids on q and returns them keyed by ID,
+// for recordChanges.
+func subnetSnapshots(ctx context.Context, q database.Querier, ids []pgtype.UUID) (map[pgtype.UUID]models.Subnet, error) {
+	subnets, err := querySubnetsOn(ctx, q, "SELECT "+subnetColumns+" FROM subnets WHERE id = ANY($1) FOR UPDATE", ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[pgtype.UUID]models.Subnet, len(subnets))
+	for _, s := range subnets {
+		byID[s.ID] = s
+	}
+	return byID, nil
+}
 
-	_, err := database.DB.Exec(context.Background(), "DELETE FROM subnets WHERE id = $1", id)
+// subnetsOf returns the subnets of addresses gathered across subnets, keyed
+// by subnet ID, so that lists such as a device's addresses can name them.
+func subnetsOf(ctx context.Context, ips []models.IP) (map[string]models.Subnet, error) {
+	ids := make([]pgtype.UUID, len(ips))
+	for i, ip := range ips {
+		ids[i] = ip.SubnetID
+	}
+	subnets, err := querySubnets(ctx, "SELECT "+subnetColumns+" FROM subnets WHERE id = ANY($1)", ids)
 	if err != nil {
-		log.Printf("Error deleting subnet: %v", err)
-		http.Error(w, "Failed to delete subnet", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
+	byID := make(map[string]models.Subnet, len(subnets))
+	for _, s := range subnets {
+		byID[s.ID.String()] = s
+	}
+	return byID, nil
+}
 
-	w.WriteHeader(http.StatusOK)
+func getSubnet(ctx context.Context, id string) (models.Subnet, error) {
+	var s models.Subnet
+	err := scanSubnet(database.DB.QueryRow(ctx, "SELECT "+subnetColumns+" FROM subnets WHERE id = $1", id), &s)
+	if isNoRows(err) {
+		return s, errNotFound("subnet_not_found", "Subnet not found")
+	}
+	return s, err
+}
+
+// getNetwork is getSubnet for operations on host addresses, which containers do not hold.
+func getNetwork(ctx context.Context, id string) (models.Subnet, error) {
+	s, err := getSubnet(ctx, id)
+	if err == nil && s.Kind == models.SubnetKindContainer {
+		return s, errConflict("subnet_is_container", "%s is a container and holds no host addresses", s.CIDR)
+	}
+	return s, err
+}
+
+// parseSubnetCIDR validates user input and returns the canonical network
+// prefix (RFC 5952 for IPv6), e.g. "2001:DB8:0:0::1/64" becomes 2001:db8::/64.
+func parseSubnetCIDR(cidr string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, errInvalid("invalid_cidr", "Invalid CIDR format")
+	}
+	if prefix.Addr().Is4In6() {
+		return netip.Prefix{}, errInvalid("invalid_cidr", "IPv4-mapped IPv6 prefixes are not supported; use the IPv4 form")
+	}
+	return prefix.Masked(), nil
+}
+
+// parseSubnetKind validates a subnet kind; empty means a network.
+func parseSubnetKind(kind string) (string, error) {
+	switch kind {
+	case "":
+		return models.SubnetKindNetwork, nil
+	case models.SubnetKindNetwork, models.SubnetKindContainer:
+		return kind, nil
+	}
+	return "", errInvalid("invalid_kind", "kind must be network or container")
+}
+
+// createSubnet validates and stores a new subnet from the CIDR, Name, Kind,
+// VRFID, VLANID, SiteID, TenantID and CustomFields of in. A network also gets
+// the addresses reserved by in.ReservationPolicy, or by the default policy
+// when it is empty.
+func createSubnet(ctx context.Context, in models.Subnet) (models.Subnet, error) {
+	var s models.Subnet
+	if in.CIDR == "" || in.Name == "" {
+		return s, errInvalid("missing_field", "cidr and name are required")
+	}
+	prefix, err := parseSubnetCIDR(in.CIDR)
+	if err != nil {
+		return s, err
+	}
+	kind, err := parseSubnetKind(in.Kind)
+	if err != nil {
+		return s, err
+	}
+	if err := checkVRFExists(ctx, in.VRFID); err != nil {
+		return s, err
+	}
+	if err := checkVLANExists(ctx, in.VLANID); err != nil {
+		return s, err
+	}
+	if err := checkSiteExists(ctx, in.SiteID); err != nil {
+		return s, err
+	}
+	if err := checkTenantExists(ctx, in.TenantID); err != nil {
+		return s, err
+	}
+	customFields, err := applyCustomFields(ctx, models.CustomFieldObjectSubnet, nil, in.CustomFields)
+	if err != nil {
+		return s, err
+	}
+	// Containers hold no addresses, so they have nothing to reserve.
+	var policy reservationPolicy
+	if kind == models.SubnetKindNetwork {
+		if policy, err = subnetReservationPolicy(in.ReservationPolicy); err != nil {
+			return s, err
+		}
+	}
+	gateway, reserved := policy.plan(prefix)
+	place := subnetPlacement{Prefix: prefix, Kind: kind, VRFID: in.VRFID}
+
+	// Only the subnet itself is stored. Host addresses are computed from the CIDR
+	// on demand, and rows in ips are created only once an address carries state,
+	// such as the addresses reserved by the policy.
+	err = withSubnetLock(ctx, func(tx pgx.Tx) error {
+		if err := checkSubnetPlacement(ctx, tx, pgtype.UUID{}, place, nil); err != nil {
+			return err
+		}
+		if err := scanSubnet(tx.QueryRow(ctx,
+			`INSERT INTO subnets (cidr, name, kind, vrf_id, vlan_id, site_id, tenant_id, custom_fields, gateway, reservation_policy)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING `+subnetColumns,
+			prefix, in.Name, kind, in.VRFID, in.VLANID, in.SiteID, in.TenantID, customFields,
+			gatewayArg(gateway), policy.String()), &s); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, subnetAudit("create", nil, &s)); err != nil {
+			return err
+		}
+		return reserveByPolicy(ctx, tx, s.ID, reserved)
+	})
+	return s, subnetWriteError(ctx, err, place, s.ID)
+}
+
+// subnetPatch lists the subnet fields that may be changed; nil fields are left as is.
+type subnetPatch struct {
+	CIDR         *string        `json:"cidr"`
+	Name         *string        `json:"name"`
+	Kind         *string        `json:"kind"`
+	VRFID        nullableUUID   `json:"vrf_id"`        // null moves the subnet to the global table
+	VLANID       nullableUUID   `json:"vlan_id"`       // null unlinks the subnet from its VLAN
+	SiteID       nullableUUID   `json:"site_id"`       // null removes the subnet from its site
+	TenantID     nullableUUID   `json:"tenant_id"`     // null removes the subnet's tenant
+	CustomFields map[string]any `json:"custom_fields"` // merged into the current values; null removes a value
+}
+
+func updateSubnet(ctx context.Context, id string, patch subnetPatch) (models.Subnet, error) {
+	s, err := getSubnet(ctx, id)
+	if err != nil {
+		return s, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return s, errInvalid("missing_field", "name must not be empty")
+	}
+	var prefix netip.Prefix
+	if patch.CIDR != nil {
+		if prefix, err = parseSubnetCIDR(*patch.CIDR); err != nil {
+			return s, err
+		}
+	}
+	var kind string
+	if patch.Kind != nil {
+		if kind, err = parseSubnetKind(*patch.Kind); err != nil {
+			return s, err
+		}
+	}
+	if patch.VRFID.Set {
+		if err := checkVRFExists(ctx, patch.VRFID.ID); err != nil {
+			return s, err
+		}
+	}
+	if patch.VLANID.Set {
+		if err := checkVLANExists(ctx, patch.VLANID.ID); err != nil {
+			return s, err
+		}
+	}
+	if patch.SiteID.Set {
+		if err := checkSiteExists(ctx, patch.SiteID.ID); err != nil {
+			return s, err
+		}
+	}
+	if patch.TenantID.Set {
+		if err := checkTenantExists(ctx, patch.TenantID.ID); err != nil {
+			return s, err
+		}
+	}
+
+	var place subnetPlacement
+	err = withSubnetLock(ctx, func(tx pgx.Tx) error {
+		var before models.Subnet
+		if err := scanSubnet(tx.QueryRow(ctx, "SELECT "+subnetColumns+" FROM subnets WHERE id = $1 FOR UPDATE", s.ID), &before); err != nil {
+			return err
+		}
+		s = before
+		current, err := parseSubnetCIDR(s.CIDR)
+		if err != nil {
+			return err
+		}
+		old := subnetPlacement{Prefix: current, Kind: s.Kind, VRFID: s.VRFID}
+		place = old
+		if patch.CIDR != nil {
+			place.Prefix = prefix
+		}
+		if patch.Kind != nil {
+			place.Kind = kind
+		}
+		if patch.VRFID.Set {
+			place.VRFID = patch.VRFID.ID
+		}
+		if patch.Name != nil {
+			s.Name = *patch.Name
+		}
+		if patch.VLANID.Set {
+			s.VLANID = patch.VLANID.ID
+		}
+		if patch.SiteID.Set {
+			s.SiteID = patch.SiteID.ID
+		}
+		if patch.TenantID.Set {
+			s.TenantID = patch.TenantID.ID
+		}
+		if s.CustomFields, err = applyCustomFields(ctx, models.CustomFieldObjectSubnet, s.CustomFields, patch.CustomFields); err != nil {
+			return err
+		}
+		if err := checkSubnetPlacement(ctx, tx, s.ID, place, &old); err != nil {
+			return err
+		}
+		if place.Kind == models.SubnetKindContainer {
+			var hasIPs bool
+			if err := tx.QueryRow(ctx,
+				"SELECT EXISTS (SELECT 1 FROM ips WHERE subnet_id = $1) OR EXISTS (SELECT 1 FROM ip_ranges WHERE subnet_id = $1)",
+				s.ID).Scan(&hasIPs); err != nil {
+				return err
+			}
+			if hasIPs {
+				return errConflict("subnet_has_ips", "%s has recorded IP addresses or ranges and cannot become a container", place.Prefix)
+			}
+		}
+		// A gateway the new CIDR no longer contains is dropped.
+		if err := scanSubnet(tx.QueryRow(ctx,
+			`UPDATE subnets SET cidr = $2, name = $3, kind = $4, vrf_id = $5, vlan_id = $6, site_id = $7, tenant_id = $8,
+			        custom_fields = $9, gateway = CASE WHEN gateway <<= $2 AND $4 = 'network' THEN gateway END
+			 WHERE id = $1 RETURNING `+subnetColumns,
+			s.ID, place.Prefix, s.Name, place.Kind, place.VRFID, s.VLANID, s.SiteID, s.TenantID, s.CustomFields), &s); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, subnetAudit("update", &before, &s))
+	})
+	if isNoRows(err) {
+		return s, errNotFound("subnet_not_found", "Subnet not found")
+	}
+	if database.ErrorCode(err) == database.CheckViolation {
+		return s, errConflict("ips_outside_subnet", "%s does not contain every recorded IP address and range of this subnet", place.Prefix)
+	}
+	return s, subnetWriteError(ctx, err, place, s.ID)
+}
+
+// carveSubnet creates a subnet named name from the lowest free /bits block of
+// the container parentID and returns it. Concurrent carves are serialized by
+// the subnet lock, so each of them receives a different block. A carved
+// network gets the addresses reserved by the default policy.
+func carveSubnet(ctx context.Context, parentID string, bits int, name, kind string) (models.Subnet, error) {
+	var s models.Subnet
+	if name == "" {
+		return s, errInvalid("missing_field", "name is required")
+	}
+	kind, err := parseSubnetKind(kind)
+	if err != nil {
+		return s, err
+	}
+	parent, err := getSubnet(ctx, parentID)
+	if err != nil {
+		return s, err
+	}
+	if parent.Kind != models.SubnetKindContainer {
+		return s, errConflict("subnet_not_container", "%s is a network; only containers can hold child subnets", parent.CIDR)
+	}
+	parentPrefix, err := netip.ParsePrefix(parent.CIDR)
+	if err != nil {
+		return s, err
+	}
+	if bits <= parentPrefix.Bits() || bits > parentPrefix.Addr().BitLen() {
+		return s, errInvalid("invalid_prefix_length", "prefix length must be between /%d and /%d",
+			parentPrefix.Bits()+1, parentPrefix.Addr().BitLen())
+	}
+
+	// The new subnet lives in the parent's VRF and site and belongs to its tenant.
+	place := subnetPlacement{Kind: kind, VRFID: parent.VRFID}
+	err = withSubnetLock(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			"SELECT cidr FROM subnets WHERE cidr << $1 AND vrf_id IS NOT DISTINCT FROM $2 ORDER BY cidr",
+			parentPrefix, parent.VRFID)
+		if err != nil {
+			return err
+		}
+		used, err := pgx.CollectRows(rows, pgx.RowTo[netip.Prefix])
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if place.Prefix, ok = ipcalc.NextFreePrefix(parentPrefix, bits, used); !ok {
+			return errConflict("no_free_prefix", "No free /%d left in %s", bits, parent.CIDR)
+		}
+		if err := checkSubnetPlacement(ctx, tx, pgtype.UUID{}, place, nil); err != nil {
+			return err
+		}
+		var policy reservationPolicy
+		if kind == models.SubnetKindNetwork {
+			policy = defaultReservationPolicy()
+		}
+		gateway, reserved := policy.plan(place.Prefix)
+		if err := scanSubnet(tx.QueryRow(ctx,
+			`INSERT INTO subnets (cidr, name, kind, vrf_id, site_id, tenant_id, gateway, reservation_policy)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING `+subnetColumns,
+			place.Prefix, name, kind, place.VRFID, parent.SiteID, parent.TenantID,
+			gatewayArg(gateway), policy.String()), &s); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, subnetAudit("create", nil, &s)); err != nil {
+			return err
+		}
+		return reserveByPolicy(ctx, tx, s.ID, reserved)
+	})
+	return s, subnetWriteError(ctx, err, place, s.ID)
+}
+
+// withSubnetLock runs fn in a transaction holding a table lock that excludes
+// other subnet writes, so that nesting checks and the write happen atomically.
+func withSubnetLock(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "LOCK TABLE subnets IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// subnetPlacement is where a subnet sits in the address plan.
+type subnetPlacement struct {
+	Prefix netip.Prefix
+	Kind   string
+	VRFID  pgtype.UUID // invalid for the global table
+}
+
+// checkSubnetPlacement enforces the nesting rules for writing a subnet at
+// next: within a VRF every CIDR is unique and only containers enclose other
+// subnets. A subnet moved away from old must keep all of its children.
+// self is the subnet being written (invalid when creating).
+func checkSubnetPlacement(ctx context.Context, q database.Querier, self pgtype.UUID, next subnetPlacement, old *subnetPlacement) error {
+	var cidr, name, otherKind string
+
+	// The innermost existing subnet enclosing (or equal to) the prefix becomes its parent.
+	err := q.QueryRow(ctx,
+		`SELECT cidr, name, kind FROM subnets
+		 WHERE cidr >>= $1 AND vrf_id IS NOT DISTINCT FROM $2 AND id IS DISTINCT FROM $3
+		 ORDER BY masklen(cidr) DESC LIMIT 1`,
+		next.Prefix, next.VRFID, self).Scan(database.CIDR(&cidr), &name, &otherKind)
+	switch {
+	case err == nil && cidr == next.Prefix.String():
+		return errConflict("subnet_overlap", "Subnet %s (%s) already exists", cidr, name)
+	case err == nil && otherKind != models.SubnetKindContainer:
+		return errConflict("subnet_overlap", "%s overlaps existing subnet %s (%s); only containers can hold child subnets", next.Prefix, cidr, name)
+	case err != nil && !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	if next.Kind == models.SubnetKindNetwork {
+		err := q.QueryRow(ctx,
+			`SELECT cidr, name FROM subnets
+			 WHERE cidr << $1 AND vrf_id IS NOT DISTINCT FROM $2 AND id IS DISTINCT FROM $3
+			 ORDER BY masklen(cidr), cidr LIMIT 1`,
+			next.Prefix, next.VRFID, self).Scan(database.CIDR(&cidr), &name)
+		if err == nil {
+			return errConflict("subnet_overlap", "%s overlaps existing subnet %s (%s); make it a container to hold child subnets", next.Prefix, cidr, name)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+
+	if old != nil && (old.Prefix != next.Prefix || old.VRFID != next.VRFID) {
+		err := q.QueryRow(ctx,
+			`SELECT cidr, name FROM subnets
+			 WHERE cidr << $1 AND vrf_id IS NOT DISTINCT FROM $2 AND id <> $5
+			   AND NOT (cidr << $3 AND vrf_id IS NOT DISTINCT FROM $4)
+			 ORDER BY cidr LIMIT 1`,
+			old.Prefix, old.VRFID, next.Prefix, next.VRFID, self).Scan(database.CIDR(&cidr), &name)
+		if err == nil {
+			return errConflict("child_outside_subnet", "Child subnet %s (%s) would no longer fit inside %s", cidr, name, next.Prefix)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSubnet deletes a subnet together with its ranges and addresses, and
+// records each of them in the audit log.
+func deleteSubnet(ctx context.Context, id string) error {
+	s, err := getSubnet(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		// Locking the subnet row keeps addresses and ranges from being added
+		// until it is gone.
+		if err := scanSubnet(tx.QueryRow(ctx, "SELECT "+subnetColumns+" FROM subnets WHERE id = $1 FOR UPDATE", s.ID), &s); err != nil {
+			if isNoRows(err) {
+				return errNotFound("subnet_not_found", "Subnet not found")
+			}
+			return err
+		}
+		ips, err := queryIPsOn(ctx, tx, "SELECT "+ipColumns+" FROM ips WHERE subnet_id = $1 ORDER BY address", s.ID)
+		if err != nil {
+			return err
+		}
+		ranges, err := queryRangesOn(ctx, tx, "SELECT "+rangeColumns+" FROM ip_ranges WHERE subnet_id = $1 ORDER BY start_address", s.ID)
+		if err != nil {
+			return err
+		}
+		ipIDs := make([]pgtype.UUID, len(ips))
+		for i, ip := range ips {
+			ipIDs[i] = ip.ID
+		}
+		if err := recordPrimaryIPChanges(ctx, tx, ipIDs, func() error {
+			result, err := tx.Exec(ctx, "DELETE FROM subnets WHERE id = $1", s.ID)
+			if err != nil {
+				return err
+			}
+			if result.RowsAffected() == 0 {
+				return errNotFound("subnet_not_found", "Subnet not found")
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		var entries []auditEntry
+		for _, ip := range ips {
+			entries = append(entries, ipAudit("delete", ip, models.IP{}))
+		}
+		for _, rg := range ranges {
+			entries = append(entries, rangeAudit("delete", &rg, nil))
+		}
+		entries = append(entries, subnetAudit("delete", &s, nil))
+		return recordAudit(ctx, tx, entries...)
+	})
+}
+
+// subnetWriteError turns constraint violations from writing a subnet at place
+// into conflict errors that name the existing subnet. self is the subnet being
+// written (invalid when creating), which never conflicts with itself.
+func subnetWriteError(ctx context.Context, err error, place subnetPlacement, self pgtype.UUID) error {
+	if code := database.ErrorCode(err); code == database.ExclusionViolation || code == database.UniqueViolation {
+		return errConflict("subnet_overlap", "%s", overlapMessage(ctx, place, self))
+	}
+	return err
+}
+
+// overlapMessage describes which existing subnet of the same VRF conflicts with place.
+func overlapMessage(ctx context.Context, place subnetPlacement, self pgtype.UUID) string {
+	var other, name string
+	if err := database.DB.QueryRow(ctx,
+		`SELECT cidr, name FROM subnets
+		 WHERE cidr && $1 AND vrf_id IS NOT DISTINCT FROM $2 AND id IS DISTINCT FROM $3
+		 ORDER BY masklen(cidr) LIMIT 1`,
+		place.Prefix, place.VRFID, self).Scan(database.CIDR(&other), &name); err != nil {
+		return fmt.Sprintf("%s overlaps an existing subnet", place.Prefix)
+	}
+	return fmt.Sprintf("%s overlaps existing subnet %s (%s)", place.Prefix, other, name)
 }
@@ -2,67 +2,19 @@ package handlers
 
 import (
 	"context"
-	"net"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/jackc/pgx/v5/pgtype"
+
 	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
 )
 
-// --- Unit tests for pure helper functions ---
-
-func TestCloneIP(t *testing.T) {
-	original := net.IP{192, 168, 1, 1}
-	clone := cloneIP(original)
-
-	if !original.Equal(clone) {
-		t.Errorf("expected clone to equal original, got %v", clone)
-	}
-
-	// Mutating the clone should not affect the original
-	clone[3] = 99
-	if original[3] == 99 {
-		t.Error("mutating clone should not affect original")
-	}
-}
-
-func TestIncrementIP(t *testing.T) {
-	tests := []struct {
-		name   string
-		input  net.IP
-		expect net.IP
-	}{
-		{
-			name:   "simple increment",
-			input:  net.IP{192, 168, 1, 1},
-			expect: net.IP{192, 168, 1, 2},
-		},
-		{
-			name:   "carry over last octet",
-			input:  net.IP{192, 168, 1, 255},
-			expect: net.IP{192, 168, 2, 0},
-		},
-		{
-			name:   "carry over multiple octets",
-			input:  net.IP{192, 168, 255, 255},
-			expect: net.IP{192, 169, 0, 0},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ip := cloneIP(tt.input)
-			incrementIP(ip)
-			if !ip.Equal(tt.expect) {
-				t.Errorf("incrementIP(%v) = %v, want %v", tt.input, ip, tt.expect)
-			}
-		})
-	}
-}
-
 // --- HTTP handler integration tests ---
 
 func TestHandleCreateSubnet_MissingFields(t *testing.T) {
@@ -107,10 +59,11 @@ func TestHandleCreateSubnet_InvalidCIDR(t *testing.T) {
 	}
 }
 
-func TestHandleCreateSubnet_PrefixTooShort(t *testing.T) {
+func TestHandleCreateSubnet_LargePrefix(t *testing.T) {
 	cleanDB(t)
 
-	tests := []string{"10.0.0.0/8", "0.0.0.0/0", "172.16.0.0/15"}
+	// Large prefixes are accepted because host addresses are not materialized.
+	tests := []string{"10.0.0.0/8", "172.16.0.0/12"}
 	for _, cidr := range tests {
 		t.Run(cidr, func(t *testing.T) {
 			form := url.Values{"cidr": {cidr}, "name": {"test"}}
@@ -120,17 +73,25 @@ func TestHandleCreateSubnet_PrefixTooShort(t *testing.T) {
 
 			HandleCreateSubnet(w, req)
 
-			if w.Code != http.StatusBadRequest {
-				t.Errorf("CIDR %s: expected 400, got %d", cidr, w.Code)
+			if w.Code != http.StatusOK {
+				t.Errorf("CIDR %s: expected 200, got %d", cidr, w.Code)
 			}
 		})
 	}
+
+	var ipCount int
+	if err := database.DB.QueryRow(context.Background(), "SELECT COUNT(*) FROM ips").Scan(&ipCount); err != nil {
+		t.Fatalf("failed to query ips: %v", err)
+	}
+	if ipCount != 0 {
+		t.Errorf("expected no IP records, got %d", ipCount)
+	}
 }
 
 func TestHandleCreateSubnet_Success(t *testing.T) {
 	cleanDB(t)
 
-	cidr := "192.168.100.0/30" // /30: 4 addresses → 2 usable IPs
+	cidr := "192.168.100.0/30"
 	form := url.Values{"cidr": {cidr}, "name": {"test-subnet"}}
 	req := httptest.NewRequest(http.MethodPost, "/subnets", strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -152,14 +113,14 @@ func TestHandleCreateSubnet_Success(t *testing.T) {
 		t.Errorf("expected 1 subnet record, got %d", count)
 	}
 
-	// Verify IPs were inserted (2 usable IPs for /30)
+	// Verify no IP rows were materialized; available addresses are computed on demand
 	var ipCount int
-	err = database.DB.QueryRow(context.Background(), "SELECT COUNT(*) FROM ips WHERE status = 'available'").Scan(&ipCount)
+	err = database.DB.QueryRow(context.Background(), "SELECT COUNT(*) FROM ips").Scan(&ipCount)
 	if err != nil {
 		t.Fatalf("failed to query ips: %v", err)
 	}
-	if ipCount != 2 {
-		t.Errorf("expected 2 IP records for /30, got %d", ipCount)
+	if ipCount != 0 {
+		t.Errorf("expected 0 IP records, got %d", ipCount)
 	}
 }
 
@@ -196,3 +157,213 @@ func TestHandleDeleteSubnet_Success(t *testing.T) {
 		t.Errorf("expected subnet to be deleted, but found %d records", count)
 	}
 }
+
+func TestHandleCreateSubnet_Canonical(t *testing.T) {
+	cleanDB(t)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2001:DB8:0:0::/64", "2001:db8::/64"},
+		{"2001:db8:1:0:1:0:0:1/48", "2001:db8:1::/48"},
+		{"192.168.1.77/24", "192.168.1.0/24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			form := url.Values{"cidr": {tt.input}, "name": {"test"}}
+			req := httptest.NewRequest(http.MethodPost, "/subnets", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			HandleCreateSubnet(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d; body: %s", w.Code, w.Body.String())
+			}
+			var count int
+			if err := database.DB.QueryRow(context.Background(), "SELECT COUNT(*) FROM subnets WHERE cidr = $1", tt.want).Scan(&count); err != nil {
+				t.Fatalf("failed to query subnets: %v", err)
+			}
+			if count != 1 {
+				t.Errorf("expected subnet stored as %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleCreateSubnet_Overlap(t *testing.T) {
+	cleanDB(t)
+
+	if _, err := database.DB.Exec(context.Background(),
+		"INSERT INTO subnets (cidr, name) VALUES ($1, $2)", "10.0.0.0/16", "existing",
+	); err != nil {
+		t.Fatalf("failed to insert subnet: %v", err)
+	}
+
+	for _, cidr := range []string{"10.0.0.0/16", "10.0.1.0/24", "10.0.0.0/8"} {
+		t.Run(cidr, func(t *testing.T) {
+			form := url.Values{"cidr": {cidr}, "name": {"new"}}
+			req := httptest.NewRequest(http.MethodPost, "/subnets", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			HandleCreateSubnet(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("expected 409, got %d", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), "10.0.0.0/16 (existing)") {
+				t.Errorf("expected conflict message to name the existing subnet, got %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSubnetHierarchy(t *testing.T) {
+	cleanDB(t)
+	ctx := context.Background()
+
+	create := func(cidr, kind string) (models.Subnet, error) {
+		return createSubnet(ctx, models.Subnet{CIDR: cidr, Name: "net-" + cidr, Kind: kind})
+	}
+	mustCreate := func(cidr, kind string) models.Subnet {
+		t.Helper()
+		s, err := create(cidr, kind)
+		if err != nil {
+			t.Fatalf("failed to create %s %s: %v", kind, cidr, err)
+		}
+		return s
+	}
+	wantCode := func(err error, code string) {
+		t.Helper()
+		var ae *appError
+		if !errors.As(err, &ae) || ae.Code != code {
+			t.Errorf("expected error %q, got %v", code, err)
+		}
+	}
+
+	site := mustCreate("10.10.0.0/16", models.SubnetKindContainer)
+	lan := mustCreate("10.10.1.0/24", "")
+	mustCreate("10.10.2.0/24", models.SubnetKindNetwork)
+	if lan.Kind != models.SubnetKindNetwork || lan.ParentID != site.ID {
+		t.Errorf("expected network inside %s, got kind %q parent %v", site.CIDR, lan.Kind, lan.ParentID)
+	}
+
+	// A larger container created later becomes the parent of the existing one.
+	region := mustCreate("10.0.0.0/8", models.SubnetKindContainer)
+	if site, _ = getSubnet(ctx, site.ID.String()); site.ParentID != region.ID {
+		t.Errorf("expected %s to be nested in %s", site.CIDR, region.CIDR)
+	}
+
+	_, err := create("10.10.1.128/25", "")
+	wantCode(err, "subnet_overlap") // networks cannot hold child subnets
+	_, err = create("10.10.0.0/16", models.SubnetKindContainer)
+	wantCode(err, "subnet_overlap") // duplicate CIDR
+	_, err = create("10.10.0.0/20", models.SubnetKindNetwork)
+	wantCode(err, "subnet_overlap") // a network would enclose other subnets
+	_, err = create("10.10.0.0/20", "pool")
+	wantCode(err, "invalid_kind")
+
+	// Shrinking a container must keep every child inside it.
+	_, err = updateSubnet(ctx, site.ID.String(), subnetPatch{CIDR: ptr("10.10.0.0/23")})
+	wantCode(err, "child_outside_subnet")
+	if _, err := updateSubnet(ctx, site.ID.String(), subnetPatch{CIDR: ptr("10.10.0.0/22")}); err != nil {
+		t.Errorf("shrinking around all children failed: %v", err)
+	}
+
+	// Containers hold no host addresses.
+	_, err = allocateIP(ctx, site.ID.String(), models.IP{Address: "10.10.3.1"})
+	wantCode(err, "subnet_is_container")
+	if _, err := allocateIP(ctx, lan.ID.String(), models.IP{Address: "10.10.1.1"}); err != nil {
+		t.Fatalf("allocate in network failed: %v", err)
+	}
+	_, err = updateSubnet(ctx, lan.ID.String(), subnetPatch{Kind: ptr(models.SubnetKindContainer)})
+	wantCode(err, "subnet_has_ips")
+
+	// The dashboard nests the subnets.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	HandleSubnetList(w, req)
+	body := w.Body.String()
+	if strings.Count(body, `class="card `) != 1 || !strings.Contains(body, "subnet-tree") || !strings.Contains(body, "10.10.2.0/24") {
+		t.Errorf("expected a single top-level card with a subnet tree, got:\n%s", body)
+	}
+}
+
+func TestSubnetTree(t *testing.T) {
+	id := func(b byte) pgtype.UUID { return pgtype.UUID{Bytes: [16]byte{b}, Valid: true} }
+	subnets := []models.Subnet{
+		{ID: id(1), CIDR: "10.0.0.0/8"},
+		{ID: id(2), CIDR: "10.1.0.0/16", ParentID: id(1)},
+		{ID: id(3), CIDR: "10.1.1.0/24", ParentID: id(2)},
+		{ID: id(4), CIDR: "10.2.0.0/16", ParentID: id(1)},
+		{ID: id(5), CIDR: "192.168.0.0/24"},
+	}
+
+	roots := subnetTree(subnets, pgtype.UUID{})
+	if len(roots) != 2 || roots[0].CIDR != "10.0.0.0/8" || roots[1].CIDR != "192.168.0.0/24" {
+		t.Fatalf("unexpected roots: %+v", roots)
+	}
+	if c := roots[0].Children; len(c) != 2 || c[0].CIDR != "10.1.0.0/16" || len(c[0].Children) != 1 || c[1].CIDR != "10.2.0.0/16" {
+		t.Errorf("unexpected children: %+v", c)
+	}
+	if sub := subnetTree(subnets, id(2)); len(sub) != 1 || sub[0].CIDR != "10.1.1.0/24" {
+		t.Errorf("unexpected subtree: %+v", sub)
+	}
+
+	// Subnets whose parent was filtered out become top-level.
+	if filtered := subnetTree(subnets[2:], pgtype.UUID{}); len(filtered) != 3 || filtered[0].CIDR != "10.1.1.0/24" {
+		t.Errorf("unexpected filtered roots: %+v", filtered)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestHandleCarveSubnet(t *testing.T) {
+	cleanDB(t)
+	ctx := context.Background()
+
+	parent, err := createSubnet(ctx, models.Subnet{CIDR: "10.20.0.0/16", Name: "campus", Kind: models.SubnetKindContainer})
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	if _, err := createSubnet(ctx, models.Subnet{CIDR: "10.20.0.0/26", Name: "existing"}); err != nil {
+		t.Fatalf("failed to create child: %v", err)
+	}
+
+	carve := func(prefixLength string) *httptest.ResponseRecorder {
+		form := url.Values{"prefix_length": {prefixLength}, "name": {"carved"}}
+		req := httptest.NewRequest(http.MethodPost, "/subnets/"+parent.ID.String()+"/carve", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetPathValue("id", parent.ID.String())
+		w := httptest.NewRecorder()
+		HandleCarveSubnet(w, req)
+		return w
+	}
+
+	w := carve("/26")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d; body: %s", w.Code, w.Body.String())
+	}
+	var cidr string
+	if err := database.DB.QueryRow(ctx, "SELECT cidr FROM subnets WHERE name = 'carved'").Scan(database.CIDR(&cidr)); err != nil {
+		t.Fatalf("carved subnet not found: %v", err)
+	}
+	if cidr != "10.20.0.64/26" {
+		t.Errorf("expected 10.20.0.64/26, got %s", cidr)
+	}
+
+	for _, tt := range []struct {
+		prefixLength string
+		status       int
+	}{
+		{"16", http.StatusBadRequest}, // not smaller than the parent
+		{"33", http.StatusBadRequest},
+		{"abc", http.StatusBadRequest},
+	} {
+		if w := carve(tt.prefixLength); w.Code != tt.status {
+			t.Errorf("prefix length %q: expected %d, got %d", tt.prefixLength, tt.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// tagColumns is the column list scanned by scanTag.
+const tagColumns = `id, name, color, description, created_at,
+	(SELECT COUNT(*) FROM subnet_tags x WHERE x.tag_id = tags.id),
+	(SELECT COUNT(*) FROM ip_tags x WHERE x.tag_id = tags.id)`
+
+func HandleTagList(w http.ResponseWriter, r *http.Request) {
+	tags, err := listTags(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tags")
+		return
+	}
+
+	component := templates.TagList(tags)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateTag(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in := models.Tag{Name: r.FormValue("name"), Color: r.FormValue("color"), Description: r.FormValue("description")}
+	if _, err := createTag(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create tag")
+		return
+	}
+
+	HandleTagList(w, r)
+}
+
+func HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	if err := deleteTag(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete tag")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleBulkTagSubnets adds a tag to (action=tag) or removes it from
+// (action=untag) the subnets selected on the subnet list, then shows the list
+// again with the filters of the query string.
+func HandleBulkTagSubnets(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	var targets tagTargets
+	for _, s := range r.PostForm["subnet_id"] {
+		id, err := parseRefID(s, "invalid_subnet", "Invalid subnet ID")
+		if err != nil {
+			writeError(w, err, "Failed to tag subnets")
+			return
+		}
+		targets.SubnetIDs = append(targets.SubnetIDs, id)
+	}
+	if _, err := bulkTag(r.Context(), r.PostForm.Get("tag_id"), targets, r.PostForm.Get("action") != "untag"); err != nil {
+		writeError(w, err, "Failed to tag subnets")
+		return
+	}
+
+	target := "/"
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// HandleBulkTagIPs adds a tag to (action=tag) or removes it from
+// (action=untag) the addresses selected in the IP table of a subnet, then
+// shows the same page of the table again.
+func HandleBulkTagIPs(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	subnetID, err := parseRefID(r.PathValue("id"), "invalid_subnet", "Invalid subnet ID")
+	if err != nil {
+		writeError(w, err, "Failed to tag IPs")
+		return
+	}
+	var targets tagTargets
+	for _, address := range r.PostForm["address"] {
+		targets.IPs = append(targets.IPs, ipRef{SubnetID: subnetID, Address: address})
+	}
+	if _, err := bulkTag(r.Context(), r.PostForm.Get("tag_id"), targets, r.PostForm.Get("action") != "untag"); err != nil {
+		writeError(w, err, "Failed to tag IPs")
+		return
+	}
+
+	target := "/subnets/" + r.PathValue("id")
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// parseTagID parses a tag reference from a form or query; empty means no tag.
+func parseTagID(s string) (pgtype.UUID, error) {
+	return parseRefID(s, "invalid_tag", "Invalid tag ID")
+}
+
+// validateTagColor checks a tag color and returns the value to store;
+// empty means neutral.
+func validateTagColor(color string) (string, error) {
+	if color == "" {
+		return models.TagColorNeutral, nil
+	}
+	if !slices.Contains(models.TagColors, color) {
+		return "", errInvalid("invalid_color", "color must be one of neutral, primary, secondary, accent, info, success, warning or error")
+	}
+	return color, nil
+}
+
+// scanTag scans a row selected with tagColumns.
+func scanTag(row interface{ Scan(...any) error }, t *models.Tag) error {
+	return row.Scan(&t.ID, &t.Name, &t.Color, &t.Description, &t.CreatedAt, &t.SubnetCount, &t.IPCount)
+}
+
+func listTags(ctx context.Context) ([]models.Tag, error) {
+	rows, err := database.DB.Query(ctx, "SELECT "+tagColumns+" FROM tags ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var t models.Tag
+		if err := scanTag(rows, &t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func getTag(ctx context.Context, id string) (models.Tag, error) {
+	var t models.Tag
+	err := scanTag(database.DB.QueryRow(ctx, "SELECT "+tagColumns+" FROM tags WHERE id = $1", id), &t)
+	if isNoRows(err) {
+		return t, errNotFound("tag_not_found", "Tag not found")
+	}
+	return t, err
+}
+
+func createTag(ctx context.Context, in models.Tag) (models.Tag, error) {
+	var t models.Tag
+	if in.Name == "" {
+		return t, errInvalid("missing_field", "name is required")
+	}
+	color, err := validateTagColor(in.Color)
+	if err != nil {
+		return t, err
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanTag(tx.QueryRow(ctx,
+			"INSERT INTO tags (name, color, description) VALUES ($1, $2, $3) RETURNING "+tagColumns,
+			in.Name, color, in.Description), &t); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, tagAudit("create", nil, &t))
+	})
+	return t, uniqueWriteError(err, "tag_exists", "A tag with this name already exists")
+}
+
+// tagPatch lists the tag fields that may be changed; nil fields are left as is.
+type tagPatch struct {
+	Name        *string `json:"name"`
+	Color       *string `json:"color"`
+	Description *string `json:"description"`
+}
+
+func updateTag(ctx context.Context, id string, patch tagPatch) (models.Tag, error) {
+	t, err := getTag(ctx, id)
+	if err != nil {
+		return t, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return t, errInvalid("missing_field", "name must not be empty")
+	}
+	var color string
+	if patch.Color != nil {
+		if color, err = validateTagColor(*patch.Color); err != nil {
+			return t, err
+		}
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Tag
+		if err := scanTag(tx.QueryRow(ctx, "SELECT "+tagColumns+" FROM tags WHERE id = $1 FOR UPDATE", t.ID), &before); err != nil {
+			return err
+		}
+		t = before
+		if patch.Name != nil {
+			t.Name = *patch.Name
+		}
+		if patch.Color != nil {
+			t.Color = color
+		}
+		if patch.Description != nil {
+			t.Description = *patch.Description
+		}
+		if err := scanTag(tx.QueryRow(ctx,
+			"UPDATE tags SET name = $2, color = $3, description = $4 WHERE id = $1 RETURNING "+tagColumns,
+			t.ID, t.Name, t.Color, t.Description), &t); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, tagAudit("update", &before, &t))
+	})
+	if isNoRows(err) {
+		return t, errNotFound("tag_not_found", "Tag not found")
+	}
+	return t, uniqueWriteError(err, "tag_exists", "A tag with this name already exists")
+}
+
+// deleteTag removes a tag from everything it is attached to and deletes it.
+// Each subnet and address that loses the tag is recorded as changed.
+func deleteTag(ctx context.Context, id string) error {
+	t, err := getTag(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		subnetIDs, err := queryIDs(ctx, tx, "SELECT subnet_id FROM subnet_tags WHERE tag_id = $1", t.ID)
+		if err != nil {
+			return err
+		}
+		ipIDs, err := queryIDs(ctx, tx, "SELECT ip_id FROM ip_tags WHERE tag_id = $1", t.ID)
+		if err != nil {
+			return err
+		}
+		if err := recordChanges(ctx, tx, subnetIDs, subnetSnapshots, subnetAudit, func() error {
+			return recordChanges(ctx, tx, ipIDs, ipSnapshots, ipUpdateAudit, func() error {
+				_, err := tx.Exec(ctx, "DELETE FROM tags WHERE id = $1", t.ID)
+				return err
+			})
+		}); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, tagAudit("delete", &t, nil))
+	})
+}
+
+// tagAudit describes a change to a tag; before is nil when it was created
+// and after is nil when it was deleted.
+func tagAudit(action string, before, after *models.Tag) auditEntry {
+	t := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectTag, t.ID, t.Name, before, after)
+}
+
+// ipRef names an address of a subnet.
+type ipRef struct {
+	SubnetID pgtype.UUID `json:"subnet_id"`
+	Address  string      `json:"address"`
+}
+
+// tagTargets lists the subnets and addresses a tag is added to or removed from.
+type tagTargets struct {
+	SubnetIDs []pgtype.UUID `json:"subnet_ids"`
+	IPs       []ipRef       `json:"ips"`
+}
+
+// bulkTag adds the tag tagID to every target (remove when add is false) and
+// returns the tag with its new counts. Only allocated and reserved addresses
+// can carry tags. Targets that already have (or lack) the tag are skipped.
+func bulkTag(ctx context.Context, tagID string, targets tagTargets, add bool) (models.Tag, error) {
+	if tagID == "" {
+		return models.Tag{}, errInvalid("missing_field", "tag is required")
+	}
+	t, err := getTag(ctx, tagID)
+	if err != nil {
+		return t, err
+	}
+	if len(targets.SubnetIDs) == 0 && len(targets.IPs) == 0 {
+		return t, errInvalid("missing_field", "Select at least one subnet or address")
+	}
+
+	var ipIDs []pgtype.UUID
+	for _, ref := range targets.IPs {
+		ip, err := getIP(ctx, ref.SubnetID.String(), ref.Address)
+		if err != nil {
+			return t, err
+		}
+		if ip.Status == "available" {
+			return t, errConflict("ip_not_in_use", "%s is not allocated or reserved and cannot be tagged", ip.Address)
+		}
+		ipIDs = append(ipIDs, ip.ID)
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return t, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Each target is recorded with its tags before and after, so that its
+	// history can be replayed; only those whose tags changed are recorded.
+	subnetsBefore, err := subnetSnapshots(ctx, tx, targets.SubnetIDs)
+	if err != nil {
+		return t, err
+	}
+	ipsBefore, err := ipSnapshots(ctx, tx, ipIDs)
+	if err != nil {
+		return t, err
+	}
+	var subnetIDs, taggedIPs []pgtype.UUID
+	if add {
+		subnetIDs, err = queryIDs(ctx, tx,
+			"INSERT INTO subnet_tags (subnet_id, tag_id) SELECT unnest($1::uuid[]), $2 ON CONFLICT DO NOTHING RETURNING subnet_id",
+			targets.SubnetIDs, t.ID)
+		if database.ErrorCode(err) == database.ForeignKeyViolation {
+			return t, errInvalid("subnet_not_found", "Subnet not found")
+		}
+		if err != nil {
+			return t, err
+		}
+		taggedIPs, err = queryIDs(ctx, tx,
+			"INSERT INTO ip_tags (ip_id, tag_id) SELECT unnest($1::uuid[]), $2 ON CONFLICT DO NOTHING RETURNING ip_id",
+			ipIDs, t.ID)
+		if database.ErrorCode(err) == database.ForeignKeyViolation {
+			return t, errIPChanged()
+		}
+	} else {
+		if subnetIDs, err = queryIDs(ctx, tx,
+			"DELETE FROM subnet_tags WHERE tag_id = $2 AND subnet_id = ANY($1) RETURNING subnet_id", targets.SubnetIDs, t.ID); err != nil {
+			return t, err
+		}
+		taggedIPs, err = queryIDs(ctx, tx, "DELETE FROM ip_tags WHERE tag_id = $2 AND ip_id = ANY($1) RETURNING ip_id", ipIDs, t.ID)
+	}
+	if err != nil {
+		return t, err
+	}
+
+	action := "untag"
+	if add {
+		action = "tag"
+	}
+	subnetsAfter, err := subnetSnapshots(ctx, tx, subnetIDs)
+	if err != nil {
+		return t, err
+	}
+	ipsAfter, err := ipSnapshots(ctx, tx, taggedIPs)
+	if err != nil {
+		return t, err
+	}
+	var entries []auditEntry
+	for _, id := range subnetIDs {
+		before, after := subnetsBefore[id], subnetsAfter[id]
+		entries = append(entries, subnetAudit(action, &before, &after))
+	}
+	for _, id := range taggedIPs {
+		entries = append(entries, ipAudit(action, ipsBefore[id], ipsAfter[id]))
+	}
+	if err := recordAudit(ctx, tx, entries...); err != nil {
+		return t, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return t, err
+	}
+	return getTag(ctx, tagID)
+}
+
+// queryIDs runs a query returning one UUID column on q and collects it.
+func queryIDs(ctx context.Context, q database.Querier, sql string, args ...any) ([]pgtype.UUID, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[pgtype.UUID])
+}
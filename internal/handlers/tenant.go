@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// tenantColumns is the column list scanned by scanTenant.
+const tenantColumns = `id, name, description, created_at,
+	(SELECT COUNT(*) FROM subnets s WHERE s.tenant_id = tenants.id)`
+
+// HandleTenantList shows the tenants with their address usage.
+func HandleTenantList(w http.ResponseWriter, r *http.Request) {
+	tenants, err := listTenants(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tenants")
+		return
+	}
+	usage, err := tenantUsage(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch tenant usage")
+		return
+	}
+
+	component := templates.TenantList(tenants, usage)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in := models.Tenant{Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if _, err := createTenant(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create tenant")
+		return
+	}
+
+	HandleTenantList(w, r)
+}
+
+func HandleDeleteTenant(w http.ResponseWriter, r *http.Request) {
+	if err := deleteTenant(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete tenant")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseTenantID parses a tenant reference from a form or query; empty means no tenant.
+func parseTenantID(s string) (pgtype.UUID, error) {
+	return parseRefID(s, "invalid_tenant", "Invalid tenant ID")
+}
+
+// checkTenantExists reports an error if id refers to a tenant that does not
+// exist. The invalid ID (no tenant) always exists.
+func checkTenantExists(ctx context.Context, id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM tenants WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("tenant_not_found", "Tenant not found")
+	}
+	return nil
+}
+
+// scanTenant scans a row selected with tenantColumns.
+func scanTenant(row interface{ Scan(...any) error }, t *models.Tenant) error {
+	return row.Scan(&t.ID, &t.Name, &t.Description, &t.CreatedAt, &t.SubnetCount)
+}
+
+func listTenants(ctx context.Context) ([]models.Tenant, error) {
+	rows, err := database.DB.Query(ctx, "SELECT "+tenantColumns+" FROM tenants ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var t models.Tenant
+		if err := scanTenant(rows, &t); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+func getTenant(ctx context.Context, id string) (models.Tenant, error) {
+	var t models.Tenant
+	err := scanTenant(database.DB.QueryRow(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1", id), &t)
+	if isNoRows(err) {
+		return t, errNotFound("tenant_not_found", "Tenant not found")
+	}
+	return t, err
+}
+
+func createTenant(ctx context.Context, in models.Tenant) (models.Tenant, error) {
+	var t models.Tenant
+	if in.Name == "" {
+		return t, errInvalid("missing_field", "name is required")
+	}
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanTenant(tx.QueryRow(ctx,
+			"INSERT INTO tenants (name, description) VALUES ($1, $2) RETURNING "+tenantColumns,
+			in.Name, in.Description), &t); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, tenantAudit("create", nil, &t))
+	})
+	return t, uniqueWriteError(err, "tenant_exists", "A tenant with this name already exists")
+}
+
+// tenantPatch lists the tenant fields that may be changed; nil fields are left as is.
+type tenantPatch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+func updateTenant(ctx context.Context, id string, patch tenantPatch) (models.Tenant, error) {
+	t, err := getTenant(ctx, id)
+	if err != nil {
+		return t, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return t, errInvalid("missing_field", "name must not be empty")
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.Tenant
+		if err := scanTenant(tx.QueryRow(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1 FOR UPDATE", t.ID), &before); err != nil {
+			return err
+		}
+		t = before
+		if patch.Name != nil {
+			t.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			t.Description = *patch.Description
+		}
+		if err := scanTenant(tx.QueryRow(ctx,
+			"UPDATE tenants SET name = $2, description = $3 WHERE id = $1 RETURNING "+tenantColumns,
+			t.ID, t.Name, t.Description), &t); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, tenantAudit("update", &before, &t))
+	})
+	if isNoRows(err) {
+		return t, errNotFound("tenant_not_found", "Tenant not found")
+	}
+	return t, uniqueWriteError(err, "tenant_exists", "A tenant with this name already exists")
+}
+
+// deleteTenant removes a tenant that no longer owns any subnets or addresses.
+func deleteTenant(ctx context.Context, id string) error {
+	t, err := getTenant(ctx, id)
+	if err != nil {
+		return err
+	}
+	if t.SubnetCount > 0 {
+		return errConflict("tenant_in_use", "Tenant %s still owns %d subnets", t.Name, t.SubnetCount)
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM tenants WHERE id = $1", t.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, tenantAudit("delete", &t, nil))
+	})
+	if database.ErrorCode(err) == database.ForeignKeyViolation {
+		return errConflict("tenant_in_use", "Tenant %s still owns IP addresses", t.Name)
+	}
+	return err
+}
+
+// tenantAudit describes a change to a tenant; before is nil when it was
+// created and after is nil when it was deleted.
+func tenantAudit(action string, before, after *models.Tenant) auditEntry {
+	t := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectTenant, t.ID, t.Name, before, after)
+}
+
+// tenantUsage counts the allocated and reserved addresses of every tenant,
+// ordered by tenant name. An address counts for its own tenant, or else for
+// the tenant of its subnet. A last entry without tenant ID covers the subnets
+// and addresses nobody owns.
+func tenantUsage(ctx context.Context) ([]models.TenantUsage, error) {
+	tenants, err := listTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unowned := models.TenantUsage{TenantName: "Unassigned"}
+	if err := database.DB.QueryRow(ctx,
+		"SELECT COUNT(*) FROM subnets WHERE tenant_id IS NULL").Scan(&unowned.Subnets); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(ctx,
+		`SELECT COALESCE(i.tenant_id, s.tenant_id),
+		        COUNT(*) FILTER (WHERE i.status = 'allocated'),
+		        COUNT(*) FILTER (WHERE i.status = 'reserved')
+		 FROM ips i JOIN subnets s ON s.id = i.subnet_id
+		 WHERE i.status <> 'available'
+		 GROUP BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[pgtype.UUID]models.TenantUsage)
+	for rows.Next() {
+		var id pgtype.UUID
+		var u models.TenantUsage
+		if err := rows.Scan(&id, &u.Allocated, &u.Reserved); err != nil {
+			return nil, err
+		}
+		counts[id] = u
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	usage := make([]models.TenantUsage, 0, len(tenants)+1)
+	for _, t := range tenants {
+		u := counts[t.ID]
+		u.TenantID, u.TenantName, u.Subnets = t.ID, t.Name, t.SubnetCount
+		usage = append(usage, u)
+	}
+	u := counts[pgtype.UUID{}]
+	unowned.Allocated, unowned.Reserved = u.Allocated, u.Reserved
+	return append(usage, unowned), nil
+}
@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/testcontainers/testcontainers-go"
@@ -52,13 +57,9 @@ func TestMain(m *testing.M) {
 	}
 	defer database.Close()
 
-	// Apply schema
-	schema, err := os.ReadFile("../../internal/database/schema.sql")
-	if err != nil {
-		log.Fatalf("failed to read schema.sql: %v", err)
-	}
-	if _, err := database.DB.Exec(ctx, string(schema)); err != nil {
-		log.Fatalf("failed to apply schema: %v", err)
+	// Apply schema using the same migrations as the server
+	if _, err := database.MigrateUp(ctx); err != nil {
+		log.Fatalf("failed to apply migrations: %v", err)
 	}
 
 	// Run tests
@@ -68,8 +69,34 @@ func TestMain(m *testing.M) {
 // cleanDB truncates all tables to ensure a clean state for each test.
 func cleanDB(t *testing.T) {
 	t.Helper()
-	_, err := database.DB.Exec(context.Background(), "TRUNCATE TABLE ips, subnets RESTART IDENTITY CASCADE")
+	_, err := database.DB.Exec(context.Background(), "TRUNCATE TABLE ips, subnets, vrfs, vlans, vlan_groups, locations, sites, regions, tenants, tags, subnet_tags, ip_tags, custom_fields, devices, interfaces, ip_ranges, ip_expirations, audit_log RESTART IDENTITY CASCADE")
 	if err != nil {
 		t.Fatalf("failed to clean database: %v", err)
 	}
 }
+
+// serveAPI calls an API handler with an optional JSON body and path values
+// given as name/value pairs, and returns the recorded response.
+func serveAPI(t *testing.T, h http.HandlerFunc, method, target, body string, pathValues ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		req.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, req)
+	return w
+}
+
+// decodeBody unmarshals a JSON response body into v.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+}
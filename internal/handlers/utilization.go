@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"math/big"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/ipcalc"
+	"github.com/ttani03/goth-ipam/internal/models"
+)
+
+// Default utilization thresholds in percent.
+const (
+	defaultWarningThreshold  = 80
+	defaultCriticalThreshold = 95
+)
+
+// thresholds are the utilization percentages from which a subnet is shown
+// as nearly full (Warning) or full (Critical).
+type thresholds struct {
+	Warning, Critical float64
+}
+
+// utilizationThresholds reads the thresholds once from UTILIZATION_WARNING
+// and UTILIZATION_CRITICAL. Missing or invalid values fall back to the
+// defaults.
+var utilizationThresholds = sync.OnceValue(func() thresholds {
+	t := thresholds{Warning: defaultWarningThreshold, Critical: defaultCriticalThreshold}
+	for name, v := range map[string]*float64{"UTILIZATION_WARNING": &t.Warning, "UTILIZATION_CRITICAL": &t.Critical} {
+		s := os.Getenv(name)
+		if s == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(s, 64)
+		if err != nil || p <= 0 || p > 100 {
+			log.Printf("Ignoring %s=%q: expected a percentage between 0 and 100", name, s)
+			continue
+		}
+		*v = p
+	}
+	if t.Warning > t.Critical {
+		log.Printf("Ignoring utilization thresholds: warning %g%% is above critical %g%%", t.Warning, t.Critical)
+		t = thresholds{Warning: defaultWarningThreshold, Critical: defaultCriticalThreshold}
+	}
+	return t
+})
+
+// level classifies a utilization percentage.
+func (t thresholds) level(percent float64) string {
+	switch {
+	case percent >= t.Critical:
+		return models.UtilizationCritical
+	case percent >= t.Warning:
+		return models.UtilizationWarning
+	}
+	return models.UtilizationOK
+}
+
+// fillSubnetStats loads the Stats of subnets. A network counts its own
+// addresses; a container those of the networks nested inside it.
+func fillSubnetStats(ctx context.Context, subnets []models.Subnet) error {
+	if len(subnets) == 0 {
+		return nil
+	}
+	ids := make([]pgtype.UUID, len(subnets))
+	for i, s := range subnets {
+		ids[i] = s.ID
+	}
+	rows, err := database.DB.Query(ctx,
+		`SELECT s.id, n.cidr,
+		   (SELECT COUNT(*) FROM ips i WHERE i.subnet_id = n.id AND i.status = 'allocated'),
+		   (SELECT COUNT(*) FROM ips i WHERE i.subnet_id = n.id AND i.status = 'reserved')
+		 FROM subnets s JOIN subnets n
+		   ON n.kind = $2 AND n.vrf_id IS NOT DISTINCT FROM s.vrf_id
+		  AND (n.id = s.id OR (s.kind = $3 AND n.cidr << s.cidr))
+		 WHERE s.id = ANY($1)`,
+		ids, models.SubnetKindNetwork, models.SubnetKindContainer)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stats := make(map[pgtype.UUID]*models.SubnetStats, len(subnets))
+	for _, id := range ids {
+		stats[id] = &models.SubnetStats{Total: new(big.Int)}
+	}
+	for rows.Next() {
+		var id pgtype.UUID
+		var prefix netip.Prefix
+		var allocated, reserved int64
+		if err := rows.Scan(&id, &prefix, &allocated, &reserved); err != nil {
+			return err
+		}
+		st := stats[id]
+		st.Allocated += allocated
+		st.Reserved += reserved
+		st.Total.Add(st.Total, ipcalc.HostCount(prefix))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	t := utilizationThresholds()
+	for i := range subnets {
+		st := stats[subnets[i].ID]
+		t.finish(st)
+		subnets[i].Stats = st
+	}
+	return nil
+}
+
+// finish derives Available, Percent and Level of st from its counts and Total.
+func (t thresholds) finish(st *models.SubnetStats) {
+	st.Available = new(big.Int).Sub(st.Total, big.NewInt(st.Allocated+st.Reserved))
+	if st.Available.Sign() < 0 {
+		st.Available.SetInt64(0)
+	}
+	st.Percent = st.Utilization().Percent()
+	st.Level = t.level(st.Percent)
+}
+
+// byUtilization orders subnets fullest first, for slices.SortStableFunc.
+// Subnets without stats sort last.
+func byUtilization(a, b models.Subnet) int {
+	return cmp.Compare(percentOf(b.Stats), percentOf(a.Stats))
+}
+
+// percentOf returns the utilization of st, -1 when it is not loaded.
+func percentOf(st *models.SubnetStats) float64 {
+	if st == nil {
+		return -1
+	}
+	return st.Percent
+}
+
+// sortUtilization orders subnet lists fullest first instead of by VRF and CIDR.
+const sortUtilization = "utilization"
+
+// parseSubnetSort checks the sort parameter of the subnet lists.
+func parseSubnetSort(s string) (string, error) {
+	if s != "" && s != sortUtilization {
+		return "", errInvalid("invalid_sort", "sort must be %s or empty", sortUtilization)
+	}
+	return s, nil
+}
+
+// subnetWithStats returns s with its Stats loaded.
+func subnetWithStats(ctx context.Context, s models.Subnet) (models.Subnet, error) {
+	one := []models.Subnet{s}
+	err := fillSubnetStats(ctx, one)
+	return one[0], err
+}
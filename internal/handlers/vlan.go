@@ -0,0 +1,611 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// vlanGroupColumns is the column list scanned by scanVLANGroup.
+const vlanGroupColumns = `id, name, description, created_at,
+	(SELECT COUNT(*) FROM vlans v WHERE v.group_id = vlan_groups.id)`
+
+// vlanColumns is the column list scanned by scanVLAN.
+const vlanColumns = `id, vid, name, group_id,
+	COALESCE((SELECT g.name FROM vlan_groups g WHERE g.id = vlans.group_id), ''),
+	site_id, COALESCE((SELECT st.name FROM sites st WHERE st.id = vlans.site_id), ''),
+	status, description, created_at,
+	(SELECT COUNT(*) FROM subnets s WHERE s.vlan_id = vlans.id)`
+
+func HandleVLANList(w http.ResponseWriter, r *http.Request) {
+	filter := vlanFilter{Group: r.URL.Query().Get("group")}
+	vlans, err := listVLANs(r.Context(), filter)
+	if err != nil {
+		writeError(w, err, "Failed to fetch VLANs")
+		return
+	}
+	groups, err := listVLANGroups(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch VLAN groups")
+		return
+	}
+	sites, err := listSites(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch sites")
+		return
+	}
+
+	component := templates.VLANList(vlans, groups, sites, filter.Group)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateVLAN(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in, err := vlanFromForm(r)
+	if err != nil {
+		writeError(w, err, "Failed to create VLAN")
+		return
+	}
+	if _, err := createVLAN(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create VLAN")
+		return
+	}
+
+	// Return updated list
+	HandleVLANList(w, r)
+}
+
+func HandleCreateVLANGroup(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in := models.VLANGroup{Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if _, err := createVLANGroup(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create VLAN group")
+		return
+	}
+
+	HandleVLANList(w, r)
+}
+
+func HandleDeleteVLAN(w http.ResponseWriter, r *http.Request) {
+	if err := deleteVLAN(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete VLAN")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleVLANDetail shows a VLAN with its subnets and a form to edit it.
+func HandleVLANDetail(w http.ResponseWriter, r *http.Request) {
+	v, err := getVLAN(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to fetch VLAN")
+		return
+	}
+	groups, err := listVLANGroups(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch VLAN groups")
+		return
+	}
+	sites, err := listSites(r.Context(), "")
+	if err != nil {
+		writeError(w, err, "Failed to fetch sites")
+		return
+	}
+	subnets, err := querySubnets(r.Context(),
+		"SELECT "+subnetColumns+" FROM subnets WHERE vlan_id = $1 ORDER BY vrf_id NULLS FIRST, cidr", v.ID)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+	// Networks not yet linked to any VLAN are offered in the link form.
+	unlinked, err := querySubnets(r.Context(),
+		"SELECT "+subnetColumns+" FROM subnets WHERE vlan_id IS NULL AND kind = $1 ORDER BY vrf_id NULLS FIRST, cidr",
+		models.SubnetKindNetwork)
+	if err != nil {
+		writeError(w, err, "Failed to fetch subnets")
+		return
+	}
+
+	component := templates.VLANDetail(v, groups, sites, subnets, unlinked)
+	component.Render(r.Context(), w)
+}
+
+// HandleUpdateVLAN saves the edit form of the VLAN detail page.
+func HandleUpdateVLAN(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	in, err := vlanFromForm(r)
+	if err != nil {
+		writeError(w, err, "Failed to update VLAN")
+		return
+	}
+	patch := vlanPatch{
+		VID:         &in.VID,
+		Name:        &in.Name,
+		GroupID:     nullableUUID{Set: true, ID: in.GroupID},
+		SiteID:      nullableUUID{Set: true, ID: in.SiteID},
+		Status:      &in.Status,
+		Description: &in.Description,
+	}
+	if _, err := updateVLAN(r.Context(), r.PathValue("id"), patch); err != nil {
+		writeError(w, err, "Failed to update VLAN")
+		return
+	}
+
+	HandleVLANDetail(w, r)
+}
+
+// HandleLinkVLANSubnet links the subnet chosen on the VLAN detail page to the VLAN.
+func HandleLinkVLANSubnet(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	vlanID, err := parseVLANID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err, "Failed to link subnet")
+		return
+	}
+	patch := subnetPatch{VLANID: nullableUUID{Set: true, ID: vlanID}}
+	if _, err := updateSubnet(r.Context(), r.FormValue("subnet_id"), patch); err != nil {
+		writeError(w, err, "Failed to link subnet")
+		return
+	}
+
+	HandleVLANDetail(w, r)
+}
+
+// HandleUnlinkVLANSubnet removes a subnet from the VLAN; the subnet itself is kept.
+func HandleUnlinkVLANSubnet(w http.ResponseWriter, r *http.Request) {
+	s, err := getSubnet(r.Context(), r.PathValue("subnetID"))
+	if err != nil {
+		writeError(w, err, "Failed to unlink subnet")
+		return
+	}
+	if s.VLANID.String() != r.PathValue("id") {
+		writeError(w, errNotFound("subnet_not_found", "Subnet is not linked to this VLAN"), "Failed to unlink subnet")
+		return
+	}
+	if _, err := updateSubnet(r.Context(), s.ID.String(), subnetPatch{VLANID: nullableUUID{Set: true}}); err != nil {
+		writeError(w, err, "Failed to unlink subnet")
+		return
+	}
+
+	HandleVLANDetail(w, r)
+}
+
+// vlanFromForm reads the fields of the VLAN create and edit forms.
+func vlanFromForm(r *http.Request) (models.VLAN, error) {
+	in := models.VLAN{
+		Name:        r.FormValue("name"),
+		Status:      r.FormValue("status"),
+		Description: r.FormValue("description"),
+	}
+	vid, err := strconv.Atoi(r.FormValue("vid"))
+	if err != nil {
+		return in, errInvalid("invalid_vid", "VID must be a number between 1 and 4094")
+	}
+	in.VID = vid
+	if in.GroupID, err = parseVLANGroupID(r.FormValue("group_id")); err != nil {
+		return in, err
+	}
+	in.SiteID, err = parseSiteID(r.FormValue("site_id"))
+	return in, err
+}
+
+// scanVLANGroup scans a row selected with vlanGroupColumns.
+func scanVLANGroup(row interface{ Scan(...any) error }, g *models.VLANGroup) error {
+	return row.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.VLANCount)
+}
+
+// scanVLAN scans a row selected with vlanColumns.
+func scanVLAN(row interface{ Scan(...any) error }, v *models.VLAN) error {
+	return row.Scan(&v.ID, &v.VID, &v.Name, &v.GroupID, &v.GroupName, &v.SiteID, &v.SiteName, &v.Status, &v.Description, &v.CreatedAt, &v.SubnetCount)
+}
+
+func listVLANGroups(ctx context.Context) ([]models.VLANGroup, error) {
+	rows, err := database.DB.Query(ctx, "SELECT "+vlanGroupColumns+" FROM vlan_groups ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.VLANGroup
+	for rows.Next() {
+		var g models.VLANGroup
+		if err := scanVLANGroup(rows, &g); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func getVLANGroup(ctx context.Context, id string) (models.VLANGroup, error) {
+	var g models.VLANGroup
+	err := scanVLANGroup(database.DB.QueryRow(ctx, "SELECT "+vlanGroupColumns+" FROM vlan_groups WHERE id = $1", id), &g)
+	if isNoRows(err) {
+		return g, errNotFound("vlan_group_not_found", "VLAN group not found")
+	}
+	return g, err
+}
+
+// parseVLANGroupID parses a VLAN group reference from a form or query; empty means no group.
+func parseVLANGroupID(s string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if s == "" {
+		return id, nil
+	}
+	if err := id.Scan(s); err != nil {
+		return id, errInvalid("invalid_vlan_group", "Invalid VLAN group ID")
+	}
+	return id, nil
+}
+
+func createVLANGroup(ctx context.Context, in models.VLANGroup) (models.VLANGroup, error) {
+	var g models.VLANGroup
+	if in.Name == "" {
+		return g, errInvalid("missing_field", "name is required")
+	}
+	err := pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanVLANGroup(tx.QueryRow(ctx,
+			"INSERT INTO vlan_groups (name, description) VALUES ($1, $2) RETURNING "+vlanGroupColumns,
+			in.Name, in.Description), &g); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vlanGroupAudit("create", nil, &g))
+	})
+	return g, vlanGroupWriteError(err)
+}
+
+// vlanGroupPatch lists the VLAN group fields that may be changed; nil fields are left as is.
+type vlanGroupPatch struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+func updateVLANGroup(ctx context.Context, id string, patch vlanGroupPatch) (models.VLANGroup, error) {
+	g, err := getVLANGroup(ctx, id)
+	if err != nil {
+		return g, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return g, errInvalid("missing_field", "name must not be empty")
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.VLANGroup
+		if err := scanVLANGroup(tx.QueryRow(ctx, "SELECT "+vlanGroupColumns+" FROM vlan_groups WHERE id = $1 FOR UPDATE", g.ID), &before); err != nil {
+			return err
+		}
+		g = before
+		if patch.Name != nil {
+			g.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			g.Description = *patch.Description
+		}
+		if err := scanVLANGroup(tx.QueryRow(ctx,
+			"UPDATE vlan_groups SET name = $2, description = $3 WHERE id = $1 RETURNING "+vlanGroupColumns,
+			g.ID, g.Name, g.Description), &g); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vlanGroupAudit("update", &before, &g))
+	})
+	if isNoRows(err) {
+		return g, errNotFound("vlan_group_not_found", "VLAN group not found")
+	}
+	return g, vlanGroupWriteError(err)
+}
+
+// deleteVLANGroup removes a VLAN group that no longer holds any VLANs.
+func deleteVLANGroup(ctx context.Context, id string) error {
+	g, err := getVLANGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+	if g.VLANCount > 0 {
+		return errConflict("vlan_group_in_use", "VLAN group %s still holds %d VLANs", g.Name, g.VLANCount)
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM vlan_groups WHERE id = $1", g.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vlanGroupAudit("delete", &g, nil))
+	})
+	if database.ErrorCode(err) == database.ForeignKeyViolation {
+		return errConflict("vlan_group_in_use", "VLAN group %s still holds VLANs", g.Name)
+	}
+	return err
+}
+
+// vlanGroupAudit describes a change to a VLAN group; before is nil when it
+// was created and after is nil when it was deleted.
+func vlanGroupAudit(action string, before, after *models.VLANGroup) auditEntry {
+	g := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectVLANGroup, g.ID, g.Name, before, after)
+}
+
+// vlanGroupWriteError turns unique violations into a conflict error.
+func vlanGroupWriteError(err error) error {
+	if database.ErrorCode(err) == database.UniqueViolation {
+		return errConflict("vlan_group_exists", "A VLAN group with this name already exists")
+	}
+	return err
+}
+
+// vlanFilter narrows down listVLANs; zero fields do not filter.
+type vlanFilter struct {
+	Group  string // VLAN group ID, or "none" for VLANs outside any group
+	Status string
+}
+
+// where returns the SQL condition selecting the filtered VLANs and its arguments.
+func (f vlanFilter) where() (string, []any, error) {
+	conds := []string{"TRUE"}
+	var args []any
+	switch f.Group {
+	case "":
+	case "none":
+		conds = append(conds, "group_id IS NULL")
+	default:
+		id, err := parseVLANGroupID(f.Group)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, id)
+		conds = append(conds, fmt.Sprintf("group_id = $%d", len(args)))
+	}
+	if f.Status != "" {
+		status, err := parseVLANStatus(f.Status)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+func listVLANs(ctx context.Context, f vlanFilter) ([]models.VLAN, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := database.DB.Query(ctx,
+		"SELECT "+vlanColumns+" FROM vlans WHERE "+where+
+			" ORDER BY (SELECT g.name FROM vlan_groups g WHERE g.id = vlans.group_id) NULLS FIRST, vid", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vlans []models.VLAN
+	for rows.Next() {
+		var v models.VLAN
+		if err := scanVLAN(rows, &v); err != nil {
+			return nil, err
+		}
+		vlans = append(vlans, v)
+	}
+	return vlans, rows.Err()
+}
+
+func getVLAN(ctx context.Context, id string) (models.VLAN, error) {
+	var v models.VLAN
+	err := scanVLAN(database.DB.QueryRow(ctx, "SELECT "+vlanColumns+" FROM vlans WHERE id = $1", id), &v)
+	if isNoRows(err) {
+		return v, errNotFound("vlan_not_found", "VLAN not found")
+	}
+	return v, err
+}
+
+// parseVLANID parses a VLAN reference from a form; empty means no VLAN.
+func parseVLANID(s string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if s == "" {
+		return id, nil
+	}
+	if err := id.Scan(s); err != nil {
+		return id, errInvalid("invalid_vlan", "Invalid VLAN ID")
+	}
+	return id, nil
+}
+
+// checkVLANExists reports an error if id refers to a VLAN that does not
+// exist. The invalid ID (no VLAN) always exists.
+func checkVLANExists(ctx context.Context, id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM vlans WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("vlan_not_found", "VLAN not found")
+	}
+	return nil
+}
+
+// checkVLANGroupExists is checkVLANExists for VLAN groups.
+func checkVLANGroupExists(ctx context.Context, id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM vlan_groups WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("vlan_group_not_found", "VLAN group not found")
+	}
+	return nil
+}
+
+// parseVLANStatus validates a VLAN status; empty means active.
+func parseVLANStatus(status string) (string, error) {
+	switch status {
+	case "":
+		return models.VLANStatusActive, nil
+	case models.VLANStatusActive, models.VLANStatusReserved, models.VLANStatusDeprecated:
+		return status, nil
+	}
+	return "", errInvalid("invalid_status", "status must be active, reserved or deprecated")
+}
+
+// validateVLAN checks the fields of a VLAN about to be written and returns
+// its normalized status.
+func validateVLAN(ctx context.Context, v models.VLAN) (string, error) {
+	if v.VID < 1 || v.VID > 4094 {
+		return "", errInvalid("invalid_vid", "VID must be between 1 and 4094")
+	}
+	if v.Name == "" {
+		return "", errInvalid("missing_field", "name is required")
+	}
+	status, err := parseVLANStatus(v.Status)
+	if err != nil {
+		return "", err
+	}
+	if err := checkVLANGroupExists(ctx, v.GroupID); err != nil {
+		return "", err
+	}
+	return status, checkSiteExists(ctx, v.SiteID)
+}
+
+func createVLAN(ctx context.Context, in models.VLAN) (models.VLAN, error) {
+	var v models.VLAN
+	status, err := validateVLAN(ctx, in)
+	if err != nil {
+		return v, err
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanVLAN(tx.QueryRow(ctx,
+			`INSERT INTO vlans (vid, name, group_id, site_id, status, description)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+vlanColumns,
+			in.VID, in.Name, in.GroupID, in.SiteID, status, in.Description), &v); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vlanAudit("create", nil, &v))
+	})
+	return v, vlanWriteError(ctx, err, in)
+}
+
+// vlanPatch lists the VLAN fields that may be changed; nil fields are left as is.
+type vlanPatch struct {
+	VID         *int         `json:"vid"`
+	Name        *string      `json:"name"`
+	GroupID     nullableUUID `json:"group_id"` // null removes the VLAN from its group
+	SiteID      nullableUUID `json:"site_id"`  // null removes the VLAN from its site
+	Status      *string      `json:"status"`
+	Description *string      `json:"description"`
+}
+
+func updateVLAN(ctx context.Context, id string, patch vlanPatch) (models.VLAN, error) {
+	v, err := getVLAN(ctx, id)
+	if err != nil {
+		return v, err
+	}
+	in := v
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.VLAN
+		if err := scanVLAN(tx.QueryRow(ctx, "SELECT "+vlanColumns+" FROM vlans WHERE id = $1 FOR UPDATE", v.ID), &before); err != nil {
+			return err
+		}
+		v = before
+		if patch.VID != nil {
+			v.VID = *patch.VID
+		}
+		if patch.Name != nil {
+			v.Name = *patch.Name
+		}
+		if patch.GroupID.Set {
+			v.GroupID = patch.GroupID.ID
+		}
+		if patch.SiteID.Set {
+			v.SiteID = patch.SiteID.ID
+		}
+		if patch.Status != nil {
+			v.Status = *patch.Status
+		}
+		if patch.Description != nil {
+			v.Description = *patch.Description
+		}
+		status, err := validateVLAN(ctx, v)
+		if err != nil {
+			return err
+		}
+		in = v
+		if err := scanVLAN(tx.QueryRow(ctx,
+			`UPDATE vlans SET vid = $2, name = $3, group_id = $4, site_id = $5, status = $6, description = $7
+			 WHERE id = $1 RETURNING `+vlanColumns,
+			v.ID, v.VID, v.Name, v.GroupID, v.SiteID, status, v.Description), &v); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vlanAudit("update", &before, &v))
+	})
+	if isNoRows(err) {
+		return v, errNotFound("vlan_not_found", "VLAN not found")
+	}
+	return v, vlanWriteError(ctx, err, in)
+}
+
+// deleteVLAN removes a VLAN. Its subnets are kept and no longer linked to a
+// VLAN, which is recorded as a change to each of them.
+func deleteVLAN(ctx context.Context, id string) error {
+	v, err := getVLAN(ctx, id)
+	if err != nil {
+		return err
+	}
+	return pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		subnetIDs, err := queryIDs(ctx, tx, "SELECT id FROM subnets WHERE vlan_id = $1", v.ID)
+		if err != nil {
+			return err
+		}
+		if err := recordChanges(ctx, tx, subnetIDs, subnetSnapshots, subnetAudit, func() error {
+			_, err := tx.Exec(ctx, "DELETE FROM vlans WHERE id = $1", v.ID)
+			return err
+		}); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vlanAudit("delete", &v, nil))
+	})
+}
+
+// vlanAudit describes a change to a VLAN, labelled by its VID and name;
+// before is nil when it was created and after is nil when it was deleted.
+func vlanAudit(action string, before, after *models.VLAN) auditEntry {
+	v := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectVLAN, v.ID, fmt.Sprintf("%d (%s)", v.VID, v.Name), before, after)
+}
+
+// vlanWriteError turns a duplicate VID into a conflict error naming the group.
+func vlanWriteError(ctx context.Context, err error, v models.VLAN) error {
+	if database.ErrorCode(err) != database.UniqueViolation {
+		return err
+	}
+	if !v.GroupID.Valid {
+		return errConflict("vlan_exists", "VLAN %d already exists outside any group", v.VID)
+	}
+	var group string
+	database.DB.QueryRow(ctx, "SELECT name FROM vlan_groups WHERE id = $1", v.GroupID).Scan(&group)
+	return errConflict("vlan_exists", "VLAN %d already exists in group %s", v.VID, group)
+}
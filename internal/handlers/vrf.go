@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ttani03/goth-ipam/internal/database"
+	"github.com/ttani03/goth-ipam/internal/models"
+	"github.com/ttani03/goth-ipam/internal/templates"
+)
+
+// rdRegex matches route distinguishers written as ASN:number or IPv4:number (RFC 4364).
+var rdRegex = regexp.MustCompile(`^([0-9]+|[0-9]+\.[0-9]+\.[0-9]+\.[0-9]+):([0-9]+)$`)
+
+// vrfColumns is the column list scanned by scanVRF.
+const vrfColumns = `id, name, rd, description, created_at,
+	(SELECT COUNT(*) FROM subnets s WHERE s.vrf_id = vrfs.id)`
+
+func HandleVRFList(w http.ResponseWriter, r *http.Request) {
+	vrfs, err := listVRFs(r.Context())
+	if err != nil {
+		writeError(w, err, "Failed to fetch VRFs")
+		return
+	}
+
+	component := templates.VRFList(vrfs)
+	component.Render(r.Context(), w)
+}
+
+func HandleCreateVRF(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	in := models.VRF{Name: r.FormValue("name"), Description: r.FormValue("description")}
+	if rd := r.FormValue("rd"); rd != "" {
+		in.RD = &rd
+	}
+	if _, err := createVRF(r.Context(), in); err != nil {
+		writeError(w, err, "Failed to create VRF")
+		return
+	}
+
+	// Return updated list
+	HandleVRFList(w, r)
+}
+
+func HandleDeleteVRF(w http.ResponseWriter, r *http.Request) {
+	if err := deleteVRF(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err, "Failed to delete VRF")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// scanVRF scans a row selected with vrfColumns.
+func scanVRF(row interface{ Scan(...any) error }, v *models.VRF) error {
+	return row.Scan(&v.ID, &v.Name, &v.RD, &v.Description, &v.CreatedAt, &v.SubnetCount)
+}
+
+func listVRFs(ctx context.Context) ([]models.VRF, error) {
+	rows, err := database.DB.Query(ctx, "SELECT "+vrfColumns+" FROM vrfs ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vrfs []models.VRF
+	for rows.Next() {
+		var v models.VRF
+		if err := scanVRF(rows, &v); err != nil {
+			return nil, err
+		}
+		vrfs = append(vrfs, v)
+	}
+	return vrfs, rows.Err()
+}
+
+func getVRF(ctx context.Context, id string) (models.VRF, error) {
+	var v models.VRF
+	err := scanVRF(database.DB.QueryRow(ctx, "SELECT "+vrfColumns+" FROM vrfs WHERE id = $1", id), &v)
+	if isNoRows(err) {
+		return v, errNotFound("vrf_not_found", "VRF not found")
+	}
+	return v, err
+}
+
+// parseVRFID parses a VRF reference from a form or query; empty means the global table.
+func parseVRFID(s string) (pgtype.UUID, error) {
+	var id pgtype.UUID
+	if s == "" {
+		return id, nil
+	}
+	if err := id.Scan(s); err != nil {
+		return id, errInvalid("invalid_vrf", "Invalid VRF ID")
+	}
+	return id, nil
+}
+
+// checkVRFExists reports an error if id refers to a VRF that does not exist.
+// The invalid (global) ID always exists.
+func checkVRFExists(ctx context.Context, id pgtype.UUID) error {
+	if !id.Valid {
+		return nil
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM vrfs WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errInvalid("vrf_not_found", "VRF not found")
+	}
+	return nil
+}
+
+// validateRD checks an optional route distinguisher and returns the value to store (nil when empty).
+// Type 0 is 2-byte ASN:4-byte number, type 1 IPv4:2-byte number and type 2 4-byte ASN:2-byte number.
+func validateRD(rd *string) (any, error) {
+	if rd == nil || *rd == "" {
+		return nil, nil
+	}
+	invalid := errInvalid("invalid_rd", "Route distinguisher must look like 65000:100 or 192.0.2.1:100")
+	m := rdRegex.FindStringSubmatch(*rd)
+	if m == nil {
+		return nil, invalid
+	}
+	number, err := strconv.ParseUint(m[2], 10, 32)
+	if err != nil {
+		return nil, invalid
+	}
+	if addr, err := netip.ParseAddr(m[1]); err == nil && addr.Is4() {
+		if number > 0xffff {
+			return nil, invalid
+		}
+		return *rd, nil
+	}
+	asn, err := strconv.ParseUint(m[1], 10, 32)
+	if err != nil || (asn > 0xffff && number > 0xffff) {
+		return nil, invalid
+	}
+	return *rd, nil
+}
+
+func createVRF(ctx context.Context, in models.VRF) (models.VRF, error) {
+	var v models.VRF
+	if in.Name == "" {
+		return v, errInvalid("missing_field", "name is required")
+	}
+	rd, err := validateRD(in.RD)
+	if err != nil {
+		return v, err
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if err := scanVRF(tx.QueryRow(ctx,
+			"INSERT INTO vrfs (name, rd, description) VALUES ($1, $2, $3) RETURNING "+vrfColumns,
+			in.Name, rd, in.Description), &v); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vrfAudit("create", nil, &v))
+	})
+	return v, vrfWriteError(err)
+}
+
+// vrfPatch lists the VRF fields that may be changed; nil fields are left as is.
+type vrfPatch struct {
+	Name        *string `json:"name"`
+	RD          *string `json:"rd"` // "" removes the route distinguisher
+	Description *string `json:"description"`
+}
+
+func updateVRF(ctx context.Context, id string, patch vrfPatch) (models.VRF, error) {
+	v, err := getVRF(ctx, id)
+	if err != nil {
+		return v, err
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		return v, errInvalid("missing_field", "name must not be empty")
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		var before models.VRF
+		if err := scanVRF(tx.QueryRow(ctx, "SELECT "+vrfColumns+" FROM vrfs WHERE id = $1 FOR UPDATE", v.ID), &before); err != nil {
+			return err
+		}
+		v = before
+		if patch.Name != nil {
+			v.Name = *patch.Name
+		}
+		if patch.RD != nil {
+			v.RD = patch.RD
+		}
+		if patch.Description != nil {
+			v.Description = *patch.Description
+		}
+		rd, err := validateRD(v.RD)
+		if err != nil {
+			return err
+		}
+		if err := scanVRF(tx.QueryRow(ctx,
+			"UPDATE vrfs SET name = $2, rd = $3, description = $4 WHERE id = $1 RETURNING "+vrfColumns,
+			v.ID, v.Name, rd, v.Description), &v); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vrfAudit("update", &before, &v))
+	})
+	if isNoRows(err) {
+		return v, errNotFound("vrf_not_found", "VRF not found")
+	}
+	return v, vrfWriteError(err)
+}
+
+// deleteVRF removes a VRF that no longer holds any subnets.
+func deleteVRF(ctx context.Context, id string) error {
+	v, err := getVRF(ctx, id)
+	if err != nil {
+		return err
+	}
+	if v.SubnetCount > 0 {
+		return errConflict("vrf_in_use", "VRF %s still holds %d subnets", v.Name, v.SubnetCount)
+	}
+	err = pgx.BeginFunc(ctx, database.DB, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DELETE FROM vrfs WHERE id = $1", v.ID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, vrfAudit("delete", &v, nil))
+	})
+	if database.ErrorCode(err) == database.ForeignKeyViolation {
+		return errConflict("vrf_in_use", "VRF %s still holds subnets", v.Name)
+	}
+	return err
+}
+
+// vrfAudit describes a change to a VRF; before is nil when it was created
+// and after is nil when it was deleted.
+func vrfAudit(action string, before, after *models.VRF) auditEntry {
+	v := cmp.Or(after, before)
+	return objectAudit(action, models.AuditObjectVRF, v.ID, v.Name, before, after)
+}
+
+// vrfWriteError turns unique violations into a conflict error.
+func vrfWriteError(err error) error {
+	if database.ErrorCode(err) == database.UniqueViolation {
+		return errConflict("vrf_exists", "A VRF with this name or route distinguisher already exists")
+	}
+	return err
+}
@@ -0,0 +1,262 @@
+// Package ipcalc provides address arithmetic used to compute subnet contents
+// without materializing every host address in the database.
+package ipcalc
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"net/netip"
+)
+
+// HostRange returns the first and last assignable host addresses of p.
+// For IPv4 prefixes shorter than /31 the network and broadcast addresses are excluded.
+// For IPv6 prefixes shorter than /127 the Subnet-Router anycast address (RFC 4291)
+// is excluded; /127 point-to-point links use both addresses (RFC 6164).
+func HostRange(p netip.Prefix) (first, last netip.Addr) {
+	p = p.Masked()
+	first = p.Addr()
+	last = lastAddr(p)
+	if first.Is4() && p.Bits() < 31 {
+		first = first.Next()
+		last = last.Prev()
+	}
+	if first.Is6() && p.Bits() < 127 {
+		first = first.Next()
+	}
+	return first, last
+}
+
+// HostCount returns the exact number of assignable host addresses in p,
+// which exceeds the range of uint64 for IPv6 prefixes shorter than /64.
+func HostCount(p netip.Prefix) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+	switch {
+	case p.Addr().Is4() && p.Bits() < 31:
+		n.Sub(n, big.NewInt(2))
+	case p.Addr().Is6() && p.Bits() < 127:
+		n.Sub(n, big.NewInt(1))
+	}
+	return n
+}
+
+// BlockHostCount returns the number of assignable host addresses of p that
+// lie in block, a prefix inside p. Unlike HostCount(block), only the
+// addresses that are unusable in p (e.g. its network and broadcast
+// addresses) are left out.
+func BlockHostCount(p, block netip.Prefix) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(1), uint(block.Addr().BitLen()-block.Bits()))
+	first, last := HostRange(p)
+	if a := first.Prev(); p.Contains(a) && block.Contains(a) {
+		n.Sub(n, big.NewInt(1))
+	}
+	if a := last.Next(); p.Contains(a) && block.Contains(a) {
+		n.Sub(n, big.NewInt(1))
+	}
+	return n
+}
+
+// RangeSize returns the number of addresses from first to last (inclusive),
+// which exceeds the range of uint64 for large IPv6 ranges.
+// first and last must belong to the same address family and first must not be greater than last.
+func RangeSize(first, last netip.Addr) *big.Int {
+	n := new(big.Int).SetBytes(last.AsSlice())
+	n.Sub(n, new(big.Int).SetBytes(first.AsSlice()))
+	return n.Add(n, big.NewInt(1))
+}
+
+// Add returns a advanced by n addresses.
+// ok is false when the result would run past the end of the address family.
+func Add(a netip.Addr, n uint64) (netip.Addr, bool) {
+	if a.Is4() {
+		b := a.As4()
+		v := uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3])
+		if n > math.MaxUint32-v {
+			return netip.Addr{}, false
+		}
+		v += n
+		return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}), true
+	}
+	hi, lo := split(a)
+	lo, carry := bits.Add64(lo, n, 0)
+	hi, overflow := bits.Add64(hi, 0, carry)
+	if overflow != 0 {
+		return netip.Addr{}, false
+	}
+	return join(hi, lo), true
+}
+
+// AddBig is Add for offsets that may exceed uint64, as in IPv6 prefixes shorter than /64.
+func AddBig(a netip.Addr, n *big.Int) (netip.Addr, bool) {
+	if n.Sign() < 0 {
+		return netip.Addr{}, false
+	}
+	v := new(big.Int).SetBytes(a.AsSlice())
+	v.Add(v, n)
+	if v.BitLen() > a.BitLen() {
+		return netip.Addr{}, false
+	}
+	b := make([]byte, a.BitLen()/8)
+	addr, _ := netip.AddrFromSlice(v.FillBytes(b))
+	return addr, true
+}
+
+// Distance returns b - a, saturating at math.MaxUint64.
+// a and b must belong to the same address family and a must not be greater than b.
+func Distance(a, b netip.Addr) uint64 {
+	if a.Is4() {
+		return uint64(v4(b) - v4(a))
+	}
+	ahi, alo := split(a)
+	bhi, blo := split(b)
+	lo, borrow := bits.Sub64(blo, alo, 0)
+	hi, _ := bits.Sub64(bhi, ahi, borrow)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
+// FreeAddrs returns up to limit addresses between first and last (inclusive)
+// that are not present in used, skipping the first skip free addresses.
+// used must be sorted in ascending order; entries outside [first, last] are ignored.
+func FreeAddrs(first, last netip.Addr, used []netip.Addr, skip uint64, limit int) []netip.Addr {
+	var out []netip.Addr
+	cur := first
+	// take collects free addresses from the half-open run [cur, end).
+	take := func(end netip.Addr) {
+		gap := Distance(cur, end)
+		if skip >= gap {
+			skip -= gap
+			return
+		}
+		a, _ := Add(cur, skip)
+		skip = 0
+		for ; a.Less(end) && len(out) < limit; a = a.Next() {
+			out = append(out, a)
+		}
+	}
+	for _, u := range used {
+		if u.Less(cur) || last.Less(u) {
+			continue
+		}
+		take(u)
+		if len(out) >= limit || u == last {
+			return out
+		}
+		cur = u.Next()
+	}
+	// The trailing run includes last itself.
+	take(last)
+	if len(out) < limit && skip == 0 {
+		out = append(out, last)
+	}
+	return out
+}
+
+// LastFree returns the highest address between first and last (inclusive)
+// that is not present in used. used must be sorted in ascending order.
+// ok is false when every address is used.
+func LastFree(first, last netip.Addr, used []netip.Addr) (netip.Addr, bool) {
+	cur := last
+	for i := len(used) - 1; i >= 0; i-- {
+		u := used[i]
+		if cur.Less(u) {
+			continue
+		}
+		if u.Less(cur) || u.Less(first) {
+			break
+		}
+		// u == cur: step below it.
+		if cur == first {
+			return netip.Addr{}, false
+		}
+		cur = cur.Prev()
+	}
+	return cur, true
+}
+
+// NextFreePrefix returns the lowest /bits block inside parent that overlaps
+// none of the prefixes in used. used must be sorted by address; prefixes
+// outside parent are ignored. ok is false when no such block exists.
+func NextFreePrefix(parent netip.Prefix, bits int, used []netip.Prefix) (netip.Prefix, bool) {
+	parent = parent.Masked()
+	if bits < parent.Bits() || bits > parent.Addr().BitLen() {
+		return netip.Prefix{}, false
+	}
+	cand := netip.PrefixFrom(parent.Addr(), bits)
+	for _, u := range used {
+		if !u.Overlaps(cand) {
+			if cand.Addr().Less(u.Addr()) {
+				break
+			}
+			continue
+		}
+		// Jump to the first aligned block after u.
+		next := lastAddr(u.Masked()).Next()
+		if !next.IsValid() {
+			return netip.Prefix{}, false
+		}
+		if p := netip.PrefixFrom(next, bits).Masked(); p.Addr() != next {
+			if next = lastAddr(p).Next(); !next.IsValid() {
+				return netip.Prefix{}, false
+			}
+		}
+		cand = netip.PrefixFrom(next, bits)
+	}
+	if !parent.Contains(cand.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return cand, true
+}
+
+// lastAddr returns the highest address contained in the masked prefix p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	if p.Addr().Is4() {
+		v := v4(p.Addr()) | (uint32(1)<<(32-p.Bits()) - 1)
+		return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	}
+	hi, lo := split(p.Addr())
+	hostBits := 128 - p.Bits()
+	switch {
+	case hostBits >= 64:
+		lo = math.MaxUint64
+		hi |= mask(hostBits - 64)
+	default:
+		lo |= mask(hostBits)
+	}
+	return join(hi, lo)
+}
+
+// mask returns a value with the low n bits set (0 <= n <= 64).
+func mask(n int) uint64 {
+	if n >= 64 {
+		return math.MaxUint64
+	}
+	return uint64(1)<<n - 1
+}
+
+func v4(a netip.Addr) uint32 {
+	b := a.As4()
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func split(a netip.Addr) (hi, lo uint64) {
+	b := a.As16()
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[i+8])
+	}
+	return hi, lo
+}
+
+func join(hi, lo uint64) netip.Addr {
+	var b [16]byte
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(hi)
+		b[i+8] = byte(lo)
+		hi >>= 8
+		lo >>= 8
+	}
+	return netip.AddrFrom16(b)
+}
@@ -0,0 +1,293 @@
+package ipcalc
+
+import (
+	"math"
+	"math/big"
+	"net/netip"
+	"testing"
+)
+
+func TestHostRange(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		first, last string
+	}{
+		{"192.168.1.0/24", "192.168.1.1", "192.168.1.254"},
+		{"192.168.1.77/24", "192.168.1.1", "192.168.1.254"},
+		{"10.0.0.0/8", "10.0.0.1", "10.255.255.254"},
+		{"192.168.100.0/30", "192.168.100.1", "192.168.100.2"},
+		{"192.168.100.0/31", "192.168.100.0", "192.168.100.1"},
+		{"192.168.100.7/32", "192.168.100.7", "192.168.100.7"},
+		{"0.0.0.0/0", "0.0.0.1", "255.255.255.254"},
+		{"2001:db8::/64", "2001:db8::1", "2001:db8::ffff:ffff:ffff:ffff"},
+		{"2001:DB8:0:0:1::/120", "2001:db8::1:0:0:1", "2001:db8::1:0:0:ff"},
+		{"2001:db8::/127", "2001:db8::", "2001:db8::1"},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			first, last := HostRange(netip.MustParsePrefix(tt.prefix))
+			if first.String() != tt.first || last.String() != tt.last {
+				t.Errorf("HostRange(%s) = %s-%s, want %s-%s", tt.prefix, first, last, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestHostCount(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"192.168.100.0/30", "2"},
+		{"192.168.100.0/31", "2"},
+		{"192.168.100.0/32", "1"},
+		{"10.0.0.0/8", "16777214"},
+		{"0.0.0.0/0", "4294967294"},
+		{"2001:db8::/120", "255"},
+		{"2001:db8::/127", "2"},
+		{"2001:db8::/64", "18446744073709551615"},
+		{"2001:db8::/48", "1208925819614629174706175"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := HostCount(netip.MustParsePrefix(tt.prefix)); got.String() != tt.want {
+				t.Errorf("HostCount(%s) = %s, want %s", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockHostCount(t *testing.T) {
+	tests := []struct {
+		prefix, block string
+		want          string
+	}{
+		{"10.0.0.0/16", "10.0.0.0/24", "255"},   // network address
+		{"10.0.0.0/16", "10.0.1.0/24", "256"},   // inner block
+		{"10.0.0.0/16", "10.0.255.0/24", "255"}, // broadcast address
+		{"10.0.0.0/24", "10.0.0.0/24", "254"},
+		{"10.0.0.0/31", "10.0.0.0/31", "2"},
+		{"2001:db8::/56", "2001:db8::/64", "18446744073709551615"},
+		{"2001:db8::/56", "2001:db8:0:ff::/64", "18446744073709551616"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.block, func(t *testing.T) {
+			got := BlockHostCount(netip.MustParsePrefix(tt.prefix), netip.MustParsePrefix(tt.block))
+			if got.String() != tt.want {
+				t.Errorf("BlockHostCount(%s, %s) = %s, want %s", tt.prefix, tt.block, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRangeSize(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        string
+	}{
+		{"10.0.0.100", "10.0.0.199", "100"},
+		{"10.0.0.1", "10.0.0.1", "1"},
+		{"10.0.0.255", "10.0.1.0", "2"},
+		{"2001:db8::1", "2001:db8::ff", "255"},
+		{"2001:db8::", "2001:db8:0:1:ffff:ffff:ffff:ffff", "36893488147419103232"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.first+"-"+tt.last, func(t *testing.T) {
+			got := RangeSize(netip.MustParseAddr(tt.first), netip.MustParseAddr(tt.last))
+			if got.String() != tt.want {
+				t.Errorf("RangeSize(%s, %s) = %s, want %s", tt.first, tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		addr string
+		n    uint64
+		want string
+		ok   bool
+	}{
+		{"192.168.1.1", 1, "192.168.1.2", true},
+		{"192.168.1.255", 1, "192.168.2.0", true},
+		{"10.0.0.1", 1 << 16, "10.1.0.1", true},
+		{"255.255.255.254", 1, "255.255.255.255", true},
+		{"255.255.255.255", 1, "", false},
+		{"2001:db8::ffff:ffff:ffff:ffff", 1, "2001:db8:0:1::", true},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", 1, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Add(netip.MustParseAddr(tt.addr), tt.n)
+		if ok != tt.ok || (ok && got.String() != tt.want) {
+			t.Errorf("Add(%s, %d) = %s, %v; want %s, %v", tt.addr, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAddBig(t *testing.T) {
+	huge, _ := new(big.Int).SetString("18446744073709551616", 10) // 2^64
+	tests := []struct {
+		addr string
+		n    *big.Int
+		want string
+		ok   bool
+	}{
+		{"10.0.0.1", big.NewInt(255), "10.0.1.0", true},
+		{"255.255.255.255", big.NewInt(1), "", false},
+		{"2001:db8::", huge, "2001:db8:0:1::", true},
+		{"2001:db8::1", big.NewInt(0), "2001:db8::1", true},
+		{"10.0.0.1", big.NewInt(-1), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := AddBig(netip.MustParseAddr(tt.addr), tt.n)
+		if ok != tt.ok || (ok && got.String() != tt.want) {
+			t.Errorf("AddBig(%s, %s) = %s, %v; want %s, %v", tt.addr, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want uint64
+	}{
+		{"10.0.0.1", "10.0.0.1", 0},
+		{"10.0.0.1", "10.0.1.0", 255},
+		{"2001:db8::", "2001:db8:0:1::", math.MaxUint64},
+		{"2001:db8::ff", "2001:db8::1:0", 0xff01},
+	}
+	for _, tt := range tests {
+		if got := Distance(netip.MustParseAddr(tt.a), netip.MustParseAddr(tt.b)); got != tt.want {
+			t.Errorf("Distance(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFreeAddrs(t *testing.T) {
+	parse := func(ss ...string) []netip.Addr {
+		out := make([]netip.Addr, 0, len(ss))
+		for _, s := range ss {
+			out = append(out, netip.MustParseAddr(s))
+		}
+		return out
+	}
+	first := netip.MustParseAddr("10.0.0.1")
+	last := netip.MustParseAddr("10.0.0.6")
+
+	tests := []struct {
+		name  string
+		used  []netip.Addr
+		skip  uint64
+		limit int
+		want  []string
+	}{
+		{"nothing used", nil, 0, 10, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}},
+		{"limit", nil, 0, 2, []string{"10.0.0.1", "10.0.0.2"}},
+		{"skip", nil, 4, 10, []string{"10.0.0.5", "10.0.0.6"}},
+		{"gaps", parse("10.0.0.1", "10.0.0.3", "10.0.0.4"), 0, 10, []string{"10.0.0.2", "10.0.0.5", "10.0.0.6"}},
+		{"skip across used", parse("10.0.0.1", "10.0.0.3", "10.0.0.4"), 1, 10, []string{"10.0.0.5", "10.0.0.6"}},
+		{"last used", parse("10.0.0.6"), 3, 10, []string{"10.0.0.4", "10.0.0.5"}},
+		{"all used", parse("10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"), 0, 10, nil},
+		{"skip past end", nil, 6, 10, nil},
+		{"out of range ignored", parse("10.0.0.0", "10.0.0.2", "10.0.0.7"), 0, 10, []string{"10.0.0.1", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FreeAddrs(first, last, tt.used, tt.skip, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FreeAddrs() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].String() != tt.want[i] {
+					t.Fatalf("FreeAddrs() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLastFree(t *testing.T) {
+	parse := func(ss ...string) []netip.Addr {
+		out := make([]netip.Addr, 0, len(ss))
+		for _, s := range ss {
+			out = append(out, netip.MustParseAddr(s))
+		}
+		return out
+	}
+	first := netip.MustParseAddr("10.0.0.1")
+	last := netip.MustParseAddr("10.0.0.6")
+
+	tests := []struct {
+		name string
+		used []netip.Addr
+		want string // "" when nothing is free
+	}{
+		{"nothing used", nil, "10.0.0.6"},
+		{"top used", parse("10.0.0.5", "10.0.0.6"), "10.0.0.4"},
+		{"gap below top", parse("10.0.0.4", "10.0.0.6"), "10.0.0.5"},
+		{"out of range ignored", parse("10.0.0.0", "10.0.0.6", "10.0.0.7"), "10.0.0.5"},
+		{"only first free", parse("10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"), "10.0.0.1"},
+		{"all used", parse("10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LastFree(first, last, tt.used)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("LastFree() = %s, want none", got)
+				}
+				return
+			}
+			if !ok || got.String() != tt.want {
+				t.Fatalf("LastFree() = %s, %v; want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextFreePrefix(t *testing.T) {
+	parse := func(ss ...string) []netip.Prefix {
+		out := make([]netip.Prefix, 0, len(ss))
+		for _, s := range ss {
+			out = append(out, netip.MustParsePrefix(s))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		parent string
+		bits   int
+		used   []netip.Prefix
+		want   string // "" when nothing is free
+	}{
+		{"empty parent", "10.20.0.0/16", 26, nil, "10.20.0.0/26"},
+		{"after first child", "10.20.0.0/16", 26, parse("10.20.0.0/26"), "10.20.0.64/26"},
+		{"gap between children", "10.20.0.0/16", 26, parse("10.20.0.0/26", "10.20.0.128/25"), "10.20.0.64/26"},
+		{"gap too small", "10.20.0.0/16", 25, parse("10.20.0.0/26", "10.20.0.128/25"), "10.20.1.0/25"},
+		{"realign after small child", "10.20.0.0/16", 24, parse("10.20.0.64/26"), "10.20.1.0/24"},
+		{"nested children", "10.20.0.0/16", 24, parse("10.20.0.0/23", "10.20.0.0/24", "10.20.1.0/24"), "10.20.2.0/24"},
+		{"child later in block", "10.20.0.0/16", 26, parse("10.20.3.0/24"), "10.20.0.0/26"},
+		{"full", "10.20.0.0/24", 25, parse("10.20.0.0/25", "10.20.0.128/25"), ""},
+		{"last block", "10.20.0.0/24", 26, parse("10.20.0.0/25", "10.20.0.128/26"), "10.20.0.192/26"},
+		{"end of address space", "255.255.255.0/24", 25, parse("255.255.255.0/25", "255.255.255.128/25"), ""},
+		{"ipv6", "2001:db8::/48", 64, parse("2001:db8::/64", "2001:db8:0:1::/64"), "2001:db8:0:2::/64"},
+		{"too short", "10.20.0.0/16", 15, nil, ""},
+		{"too long", "10.20.0.0/16", 33, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NextFreePrefix(netip.MustParsePrefix(tt.parent), tt.bits, tt.used)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("NextFreePrefix() = %s, want none", got)
+				}
+				return
+			}
+			if !ok || got.String() != tt.want {
+				t.Fatalf("NextFreePrefix() = %s, %v; want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
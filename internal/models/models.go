@@ -1,23 +1,478 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Subnet kinds. Containers group child subnets and hold no host addresses;
+// networks hold host addresses and no child subnets.
+const (
+	SubnetKindNetwork   = "network"
+	SubnetKindContainer = "container"
+)
+
 type Subnet struct {
-	ID        pgtype.UUID `json:"id"`
-	CIDR      string      `json:"cidr"`
-	Name      string      `json:"name"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID                pgtype.UUID    `json:"id"`
+	CIDR              string         `json:"cidr"`
+	Name              string         `json:"name"`
+	Kind              string         `json:"kind"`
+	VRFID             pgtype.UUID    `json:"vrf_id"`    // null for the global routing table
+	VRFName           string         `json:"-"`         // name of the VRF, for display
+	VLANID            pgtype.UUID    `json:"vlan_id"`   // null when not linked to a VLAN
+	VLANLabel         string         `json:"-"`         // e.g. "100 (servers)", for display
+	SiteID            pgtype.UUID    `json:"site_id"`   // null when not assigned to a site
+	SiteName          string         `json:"-"`         // name of the site, for display
+	TenantID          pgtype.UUID    `json:"tenant_id"` // null when not owned by a tenant
+	TenantName        string         `json:"-"`         // name of the tenant, for display
+	Tags              []TagRef       `json:"tags"`
+	CustomFields      map[string]any `json:"custom_fields"`      // values keyed by custom field name
+	ParentID          pgtype.UUID    `json:"parent_id"`          // smallest container enclosing the subnet; computed, not stored
+	Gateway           string         `json:"gateway"`            // gateway address; empty when none
+	ReservationPolicy string         `json:"reservation_policy"` // policy applied on creation, e.g. "gateway=first,last=1"; empty when none
+	CreatedAt         time.Time      `json:"created_at"`
+	Stats             *SubnetStats   `json:"stats,omitempty"` // only loaded by the list, the detail page and the API
+}
+
+// Utilization levels of a subnet, by the configured thresholds.
+const (
+	UtilizationOK       = "ok"
+	UtilizationWarning  = "warning"
+	UtilizationCritical = "critical"
+)
+
+// SubnetStats counts the host addresses of a subnet by status. For a
+// container, they are the sums over the networks nested inside it.
+type SubnetStats struct {
+	Allocated int64    `json:"allocated"`
+	Reserved  int64    `json:"reserved"`
+	Available *big.Int `json:"available"`
+	Total     *big.Int `json:"total"`   // may exceed 2^63 for IPv6 subnets
+	Percent   float64  `json:"percent"` // allocated and reserved share of the total
+	Level     string   `json:"level"`   // ok, warning or critical
+}
+
+// Utilization returns the used (allocated or reserved) and total addresses.
+func (s SubnetStats) Utilization() Utilization {
+	return Utilization{Used: big.NewInt(s.Allocated + s.Reserved), Total: s.Total}
+}
+
+// SubnetNode is a subnet with the subnets nested directly inside it.
+type SubnetNode struct {
+	Subnet
+	Children []SubnetNode
+}
+
+// VRF is a routing domain. Address space is unique within a VRF, so the same
+// private prefixes can be used by several VRFs.
+type VRF struct {
+	ID          pgtype.UUID `json:"id"`
+	Name        string      `json:"name"`
+	RD          *string     `json:"rd"` // route distinguisher, e.g. "65000:100"
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SubnetCount int         `json:"subnet_count"` // computed, not stored
+}
+
+// VLAN statuses.
+const (
+	VLANStatusActive     = "active"
+	VLANStatusReserved   = "reserved"
+	VLANStatusDeprecated = "deprecated"
+)
+
+// VLANGroup scopes VIDs, e.g. to one switching domain. A VID is unique within
+// its group.
+type VLANGroup struct {
+	ID          pgtype.UUID `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	VLANCount   int         `json:"vlan_count"` // computed, not stored
+}
+
+type VLAN struct {
+	ID          pgtype.UUID `json:"id"`
+	VID         int         `json:"vid"` // 1–4094
+	Name        string      `json:"name"`
+	GroupID     pgtype.UUID `json:"group_id"` // null for VLANs outside any group
+	GroupName   string      `json:"-"`        // name of the group, for display
+	SiteID      pgtype.UUID `json:"site_id"`  // null when not assigned to a site
+	SiteName    string      `json:"-"`        // name of the site, for display
+	Status      string      `json:"status"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SubnetCount int         `json:"subnet_count"` // computed, not stored
+}
+
+// Region groups sites, e.g. by country or metro area.
+type Region struct {
+	ID          pgtype.UUID `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SiteCount   int         `json:"site_count"` // computed, not stored
+}
+
+// Site is a datacenter, campus or branch office. Subnets and VLANs are
+// assigned to sites.
+type Site struct {
+	ID          pgtype.UUID `json:"id"`
+	RegionID    pgtype.UUID `json:"region_id"`
+	RegionName  string      `json:"-"` // name of the region, for display
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SubnetCount int         `json:"subnet_count"` // computed, not stored
+	VLANCount   int         `json:"vlan_count"`   // computed, not stored
+	Locations   []Location  `json:"locations,omitempty"`
+	Utilization Utilization `json:"utilization"` // of the networks assigned to the site
+}
+
+// Location is a place within a site, such as a room or floor.
+type Location struct {
+	ID          pgtype.UUID `json:"id"`
+	SiteID      pgtype.UUID `json:"site_id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Tenant is an organization, such as a business unit, that owns subnets and
+// addresses.
+type Tenant struct {
+	ID          pgtype.UUID `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SubnetCount int         `json:"subnet_count"` // computed, not stored
+}
+
+// Interface types.
+const (
+	InterfaceEthernet = "ethernet"
+	InterfaceVirtual  = "virtual"
+	InterfaceLAG      = "lag"
+	InterfaceWireless = "wireless"
+	InterfaceLoopback = "loopback"
+)
+
+// InterfaceTypes lists the interface types in the order they are offered.
+var InterfaceTypes = []string{InterfaceEthernet, InterfaceVirtual, InterfaceLAG, InterfaceWireless, InterfaceLoopback}
+
+// Device is a router, switch, server or other equipment whose interfaces
+// hold addresses.
+type Device struct {
+	ID             pgtype.UUID `json:"id"`
+	Name           string      `json:"name"`
+	Role           string      `json:"role"`
+	Platform       string      `json:"platform"`
+	SiteID         pgtype.UUID `json:"site_id"`
+	SiteName       string      `json:"-"` // name of the site, for display
+	Description    string      `json:"description"`
+	PrimaryIPID    pgtype.UUID `json:"primary_ip_id"`
+	PrimaryIP      string      `json:"primary_ip"` // address of the primary IP, "" when none
+	CreatedAt      time.Time   `json:"created_at"`
+	InterfaceCount int         `json:"interface_count"` // computed, not stored
+	Interfaces     []Interface `json:"interfaces,omitempty"`
+}
+
+// Interface is a network interface of a device. Addresses are assigned to
+// interfaces.
+type Interface struct {
+	ID         pgtype.UUID `json:"id"`
+	DeviceID   pgtype.UUID `json:"device_id"`
+	DeviceName string      `json:"-"` // name of the device, for display
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	MACAddress *string     `json:"mac_address"`
+	MACVendor  string      `json:"mac_vendor"` // vendor of the MAC's OUI, not stored
+	CreatedAt  time.Time   `json:"created_at"`
+	IPCount    int         `json:"ip_count"` // computed, not stored
+}
+
+// Tag colors, named after the DaisyUI badge colors used to render them.
+const (
+	TagColorNeutral   = "neutral"
+	TagColorPrimary   = "primary"
+	TagColorSecondary = "secondary"
+	TagColorAccent    = "accent"
+	TagColorInfo      = "info"
+	TagColorSuccess   = "success"
+	TagColorWarning   = "warning"
+	TagColorError     = "error"
+)
+
+// TagColors lists the valid tag colors.
+var TagColors = []string{
+	TagColorNeutral, TagColorPrimary, TagColorSecondary, TagColorAccent,
+	TagColorInfo, TagColorSuccess, TagColorWarning, TagColorError,
+}
+
+// Tag labels subnets and addresses, e.g. "prod" or "k8s-node".
+type Tag struct {
+	ID          pgtype.UUID `json:"id"`
+	Name        string      `json:"name"`
+	Color       string      `json:"color"`
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+	SubnetCount int         `json:"subnet_count"` // computed, not stored
+	IPCount     int         `json:"ip_count"`     // computed, not stored
+}
+
+// TagRef is a tag as attached to a subnet or address.
+type TagRef struct {
+	ID    pgtype.UUID `json:"id"`
+	Name  string      `json:"name"`
+	Color string      `json:"color"`
+}
+
+// Objects custom fields can be defined for.
+const (
+	CustomFieldObjectSubnet = "subnet"
+	CustomFieldObjectIP     = "ip"
+)
+
+// Custom field types. Values are stored as JSON strings, except integer
+// (a number) and boolean.
+const (
+	CustomFieldText    = "text"
+	CustomFieldInteger = "integer"
+	CustomFieldBoolean = "boolean"
+	CustomFieldDate    = "date" // YYYY-MM-DD
+	CustomFieldEnum    = "enum" // one of Choices
+	CustomFieldURL     = "url"  // absolute http or https URL
+)
+
+// CustomFieldTypes lists the valid custom field types.
+var CustomFieldTypes = []string{
+	CustomFieldText, CustomFieldInteger, CustomFieldBoolean,
+	CustomFieldDate, CustomFieldEnum, CustomFieldURL,
+}
+
+// CustomField is an admin-defined attribute of subnets or addresses, e.g. an
+// owner email or a ticket number.
+type CustomField struct {
+	ID          pgtype.UUID `json:"id"`
+	ObjectType  string      `json:"object_type"` // subnet or ip
+	Name        string      `json:"name"`        // key of the value in custom_fields, e.g. "owner_email"
+	Label       string      `json:"label"`       // shown in forms and tables; defaults to the name
+	Type        string      `json:"type"`
+	Choices     []string    `json:"choices"` // the allowed values of an enum
+	Description string      `json:"description"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Text returns the value of the field in values (the CustomFields of a subnet
+// or address) as text, or "" when it is not set.
+func (f CustomField) Text(values map[string]any) string {
+	switch v := values[f.Name].(type) {
+	case nil:
+		return ""
+	case float64: // integers, as decoded from JSON
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// TenantUsage counts the addresses a tenant holds. An address belongs to its
+// own tenant, or else to the tenant of its subnet.
+type TenantUsage struct {
+	TenantID   pgtype.UUID `json:"tenant_id"`
+	TenantName string      `json:"tenant_name"`
+	Subnets    int         `json:"subnets"`
+	Allocated  int64       `json:"allocated"`
+	Reserved   int64       `json:"reserved"`
+}
+
+// Utilization counts the used (allocated or reserved) host addresses of one
+// or more networks.
+type Utilization struct {
+	Used  *big.Int `json:"used"`
+	Total *big.Int `json:"total"` // may exceed 2^63 for IPv6 networks
+}
+
+// Add adds the counts of o to u.
+func (u *Utilization) Add(o Utilization) {
+	if u.Used == nil {
+		u.Used, u.Total = new(big.Int), new(big.Int)
+	}
+	if o.Used != nil {
+		u.Used.Add(u.Used, o.Used)
+		u.Total.Add(u.Total, o.Total)
+	}
+}
+
+// Percent returns the used share of the total in percent, 0 when there are
+// no host addresses.
+func (u Utilization) Percent() float64 {
+	if u.Total == nil || u.Total.Sign() == 0 {
+		return 0
+	}
+	p, _ := new(big.Rat).SetFrac(new(big.Int).Mul(u.Used, big.NewInt(100)), u.Total).Float64()
+	return p
 }
 
 type IP struct {
-	ID        pgtype.UUID `json:"id"`
-	SubnetID  pgtype.UUID `json:"subnet_id"`
-	Address   string      `json:"address"`
-	Status    string      `json:"status"`
-	Hostname  *string     `json:"hostname"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID                pgtype.UUID    `json:"id"`
+	SubnetID          pgtype.UUID    `json:"subnet_id"`
+	Address           string         `json:"address"`
+	Status            string         `json:"status"`
+	Hostname          *string        `json:"hostname"`
+	MACAddress        *string        `json:"mac_address"`         // lowercase colon form, null when unknown
+	MACVendor         string         `json:"mac_vendor"`          // vendor of the MAC's OUI, not stored
+	MACConflicts      int            `json:"mac_conflicts"`       // other addresses with the same MAC, not stored
+	TenantID          pgtype.UUID    `json:"tenant_id"`           // null when the address belongs to the subnet's tenant
+	TenantName        string         `json:"-"`                   // name of the tenant, for display
+	InterfaceID       pgtype.UUID    `json:"interface_id"`        // null when not assigned to a device
+	InterfaceName     string         `json:"-"`                   // name of the interface, for display
+	DeviceID          pgtype.UUID    `json:"device_id"`           // device of the interface, not stored
+	DeviceName        string         `json:"-"`                   // name of the device, for display
+	Tags              []TagRef       `json:"tags"`                // null for available addresses, which are not stored
+	CustomFields      map[string]any `json:"custom_fields"`       // values keyed by custom field name; null for available addresses
+	ReservationReason string         `json:"reservation_reason"`  // policy term that reserved the address, e.g. "gateway=first"; empty otherwise
+	ExpiresAt         *time.Time     `json:"expires_at"`          // null when the address does not expire
+	Range             *IPRange       `json:"-"`                   // the range containing the address, for display
+	CreatedAt         time.Time      `json:"created_at,omitzero"` // zero for available addresses, which are not stored
+}
+
+// Roles of an IP range.
+const (
+	RangeRoleDHCP     = "dhcp"     // handed out by a DHCP server
+	RangeRoleStatic   = "static"   // for static assignments
+	RangeRoleReserved = "reserved" // infrastructure, e.g. gateways and switches
+)
+
+// RangeRoles lists the range roles in the order they are offered.
+var RangeRoles = []string{RangeRoleDHCP, RangeRoleStatic, RangeRoleReserved}
+
+// IPRange is a contiguous block of addresses of a network set aside for a
+// purpose. The ranges of a subnet do not overlap.
+type IPRange struct {
+	ID           pgtype.UUID `json:"id"`
+	SubnetID     pgtype.UUID `json:"subnet_id"`
+	StartAddress string      `json:"start_address"` // inclusive
+	EndAddress   string      `json:"end_address"`   // inclusive
+	Role         string      `json:"role"`          // dhcp, static or reserved
+	Description  string      `json:"description"`
+	Size         *big.Int    `json:"size"` // number of addresses; computed
+	Used         int64       `json:"used"` // allocated or reserved addresses; computed
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// MACDuplicate is a MAC address recorded on more than one address, which
+// usually means a stale record or a misconfigured host.
+type MACDuplicate struct {
+	MACAddress string `json:"mac_address"`
+	MACVendor  string `json:"mac_vendor"`
+	IPs        []IP   `json:"ips"`
+}
+
+// IPExpiration records an allocated or reserved address that was returned to
+// the pool because its expiry passed.
+type IPExpiration struct {
+	ID         pgtype.UUID `json:"id"`
+	SubnetID   pgtype.UUID `json:"subnet_id"`
+	Address    string      `json:"address"`
+	Status     string      `json:"status"` // allocated or reserved, before the release
+	Hostname   *string     `json:"hostname"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	ReleasedAt time.Time   `json:"released_at"`
+	Reason     string      `json:"reason"` // e.g. "reservation expired"
+}
+
+// Object types of the audit log.
+const (
+	AuditObjectSubnet      = "subnet"
+	AuditObjectRange       = "range"
+	AuditObjectIP          = "ip"
+	AuditObjectVRF         = "vrf"
+	AuditObjectVLANGroup   = "vlan_group"
+	AuditObjectVLAN        = "vlan"
+	AuditObjectRegion      = "region"
+	AuditObjectSite        = "site"
+	AuditObjectLocation    = "location"
+	AuditObjectTenant      = "tenant"
+	AuditObjectTag         = "tag"
+	AuditObjectCustomField = "custom_field"
+	AuditObjectDevice      = "device"
+	AuditObjectInterface   = "interface"
+)
+
+// AuditObjectTypes lists the audited object types in the order they are offered.
+var AuditObjectTypes = []string{
+	AuditObjectSubnet, AuditObjectRange, AuditObjectIP, AuditObjectVRF, AuditObjectVLANGroup, AuditObjectVLAN,
+	AuditObjectRegion, AuditObjectSite, AuditObjectLocation, AuditObjectTenant, AuditObjectTag,
+	AuditObjectCustomField, AuditObjectDevice, AuditObjectInterface,
+}
+
+// AuditEntry records one change to an object, such as a subnet, a range, an
+// address or a device: who made it, from where, and the object before and
+// after. Entries are never changed or deleted, so they outlive the objects
+// they describe.
+type AuditEntry struct {
+	ID          int64           `json:"id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Actor       string          `json:"actor"`  // user reported by the proxy, "anonymous" or "system"
+	Action      string          `json:"action"` // e.g. "create", "allocate", "release" or "expire"
+	ObjectType  string          `json:"object_type"`
+	ObjectID    string          `json:"object_id"`    // UUID of the object, or the address of an ip
+	ObjectLabel string          `json:"object_label"` // CIDR, range, address or name at the time of the change
+	SubnetID    pgtype.UUID     `json:"subnet_id"`    // the subnet itself, or the subnet of a range or address; null for other objects
+	Before      json.RawMessage `json:"before"`       // null when the object was created
+	After       json.RawMessage `json:"after"`        // null when the object was deleted
+	RequestID   string          `json:"request_id"`
+	SourceIP    string          `json:"source_ip"` // empty for changes made by the server itself
+	Changes     []AuditChange   `json:"-"`         // fields that differ between Before and After, for display
+}
+
+// AuditChange is a field changed by an AuditEntry, with its values as JSON.
+type AuditChange struct {
+	Field  string
+	Before string // empty when the field was not set
+	After  string // empty when the field was removed
+}
+
+// IPStatusUnusable marks the addresses of an AddressMap that cannot be
+// assigned, such as the network and broadcast addresses of an IPv4 subnet.
+const IPStatusUnusable = "unusable"
+
+// AddressMap draws a subnet, or a block inside it, as a grid of cells: one
+// per address when there are few enough, otherwise one per equal-sized block
+// of addresses.
+type AddressMap struct {
+	Prefix string    `json:"prefix"` // the subnet or the block zoomed into
+	Parent string    `json:"parent"` // the map one zoom level out, "" at the subnet
+	Blocks bool      `json:"blocks"` // whether the cells are blocks rather than addresses
+	Cells  []MapCell `json:"cells"`
+}
+
+// MapCell is one cell of an AddressMap: an address, or a block with the
+// counts of its addresses.
+type MapCell struct {
+	IP    *IP          `json:"ip,omitempty"`    // an address cell
+	Block string       `json:"block,omitempty"` // the CIDR of a block cell
+	Stats *SubnetStats `json:"stats,omitempty"` // the usage of a block cell
+}
+
+// SearchResults holds what a global search matched, each kind ordered and
+// capped independently.
+type SearchResults struct {
+	Query   string   `json:"query"`
+	Address string   `json:"address,omitempty"` // the query as an IP address, if it is one
+	Subnets []Subnet `json:"subnets"`           // for an address, the subnets containing it, most specific first
+	IPs     []IP     `json:"ips"`
+	Devices []Device `json:"devices"`
+	VLANs   []VLAN   `json:"vlans"`
+}
+
+// Empty reports whether the search matched nothing.
+func (r SearchResults) Empty() bool {
+	return len(r.Subnets) == 0 && len(r.IPs) == 0 && len(r.Devices) == 0 && len(r.VLANs) == 0
 }
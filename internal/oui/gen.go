@@ -0,0 +1,112 @@
+//go:build ignore
+
+// gen regenerates oui.txt from the IEEE MA-L registry. Run it with
+// go generate ./internal/oui, or point -src at a downloaded copy of oui.csv
+// to work offline.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+const registryURL = "https://standards-oui.ieee.org/oui/oui.csv"
+
+func main() {
+	src := flag.String("src", registryURL, "URL or path of the IEEE MA-L registry in CSV")
+	out := flag.String("o", "oui.txt", "file to write")
+	flag.Parse()
+
+	r, err := open(*src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+	vendors, err := parse(r)
+	if err != nil {
+		log.Fatalf("%s: %v", *src, err)
+	}
+	if err := write(*out, vendors); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %d prefixes to %s", len(vendors), *out)
+}
+
+// open reads the registry from a URL or a file.
+func open(src string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.Open(src)
+	}
+	resp, err := http.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", src, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// parse reads the rows "MA-L,<6 hex digits>,<organization>,<address>" of
+// the registry into vendors keyed by colon-separated lowercase prefix.
+func parse(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	vendors := make(map[string]string)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 3 || rec[0] != "MA-L" || len(rec[1]) != 6 {
+			continue
+		}
+		a := strings.ToLower(rec[1])
+		prefix := a[0:2] + ":" + a[2:4] + ":" + a[4:6]
+		vendor := strings.Join(strings.Fields(rec[2]), " ")
+		if _, dup := vendors[prefix]; !dup && vendor != "" {
+			vendors[prefix] = vendor
+		}
+	}
+	if len(vendors) == 0 {
+		return nil, fmt.Errorf("no MA-L assignments found")
+	}
+	return vendors, nil
+}
+
+// write stores vendors in the format read by the oui package.
+func write(path string, vendors map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "# OUI prefix to vendor, generated by gen.go from the IEEE MA-L registry.")
+	fmt.Fprintln(w, "# Format: prefix<TAB>vendor, prefix as three colon-separated lowercase octets.")
+	prefixes := make([]string, 0, len(vendors))
+	for p := range vendors {
+		prefixes = append(prefixes, p)
+	}
+	slices.Sort(prefixes)
+	for _, p := range prefixes {
+		fmt.Fprintf(w, "%s\t%s\n", p, vendors[p])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
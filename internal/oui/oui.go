@@ -0,0 +1,47 @@
+// Package oui looks up the vendor of a MAC address by its organizationally
+// unique identifier (the first three octets) in an embedded offline table.
+package oui
+
+import (
+	_ "embed"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:generate go run gen.go
+
+//go:embed oui.txt
+var table string
+
+// vendors maps a prefix such as "00:50:56" to its vendor name.
+var vendors = sync.OnceValue(func() map[string]string {
+	m := make(map[string]string)
+	for line := range strings.Lines(table) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if prefix, vendor, ok := strings.Cut(line, "\t"); ok {
+			m[prefix] = vendor
+		}
+	}
+	return m
+})
+
+// Lookup returns the vendor of mac, a MAC address in lowercase colon form
+// (e.g. "00:50:56:aa:bb:cc"), or "" if its prefix is unknown. Locally
+// administered addresses have no vendor.
+func Lookup(mac string) string {
+	if len(mac) < 8 || locallyAdministered(mac) {
+		return ""
+	}
+	return vendors()[mac[:8]]
+}
+
+// locallyAdministered reports whether the U/L bit of the first octet of mac
+// is set: such addresses are assigned by software, not by a vendor.
+func locallyAdministered(mac string) bool {
+	b, err := strconv.ParseUint(mac[:2], 16, 8)
+	return err == nil && b&0x02 != 0
+}
@@ -0,0 +1,47 @@
+package oui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	// Vendors are matched by prefix, as the IEEE registry spells them out
+	// in full (e.g. "VMware, Inc.").
+	tests := []struct {
+		mac, vendor string
+	}{
+		{"00:50:56:aa:bb:cc", "VMware"},
+		{"08:00:27:00:00:01", "PCS Systemtechnik"},
+		{"b8:27:eb:12:34:56", "Raspberry Pi"},
+	}
+	for _, tt := range tests {
+		if got := Lookup(tt.mac); !strings.HasPrefix(got, tt.vendor) {
+			t.Errorf("Lookup(%q) = %q, want %q...", tt.mac, got, tt.vendor)
+		}
+	}
+	for _, mac := range []string{"02:00:00:00:00:01", "52:54:00:12:34:56", "00:50", ""} {
+		if got := Lookup(mac); got != "" {
+			t.Errorf("Lookup(%q) = %q, want no vendor", mac, got)
+		}
+	}
+}
+
+func TestLocallyAdministered(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want bool
+	}{
+		{"00:50:56:aa:bb:cc", false},
+		{"b8:27:eb:12:34:56", false},
+		{"02:00:00:00:00:01", true},
+		{"52:54:00:12:34:56", true}, // QEMU/KVM
+		{"fe:ff:ff:ff:ff:ff", true},
+		{"01:00:5e:00:00:01", false}, // multicast, but universally administered
+	}
+	for _, tt := range tests {
+		if got := locallyAdministered(tt.mac); got != tt.want {
+			t.Errorf("locallyAdministered(%q) = %v, want %v", tt.mac, got, tt.want)
+		}
+	}
+}
@@ -29,7 +29,15 @@ func Header() templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"navbar bg-primary text-primary-content shadow-lg mb-8\"><div class=\"container mx-auto\"><div class=\"flex-1\"><a href=\"/\" class=\"btn btn-ghost text-xl normal-case\">GOTH IPAM</a></div><div class=\"flex-none\"><ul class=\"menu menu-horizontal px-1\"><li><a href=\"/\">Dashboard</a></li></ul></div></div></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"navbar bg-primary text-primary-content shadow-lg mb-8\"><div class=\"container mx-auto\"><div class=\"flex-1\"><a href=\"/\" class=\"btn btn-ghost text-xl normal-case\">GOTH IPAM</a></div><div class=\"flex-none flex items-center gap-2\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = SearchBox().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<ul class=\"menu menu-horizontal px-1\"><li><a href=\"/\">Dashboard</a></li><li><a href=\"/sites\">Sites</a></li><li><a href=\"/vrfs\">VRFs</a></li><li><a href=\"/vlans\">VLANs</a></li><li><a href=\"/devices\">Devices</a></li><li><a href=\"/mac-addresses\">MAC Addresses</a></li><li><a href=\"/expiring\">Expiring</a></li><li><a href=\"/changelog\">Changelog</a></li><li><a href=\"/tenants\">Tenants</a></li><li><a href=\"/tags\">Tags</a></li><li><a href=\"/custom-fields\">Custom Fields</a></li></ul></div></div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}